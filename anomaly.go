@@ -0,0 +1,152 @@
+package cflogparser
+
+import (
+	"math"
+	"sort"
+)
+
+// Anomaly is a point of a series that is much higher than expected.
+// Score is the deviation from Expected in units of standard deviation.
+type Anomaly struct {
+	Index    int     `json:"index"`
+	Value    float64 `json:"value"`
+	Expected float64 `json:"expected"`
+	Score    float64 `json:"score"`
+}
+
+// Detector finds spikes in a series of values sampled at regular intervals.
+type Detector interface {
+	Detect(values []float64) []Anomaly
+}
+
+// score returns how many standard deviations v exceeds expected. The
+// standard deviation is floored by min, and if it is still zero, any
+// excess is regarded as infinitely anomalous.
+func score(v, expected, stddev, min float64) float64 {
+	if stddev < min {
+		stddev = min
+	}
+	if stddev == 0 {
+		if v > expected {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return (v - expected) / stddev
+}
+
+// ZScoreDetector compares each value with mean and standard deviation of
+// the previous Window values. Nothing is detected unless Window is
+// positive.
+type ZScoreDetector struct {
+	Window       int
+	Threshold    float64
+	MinDeviation float64
+}
+
+// Detect implements Detector.
+func (d *ZScoreDetector) Detect(values []float64) []Anomaly {
+	if d.Window < 1 {
+		return nil
+	}
+	var as []Anomaly
+	for i := d.Window; i < len(values); i++ {
+		mean, stddev := meanStddev(values[i-d.Window : i])
+		if s := score(values[i], mean, stddev, d.MinDeviation); s > d.Threshold {
+			as = append(as, Anomaly{Index: i, Value: values[i], Expected: mean, Score: s})
+		}
+	}
+	return as
+}
+
+func meanStddev(vs []float64) (float64, float64) {
+	sum := 0.0
+	for _, v := range vs {
+		sum += v
+	}
+	mean := sum / float64(len(vs))
+	sq := 0.0
+	for _, v := range vs {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(vs)))
+}
+
+// EWMADetector compares each value with exponentially weighted moving
+// average and variance of the previous values. Alpha is the weight of the
+// latest value, and the first Warmup values are never reported. Nothing is
+// detected if Warmup is negative.
+type EWMADetector struct {
+	Alpha        float64
+	Threshold    float64
+	Warmup       int
+	MinDeviation float64
+}
+
+// Detect implements Detector.
+func (d *EWMADetector) Detect(values []float64) []Anomaly {
+	var as []Anomaly
+	if len(values) == 0 || d.Warmup < 0 {
+		return nil
+	}
+	mean, variance := values[0], 0.0
+	for i := 1; i < len(values); i++ {
+		v := values[i]
+		if i >= d.Warmup {
+			if s := score(v, mean, math.Sqrt(variance), d.MinDeviation); s > d.Threshold {
+				as = append(as, Anomaly{Index: i, Value: v, Expected: mean, Score: s})
+			}
+		}
+		diff := v - mean
+		mean += d.Alpha * diff
+		variance = (1 - d.Alpha) * (variance + d.Alpha*diff*diff)
+	}
+	return as
+}
+
+// SeasonalDetector compares each value with values at the same phase of
+// the previous Seasons periods, e.g. the same time of the previous 7 days.
+// Period is the number of values in a period. It uses median and median
+// absolute deviation, so that past anomalies do not distort the baseline.
+// Nothing is detected unless both Period and Seasons are positive.
+type SeasonalDetector struct {
+	Period       int
+	Seasons      int
+	Threshold    float64
+	MinDeviation float64
+}
+
+// Detect implements Detector.
+func (d *SeasonalDetector) Detect(values []float64) []Anomaly {
+	if d.Period < 1 || d.Seasons < 1 {
+		return nil
+	}
+	var as []Anomaly
+	past := make([]float64, 0, d.Seasons)
+	for i := d.Period; i < len(values); i++ {
+		past = past[:0]
+		for k := 1; k <= d.Seasons && i-k*d.Period >= 0; k++ {
+			past = append(past, values[i-k*d.Period])
+		}
+		med := median(past)
+		for j, v := range past {
+			past[j] = math.Abs(v - med)
+		}
+		// 1.4826 * MAD is a consistent estimator of standard deviation.
+		stddev := 1.4826 * median(past)
+		if s := score(values[i], med, stddev, d.MinDeviation); s > d.Threshold {
+			as = append(as, Anomaly{Index: i, Value: values[i], Expected: med, Score: s})
+		}
+	}
+	return as
+}
+
+// median returns median of vs. It reorders vs.
+func median(vs []float64) float64 {
+	sort.Float64s(vs)
+	n := len(vs)
+	if n%2 == 1 {
+		return vs[n/2]
+	}
+	return (vs[n/2-1] + vs[n/2]) / 2
+}
@@ -0,0 +1,93 @@
+package cflogparser
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// dailySeries generates n days of hourly values with daily seasonality and
+// noise, and puts spikes at given indices.
+func dailySeries(n int, spikes ...int) []float64 {
+	r := rand.New(rand.NewSource(1))
+	vs := make([]float64, n*24)
+	for i := range vs {
+		vs[i] = 1000 + 500*math.Sin(2*math.Pi*float64(i%24)/24) + 20*r.NormFloat64()
+	}
+	for _, i := range spikes {
+		vs[i] *= 3
+	}
+	return vs
+}
+
+func anomalyIndices(as []Anomaly) map[int]bool {
+	m := map[int]bool{}
+	for _, a := range as {
+		m[a.Index] = true
+	}
+	return m
+}
+
+func TestDetectors(t *testing.T) {
+	// The first spike is obvious. The second is at the bottom of daily cycle,
+	// so that only seasonal detector can tell it from usual peaks.
+	spikes := []int{24*5 + 3, 24*6 + 18}
+	vs := dailySeries(8, spikes...)
+	tests := []struct {
+		name  string
+		d     Detector
+		found []int
+	}{
+		{"zscore", &ZScoreDetector{Window: 24, Threshold: 4}, spikes[:1]},
+		{"ewma", &EWMADetector{Alpha: 0.1, Threshold: 4, Warmup: 24}, spikes[:1]},
+		{"seasonal", &SeasonalDetector{Period: 24, Seasons: 4, Threshold: 6, MinDeviation: 20}, spikes},
+	}
+	for _, test := range tests {
+		got := anomalyIndices(test.d.Detect(vs))
+		for _, i := range test.found {
+			if !got[i] {
+				t.Errorf("%s: spike at %d is not detected", test.name, i)
+			}
+		}
+		if test.name == "seasonal" && len(got) != len(spikes) {
+			// Seasonal detector should be free from false positives on
+			// seasonal data.
+			t.Errorf("%s: got %d anomalies, want %d", test.name, len(got), len(spikes))
+		}
+	}
+}
+
+func TestDetectorsOnFlatSeries(t *testing.T) {
+	vs := make([]float64, 100)
+	for i := range vs {
+		vs[i] = 10
+	}
+	vs[80] = 11
+	ds := []Detector{
+		&ZScoreDetector{Window: 10, Threshold: 3, MinDeviation: 2},
+		&EWMADetector{Alpha: 0.1, Threshold: 3, MinDeviation: 2},
+		&SeasonalDetector{Period: 10, Seasons: 3, Threshold: 3, MinDeviation: 2},
+	}
+	for _, d := range ds {
+		if as := d.Detect(vs); len(as) != 0 {
+			t.Errorf("%T: small change should be tolerated by MinDeviation: %+v", d, as)
+		}
+	}
+	vs[80] = 20
+	for _, d := range ds {
+		if as := d.Detect(vs); len(as) != 1 || as[0].Index != 80 || as[0].Expected != 10 {
+			t.Errorf("%T: got %+v", d, as)
+		}
+	}
+	for _, d := range []Detector{
+		&SeasonalDetector{Period: 0, Seasons: 3, Threshold: 3},
+		&SeasonalDetector{Period: 10, Seasons: 0, Threshold: 3},
+		&ZScoreDetector{Window: 0, Threshold: 3},
+		&ZScoreDetector{Window: -1, Threshold: 3},
+		&EWMADetector{Alpha: 0.1, Warmup: -1, Threshold: 3},
+	} {
+		if as := d.Detect(vs); len(as) != 0 {
+			t.Errorf("%+v: got %+v", d, as)
+		}
+	}
+}
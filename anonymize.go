@@ -0,0 +1,251 @@
+package cflogparser
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Action tells Anonymizer what to do with a value.
+type Action int
+
+// Actions of Anonymizer.
+const (
+	ActionKeep     Action = iota // Leave the value as is
+	ActionHash                   // Replace the value with its keyed hash
+	ActionDrop                   // Remove the value
+	ActionTruncate               // Zero host bits of IP address; same as ActionKeep for others
+)
+
+// ParseAction parses the name of Action: "keep", "hash", "drop" or
+// "truncate".
+func ParseAction(s string) (Action, error) {
+	switch s {
+	case "keep":
+		return ActionKeep, nil
+	case "hash":
+		return ActionHash, nil
+	case "drop":
+		return ActionDrop, nil
+	case "truncate":
+		return ActionTruncate, nil
+	}
+	return ActionKeep, fmt.Errorf("Unknown action: %s", s)
+}
+
+// Anonymizer removes or pseudonymizes personal information in WebLog and
+// RTMPLog. Hashes are keyed by Key, so that the same value is always
+// replaced with the same pseudonym, and records can still be joined by it,
+// while the original value can't be recovered without the key.
+type Anonymizer struct {
+	Key []byte
+
+	// IP is applied to RequestIP and every address in XforwardedFor.
+	// ActionTruncate keeps the first IPv4Prefix or IPv6Prefix bits.
+	// ActionHash maps IPv4 addresses into 10.0.0.0/8 and IPv6 into fd00::/8.
+	// ActionDrop replaces them with the unspecified address.
+	IP         Action
+	IPv4Prefix int
+	IPv6Prefix int
+
+	// Cookies is applied to values of cookies by name, and DefaultCookie
+	// to the others.
+	Cookies       map[string]Action
+	DefaultCookie Action
+
+	// Params is applied to values of query parameters by name, and
+	// DefaultParam to the others. Query strings of the request, stream,
+	// referrer and page URL are all scrubbed.
+	Params       map[string]Action
+	DefaultParam Action
+
+	// Values matching any of Patterns, such as email addresses, are handled
+	// with PatternAction, unless the parameter is dropped by name. Values are
+	// matched both as is and URL-decoded.
+	Patterns      []*regexp.Regexp
+	PatternAction Action
+}
+
+// NewAnonymizer returns an Anonymizer with key, which truncates IP addresses
+// to /24 and /48, and keeps other values. Set fields to change it.
+func NewAnonymizer(key []byte) *Anonymizer {
+	return &Anonymizer{
+		Key:           key,
+		IP:            ActionTruncate,
+		IPv4Prefix:    24,
+		IPv6Prefix:    48,
+		PatternAction: ActionHash,
+	}
+}
+
+// Anonymize anonymizes rec, which must be *WebLog or *RTMPLog, in place.
+// It fails if IPv4Prefix is not between 0 and 32, or IPv6Prefix is not
+// between 0 and 128.
+func (a *Anonymizer) Anonymize(rec interface{}) error {
+	if a.IPv4Prefix < 0 || a.IPv4Prefix > 32 {
+		return fmt.Errorf("IPv4 prefix must be between 0 and 32: %d", a.IPv4Prefix)
+	}
+	if a.IPv6Prefix < 0 || a.IPv6Prefix > 128 {
+		return fmt.Errorf("IPv6 prefix must be between 0 and 128: %d", a.IPv6Prefix)
+	}
+	switch l := rec.(type) {
+	case *WebLog:
+		l.RequestIP = a.ip(l.RequestIP)
+		l.XforwardedFor = a.forwardedFor(l.XforwardedFor)
+		l.Cookie = a.cookie(l.Cookie)
+		l.QueryString = a.query(l.QueryString)
+		l.Referrer = a.scrubURL(l.Referrer)
+	case *RTMPLog:
+		l.RequestIP = a.ip(l.RequestIP)
+		l.QueryString = a.query(l.QueryString)
+		l.StreamQuery = a.query(l.StreamQuery)
+		l.Referrer = a.scrubURL(l.Referrer)
+		l.PageURL = a.scrubURL(l.PageURL)
+	default:
+		return fmt.Errorf("Unexpected record type: %T", rec)
+	}
+	return nil
+}
+
+func (a *Anonymizer) mac(s string) []byte {
+	m := hmac.New(sha256.New, a.Key)
+	m.Write([]byte(s))
+	return m.Sum(nil)
+}
+
+// Pseudonym returns the keyed hash of s, as a short URL-safe string.
+func (a *Anonymizer) Pseudonym(s string) string {
+	return base64.RawURLEncoding.EncodeToString(a.mac(s)[:12])
+}
+
+func (a *Anonymizer) ip(ip net.IP) net.IP {
+	if ip == nil {
+		return nil
+	}
+	v4 := ip.To4()
+	switch a.IP {
+	case ActionTruncate:
+		if v4 != nil {
+			return v4.Mask(net.CIDRMask(a.IPv4Prefix, 32))
+		}
+		return ip.Mask(net.CIDRMask(a.IPv6Prefix, 128))
+	case ActionHash:
+		h := a.mac(ip.String())
+		if v4 != nil {
+			return net.IPv4(10, h[0], h[1], h[2]).To4()
+		}
+		res := make(net.IP, net.IPv6len)
+		res[0] = 0xfd
+		copy(res[1:], h)
+		return res
+	case ActionDrop:
+		if v4 != nil {
+			return net.IPv4zero.To4()
+		}
+		return net.IPv6unspecified
+	}
+	return ip
+}
+
+func (a *Anonymizer) forwardedFor(s string) string {
+	if s == "" || a.IP == ActionKeep {
+		return s
+	}
+	addrs := strings.Split(s, ",")
+	for i, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if ip := net.ParseIP(addr); ip != nil {
+			addrs[i] = a.ip(ip).String()
+		} else {
+			addrs[i] = a.Pseudonym(addr)
+		}
+	}
+	return strings.Join(addrs, ",")
+}
+
+// apply applies act to a value. It returns false if the value is dropped.
+func (a *Anonymizer) apply(act Action, v string) (string, bool) {
+	dec, err := url.QueryUnescape(v)
+	if err != nil {
+		dec = v
+	}
+	for _, p := range a.Patterns {
+		if act != ActionDrop && (p.MatchString(v) || p.MatchString(dec)) {
+			act = a.PatternAction
+			break
+		}
+	}
+	switch act {
+	case ActionHash:
+		return a.Pseudonym(v), true
+	case ActionDrop:
+		return "", false
+	}
+	return v, true
+}
+
+func (a *Anonymizer) cookie(s string) string {
+	if s == "" {
+		return s
+	}
+	var res []string
+	for _, c := range strings.Split(s, ";") {
+		c = strings.TrimSpace(c)
+		name, val := c, ""
+		if i := strings.IndexByte(c, '='); i >= 0 {
+			name, val = c[:i], c[i+1:]
+		}
+		act, ok := a.Cookies[name]
+		if !ok {
+			act = a.DefaultCookie
+		}
+		if v, ok := a.apply(act, val); ok {
+			res = append(res, name+"="+v)
+		}
+	}
+	return strings.Join(res, "; ")
+}
+
+func (a *Anonymizer) query(s string) string {
+	if s == "" {
+		return s
+	}
+	var res []string
+	for _, p := range strings.Split(s, "&") {
+		name, val := p, ""
+		eq := strings.IndexByte(p, '=')
+		if eq >= 0 {
+			name, val = p[:eq], p[eq+1:]
+		}
+		act, ok := a.Params[name]
+		if !ok {
+			act = a.DefaultParam
+		}
+		if v, ok := a.apply(act, val); ok {
+			if eq >= 0 {
+				res = append(res, name+"="+v)
+			} else {
+				res = append(res, name)
+			}
+		}
+	}
+	return strings.Join(res, "&")
+}
+
+// scrubURL scrubs query string of a URL.
+func (a *Anonymizer) scrubURL(s string) string {
+	i := strings.IndexByte(s, '?')
+	if i < 0 {
+		return s
+	}
+	q := a.query(s[i+1:])
+	if q == "" {
+		return s[:i]
+	}
+	return s[:i+1] + q
+}
@@ -0,0 +1,110 @@
+package cflogparser
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeWeb(t *testing.T) {
+	a := NewAnonymizer([]byte("secret"))
+	a.Cookies = map[string]Action{"zip": ActionKeep, "session": ActionHash}
+	a.DefaultCookie = ActionDrop
+	a.Params = map[string]Action{"token": ActionDrop}
+	a.Patterns = []*regexp.Regexp{regexp.MustCompile(`^[^@]+@[^@]+$`)}
+
+	l := &WebLog{
+		RequestIP:     net.ParseIP("192.0.2.10"),
+		XforwardedFor: "198.51.100.7, 2001:db8:1:2::1",
+		Cookie:        "zip=98101; session=abc; tracking=xyz",
+		QueryString:   "a=b&token=t0k3n&email=foo%40example.com&flag",
+		Referrer:      "https://example.com/page?token=t&x=1",
+	}
+	if err := a.Anonymize(l); err != nil {
+		t.Fatal(err)
+	}
+
+	if !l.RequestIP.Equal(net.ParseIP("192.0.2.0")) {
+		t.Errorf("got IP %s, want 192.0.2.0", l.RequestIP)
+	}
+	if l.XforwardedFor != "198.51.100.0,2001:db8:1::" {
+		t.Errorf("got X-Forwarded-For %s", l.XforwardedFor)
+	}
+	session := a.Pseudonym("abc")
+	if l.Cookie != "zip=98101; session="+session {
+		t.Errorf("got cookie %s", l.Cookie)
+	}
+	if l.QueryString != "a=b&email="+a.Pseudonym("foo%40example.com")+"&flag" {
+		t.Errorf("got query %s", l.QueryString)
+	}
+	if l.Referrer != "https://example.com/page?x=1" {
+		t.Errorf("got referrer %s", l.Referrer)
+	}
+
+	// Pseudonyms depend on key only, so records can be joined across runs.
+	l2 := &WebLog{Cookie: "session=abc"}
+	a2 := NewAnonymizer([]byte("secret"))
+	a2.Cookies = map[string]Action{"session": ActionHash}
+	if err := a2.Anonymize(l2); err != nil {
+		t.Fatal(err)
+	}
+	if l2.Cookie != "session="+session {
+		t.Errorf("pseudonym is not deterministic: %s", l2.Cookie)
+	}
+	if NewAnonymizer([]byte("other")).Pseudonym("abc") == session {
+		t.Error("pseudonym must depend on key")
+	}
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	a := NewAnonymizer([]byte("secret"))
+	a.IP = ActionHash
+	v4 := a.ip(net.ParseIP("192.0.2.10"))
+	if v4.To4() == nil || v4[0] != 10 {
+		t.Errorf("got %s, want an address in 10.0.0.0/8", v4)
+	}
+	if !a.ip(net.ParseIP("192.0.2.10")).Equal(v4) {
+		t.Error("hashed IP is not deterministic")
+	}
+	if v6 := a.ip(net.ParseIP("2001:db8::1")); v6.To4() != nil || v6[0] != 0xfd {
+		t.Errorf("got %s, want an address in fd00::/8", v6)
+	}
+	a.IP = ActionDrop
+	if ip := a.ip(net.ParseIP("192.0.2.10")); !ip.Equal(net.IPv4zero) {
+		t.Errorf("got %s, want 0.0.0.0", ip)
+	}
+
+	for _, p := range [][2]int{{33, 48}, {-1, 48}, {24, 129}, {24, -1}} {
+		a := NewAnonymizer(nil)
+		a.IPv4Prefix, a.IPv6Prefix = p[0], p[1]
+		if err := a.Anonymize(&WebLog{RequestIP: net.ParseIP("192.0.2.10")}); err == nil {
+			t.Errorf("expected error for prefixes %v", p)
+		}
+	}
+	if err := a.Anonymize(nil); err == nil {
+		t.Error("expected error for nil record")
+	}
+}
+
+func TestAnonymizeRTMP(t *testing.T) {
+	a := NewAnonymizer([]byte("secret"))
+	a.DefaultParam = ActionHash
+	for _, l := range readRTMPLogs(t, "testdata/sample-rtmp.log") {
+		if err := a.Anonymize(l); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(l.RequestIP.String(), ".0") {
+			t.Errorf("IP not truncated: %s", l.RequestIP)
+		}
+		if strings.Contains(l.PageURL, "example=204") {
+			t.Errorf("page URL not scrubbed: %s", l.PageURL)
+		}
+		if l.QueryString != "key="+a.Pseudonym("value") {
+			t.Errorf("query not scrubbed: %s", l.QueryString)
+		}
+		if _, err := ParseLineRTMP(FormatLine(l)); err != nil {
+			t.Errorf("can't parse anonymized line: %v", err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package arrow builds Apache Arrow record batches of WebLog and RTMPLog, to
+// hand logs to Arrow-based engines without per-row marshalling.
+//
+// The schema mirrors fields of WebLog and RTMPLog with their JSON names.
+// Time is a timestamp in milliseconds in UTC, and IP addresses are strings.
+package arrow
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	goarrow "github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+)
+
+// Schemas of record batches.
+var (
+	WebSchema  = schemaOf(cflogparser.WebLogFields)
+	RTMPSchema = schemaOf(cflogparser.RTMPLogFields)
+)
+
+func schemaOf(fs []cflogparser.Field) *goarrow.Schema {
+	afs := make([]goarrow.Field, len(fs))
+	for i, f := range fs {
+		afs[i] = goarrow.Field{Name: f.Name, Type: arrowType(f.Type)}
+	}
+	return goarrow.NewSchema(afs, nil)
+}
+
+func arrowType(t reflect.Type) goarrow.DataType {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return goarrow.FixedWidthTypes.Timestamp_ms
+	case reflect.TypeOf(net.IP{}):
+		return goarrow.BinaryTypes.String
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return goarrow.BinaryTypes.String
+	case reflect.Uint16:
+		return goarrow.PrimitiveTypes.Uint16
+	case reflect.Uint32:
+		return goarrow.PrimitiveTypes.Uint32
+	case reflect.Uint64:
+		return goarrow.PrimitiveTypes.Uint64
+	case reflect.Float32:
+		return goarrow.PrimitiveTypes.Float32
+	}
+	panic("unsupported field type: " + t.String())
+}
+
+// Builder builds a record batch column by column.
+type Builder struct {
+	rb     *array.RecordBuilder
+	rtmp   bool
+	fields []cflogparser.Field
+	vals   []value
+}
+
+// value holds a parsed field of a line until the whole line is validated.
+type value struct {
+	s string
+	u uint64
+	f float64
+}
+
+// NewBuilder returns a Builder allocating memory from mem. If rtmp is true,
+// it builds batches of RTMPLog, otherwise of WebLog.
+func NewBuilder(mem memory.Allocator, rtmp bool) *Builder {
+	schema, fields := WebSchema, cflogparser.WebLogFields
+	if rtmp {
+		schema, fields = RTMPSchema, cflogparser.RTMPLogFields
+	}
+	return &Builder{
+		rb:     array.NewRecordBuilder(mem, schema),
+		rtmp:   rtmp,
+		fields: fields,
+		vals:   make([]value, len(fields)),
+	}
+}
+
+// Schema returns the schema of batches.
+func (b *Builder) Schema() *goarrow.Schema {
+	return b.rb.Schema()
+}
+
+// Len returns the number of rows appended since the last NewRecord.
+func (b *Builder) Len() int {
+	return b.rb.Field(0).Len()
+}
+
+// Append appends rec, which must be *WebLog or *RTMPLog as given to
+// NewBuilder.
+func (b *Builder) Append(rec interface{}) error {
+	switch rec.(type) {
+	case *cflogparser.WebLog:
+		if b.rtmp {
+			return fmt.Errorf("Unexpected record type: %T", rec)
+		}
+	case *cflogparser.RTMPLog:
+		if !b.rtmp {
+			return fmt.Errorf("Unexpected record type: %T", rec)
+		}
+	default:
+		return fmt.Errorf("Unexpected record type: %T", rec)
+	}
+	for i, f := range b.fields {
+		switch v := f.Value(rec).(type) {
+		case time.Time:
+			b.rb.Field(i).(*array.TimestampBuilder).Append(goarrow.Timestamp(v.UnixNano() / 1e6))
+		case net.IP:
+			b.rb.Field(i).(*array.StringBuilder).Append(f.String(rec))
+		case string:
+			b.rb.Field(i).(*array.StringBuilder).Append(v)
+		case uint16:
+			b.rb.Field(i).(*array.Uint16Builder).Append(v)
+		case uint32:
+			b.rb.Field(i).(*array.Uint32Builder).Append(v)
+		case uint64:
+			b.rb.Field(i).(*array.Uint64Builder).Append(v)
+		case float32:
+			b.rb.Field(i).(*array.Float32Builder).Append(v)
+		}
+	}
+	return nil
+}
+
+// AppendLine parses a line of log and appends it directly to the columns,
+// without making WebLog or RTMPLog. Fields are parsed with the same
+// functions as ParseLineWeb and ParseLineRTMP. On error, nothing is
+// appended.
+func (b *Builder) AppendLine(line string) error {
+	vals := strings.Split(line, "\t")
+	// Date and time are split in two columns, and the others follow in the
+	// order of fields.
+	if len(vals) < len(b.fields)+1 {
+		return fmt.Errorf("Insufficient number of fields: %s", line)
+	}
+	for i, f := range b.fields {
+		if err := parseField(i, f, vals, &b.vals[i]); err != nil {
+			return fmt.Errorf("Can't parse line: %w: %s", err, line)
+		}
+	}
+	for i, f := range b.fields {
+		v := b.vals[i]
+		switch f.Type.Kind() {
+		case reflect.Struct: // time.Time
+			b.rb.Field(i).(*array.TimestampBuilder).Append(goarrow.Timestamp(v.u))
+		case reflect.String, reflect.Slice: // string and net.IP
+			b.rb.Field(i).(*array.StringBuilder).Append(v.s)
+		case reflect.Uint16:
+			b.rb.Field(i).(*array.Uint16Builder).Append(uint16(v.u))
+		case reflect.Uint32:
+			b.rb.Field(i).(*array.Uint32Builder).Append(uint32(v.u))
+		case reflect.Uint64:
+			b.rb.Field(i).(*array.Uint64Builder).Append(v.u)
+		case reflect.Float32:
+			b.rb.Field(i).(*array.Float32Builder).Append(float32(v.f))
+		}
+	}
+	return nil
+}
+
+// parseField parses the i-th field f of a line split into vals.
+func parseField(i int, f cflogparser.Field, vals []string, v *value) error {
+	if i == 0 {
+		t, err := cflogparser.ParseTimeField(vals[0], vals[1])
+		if err != nil {
+			return err
+		}
+		v.u = uint64(t.UnixNano() / 1e6)
+		return nil
+	}
+	s := vals[i+1]
+	var err error
+	switch f.Type.Kind() {
+	case reflect.Slice: // net.IP
+		var ip net.IP
+		ip, err = cflogparser.ParseIPField(s)
+		if err == nil {
+			v.s = ip.String()
+		}
+	case reflect.String:
+		v.s, err = cflogparser.ParseStringField(s)
+	case reflect.Float32:
+		v.f, err = cflogparser.ParseFloatField(s)
+	default:
+		var n int64
+		n, err = cflogparser.ParseIntField(s)
+		v.u = uint64(n)
+	}
+	return err
+}
+
+// NewRecord returns a record batch of rows appended so far, and resets the
+// builder. The caller must Release the record.
+func (b *Builder) NewRecord() array.Record {
+	return b.rb.NewRecord()
+}
+
+// Release releases memory of the builder.
+func (b *Builder) Release() {
+	b.rb.Release()
+}
@@ -0,0 +1,230 @@
+package arrow
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+)
+
+func readLines(t *testing.T) []string {
+	t.Helper()
+	f, err := os.Open("../testdata/sample-rtmp.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if !strings.HasPrefix(s.Text(), "#") {
+			lines = append(lines, s.Text())
+		}
+	}
+	return lines
+}
+
+// dump formats all values of rec to compare records.
+func dump(rec array.Record) string {
+	var b strings.Builder
+	for i, col := range rec.Columns() {
+		b.WriteString(rec.ColumnName(i))
+		b.WriteString(": ")
+		b.WriteString(strings.TrimSpace(strings.Replace(stringOf(col), "\n", " ", -1)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func stringOf(a array.Interface) string {
+	if s, ok := a.(interface{ String() string }); ok {
+		return s.String()
+	}
+	return ""
+}
+
+func TestBuilder(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	lines := readLines(t)
+	b1 := NewBuilder(mem, true)
+	defer b1.Release()
+	b2 := NewBuilder(mem, true)
+	defer b2.Release()
+	for _, line := range lines {
+		l, err := cflogparser.ParseLineRTMP(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b1.Append(l); err != nil {
+			t.Fatal(err)
+		}
+		if err := b2.AppendLine(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b2.AppendLine("2010-03-12\t23:51:20\tSEA4\tbroken"); err == nil {
+		t.Error("expected error for broken line")
+	}
+	if err := b2.AppendLine(strings.Replace(lines[0], "192.0.2.147", "x", 1)); err == nil {
+		t.Error("expected error for invalid IP")
+	}
+	if err := b1.Append(&cflogparser.WebLog{}); err == nil {
+		t.Error("expected error for WebLog")
+	}
+	if b2.Len() != len(lines) {
+		t.Fatalf("got %d rows, want %d", b2.Len(), len(lines))
+	}
+
+	r1 := b1.NewRecord()
+	defer r1.Release()
+	r2 := b2.NewRecord()
+	defer r2.Release()
+	if r1.NumRows() != int64(len(lines)) || r1.NumCols() != int64(len(cflogparser.RTMPLogFields)) {
+		t.Fatalf("unexpected shape: %d x %d", r1.NumRows(), r1.NumCols())
+	}
+	if d1, d2 := dump(r1), dump(r2); d1 != d2 {
+		t.Errorf("Append and AppendLine differ:\n%s\n%s", d1, d2)
+	}
+	if got := r1.Column(4).(*array.Uint64).Value(1); got != 3914 {
+		t.Errorf("got bytes %d, want 3914", got)
+	}
+	if got := r1.Column(0).(*array.Timestamp).Value(0); got != 1268437880000 {
+		t.Errorf("got time %d, want 1268437880000", got)
+	}
+	if b1.Len() != 0 {
+		t.Errorf("builder is not reset: %d", b1.Len())
+	}
+}
+
+func TestBuilderWeb(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	lines := []string{
+		"2014-05-23\t01:13:11\tFRA2\t182\t2001:db8::1\tGET\td111111abcdef8.cloudfront.net\t/view/my/file.html\t200\twww.displaymyfiles.com\tMozilla/4.0%20(compatible;%20MSIE%205.0b1;%20Mac_PowerPC)\ta=1\tzip=98101\tRefreshHit\tMRVMF7KydIvxMWfJIglgwHQwZsbG2IhRJ07sn9AkKUFSHS9EXAMPLE==\td111111abcdef8.cloudfront.net\thttps\t12345678901\t0.001\t192.0.2.1\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\tRefreshHit\tHTTP/2.0\tProcessed\t4294967295",
+		"2014-05-23\t01:13:12\tFRA2\t-\t192.0.2.10\tGET\t-\t/caf%25C3%25A9\t000\t-\t-\t-\t-\tError\tid\t-\thttp\t-\t-\t-\t-\t-\tError\tHTTP/1.1\t-\t-",
+	}
+	b1 := NewBuilder(mem, false)
+	defer b1.Release()
+	b2 := NewBuilder(mem, false)
+	defer b2.Release()
+	for _, line := range lines {
+		l, err := cflogparser.ParseLineWeb(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b1.Append(l); err != nil {
+			t.Fatal(err)
+		}
+		if err := b2.AppendLine(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r1 := b1.NewRecord()
+	defer r1.Release()
+	r2 := b2.NewRecord()
+	defer r2.Release()
+	if d1, d2 := dump(r1), dump(r2); d1 != d2 {
+		t.Errorf("Append and AppendLine differ:\n%s\n%s", d1, d2)
+	}
+}
+
+func TestBatcherIPC(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	lines := readLines(t)
+	var buf bytes.Buffer
+	sw := NewStreamWriter(&buf, mem, true)
+	var sizes []int64
+	b := NewBatcher(mem, true, 4, func(rec array.Record) error {
+		sizes = append(sizes, rec.NumRows())
+		return sw.Write(rec)
+	})
+	defer b.Release()
+	for _, line := range lines {
+		if err := b.AppendLine(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes[0] != 4 || sizes[1] != 2 {
+		t.Errorf("got batches of %v rows, want [4 2]", sizes)
+	}
+
+	r, err := ipc.NewReader(&buf, ipc.WithAllocator(mem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	if !r.Schema().Equal(RTMPSchema) {
+		t.Errorf("unexpected schema: %s", r.Schema())
+	}
+	var n int64
+	for r.Next() {
+		n += r.Record().NumRows()
+	}
+	if n != int64(len(lines)) {
+		t.Errorf("read %d rows, want %d", n, len(lines))
+	}
+}
+
+func TestFileWriter(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	f, err := ioutil.TempFile("", "cflogarrow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	fw, err := NewFileWriter(f, mem, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBuilder(mem, false)
+	defer b.Release()
+	for i := 0; i < 3; i++ {
+		b.Append(&cflogparser.WebLog{URI: "/", Status: uint16(200 + i)})
+		rec := b.NewRecord()
+		if err := fw.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+		rec.Release()
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fr, err := ipc.NewFileReader(f, ipc.WithAllocator(mem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+	if fr.NumRecords() != 3 {
+		t.Fatalf("got %d batches, want 3", fr.NumRecords())
+	}
+	rec, err := fr.Record(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Column(7).(*array.Uint16).Value(0); got != 202 {
+		t.Errorf("got status %d, want 202", got)
+	}
+}
@@ -0,0 +1,87 @@
+package arrow
+
+import (
+	"io"
+
+	goarrow "github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+)
+
+// Batcher cuts records into batches of fixed number of rows, and passes
+// each batch to emit. The batch is released after emit returns, so emit
+// must Retain it to keep it.
+type Batcher struct {
+	*Builder
+	size int
+	emit func(array.Record) error
+}
+
+// NewBatcher returns a Batcher of batches of size rows.
+func NewBatcher(mem memory.Allocator, rtmp bool, size int, emit func(array.Record) error) *Batcher {
+	return &Batcher{NewBuilder(mem, rtmp), size, emit}
+}
+
+// Append appends rec, and emits a batch if it gets full.
+func (b *Batcher) Append(rec interface{}) error {
+	if err := b.Builder.Append(rec); err != nil {
+		return err
+	}
+	return b.check()
+}
+
+// AppendLine appends a line of log, and emits a batch if it gets full.
+func (b *Batcher) AppendLine(line string) error {
+	if err := b.Builder.AppendLine(line); err != nil {
+		return err
+	}
+	return b.check()
+}
+
+func (b *Batcher) check() error {
+	if b.Len() < b.size {
+		return nil
+	}
+	return b.Flush()
+}
+
+// Flush emits rows appended so far as a batch, if any.
+func (b *Batcher) Flush() error {
+	if b.Len() == 0 {
+		return nil
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+	return b.emit(rec)
+}
+
+// RecordWriter writes record batches in Arrow IPC format.
+type RecordWriter interface {
+	Write(array.Record) error
+	Close() error
+}
+
+// NewStreamWriter returns a RecordWriter writing batches in Arrow IPC
+// streaming format to w. If rtmp is true, the schema is RTMPSchema,
+// otherwise WebSchema.
+func NewStreamWriter(w io.Writer, mem memory.Allocator, rtmp bool) RecordWriter {
+	return ipc.NewWriter(w, ipc.WithSchema(schema(rtmp)), ipc.WithAllocator(mem))
+}
+
+// NewFileWriter returns a RecordWriter writing batches in Arrow IPC file
+// format, which allows random access to batches, to w.
+func NewFileWriter(w io.WriteSeeker, mem memory.Allocator, rtmp bool) (RecordWriter, error) {
+	fw, err := ipc.NewFileWriter(w, ipc.WithSchema(schema(rtmp)), ipc.WithAllocator(mem))
+	if err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+func schema(rtmp bool) *goarrow.Schema {
+	if rtmp {
+		return RTMPSchema
+	}
+	return WebSchema
+}
@@ -0,0 +1,271 @@
+package cflogparser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// WebLogAvroSchema is the Avro schema of WebLog, which is published as
+// schema/weblog.avsc. Time is in milliseconds since the Unix epoch, and
+// unsigned integers are encoded as signed ones wide enough to hold them.
+const WebLogAvroSchema = `{
+  "type": "record",
+  "name": "WebLog",
+  "namespace": "cflogparser",
+  "fields": [
+    {"name": "time", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "location", "type": "string"},
+    {"name": "bytes", "type": "long"},
+    {"name": "request_ip", "type": "string"},
+    {"name": "method", "type": "string"},
+    {"name": "host", "type": "string"},
+    {"name": "uri", "type": "string"},
+    {"name": "status", "type": "int"},
+    {"name": "referrer", "type": "string"},
+    {"name": "user_agent", "type": "string"},
+    {"name": "query_string", "type": "string"},
+    {"name": "cookie", "type": "string"},
+    {"name": "result_type", "type": "string"},
+    {"name": "request_id", "type": "string"},
+    {"name": "host_header", "type": "string"},
+    {"name": "request_protocol", "type": "string"},
+    {"name": "request_bytes", "type": "long"},
+    {"name": "time_taken", "type": "float"},
+    {"name": "xforwarded_for", "type": "string"},
+    {"name": "ssl_protocol", "type": "string"},
+    {"name": "ssl_cipher", "type": "string"},
+    {"name": "response_result_type", "type": "string"},
+    {"name": "http_version", "type": "string"},
+    {"name": "fle_status", "type": "string"},
+    {"name": "fle_encrypted_fields", "type": "long"}
+  ]
+}`
+
+// RTMPLogAvroSchema is the Avro schema of RTMPLog, which is published as
+// schema/rtmplog.avsc.
+const RTMPLogAvroSchema = `{
+  "type": "record",
+  "name": "RTMPLog",
+  "namespace": "cflogparser",
+  "fields": [
+    {"name": "time", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "location", "type": "string"},
+    {"name": "request_ip", "type": "string"},
+    {"name": "event_type", "type": "string"},
+    {"name": "bytes", "type": "long"},
+    {"name": "status", "type": "string"},
+    {"name": "client_id", "type": "string"},
+    {"name": "uri", "type": "string"},
+    {"name": "query_string", "type": "string"},
+    {"name": "referrer", "type": "string"},
+    {"name": "page_url", "type": "string"},
+    {"name": "user_agent", "type": "string"},
+    {"name": "stream_name", "type": "string"},
+    {"name": "stream_query", "type": "string"},
+    {"name": "stream_file_ext", "type": "string"},
+    {"name": "stream_id", "type": "long"}
+  ]
+}`
+
+var errAvroShort = errors.New("Unexpected end of Avro record")
+
+// AppendAvro appends l encoded in Avro binary encoding of WebLogAvroSchema
+// to b, and returns the extended buffer.
+func (l *WebLog) AppendAvro(b []byte) []byte {
+	b = appendAvroTime(b, l.Time)
+	b = appendAvroString(b, l.Location)
+	b = appendAvroLong(b, int64(l.Bytes))
+	b = appendAvroString(b, ipString(l.RequestIP))
+	b = appendAvroString(b, l.Method)
+	b = appendAvroString(b, l.Host)
+	b = appendAvroString(b, l.URI)
+	b = appendAvroLong(b, int64(l.Status))
+	b = appendAvroString(b, l.Referrer)
+	b = appendAvroString(b, l.UserAgent)
+	b = appendAvroString(b, l.QueryString)
+	b = appendAvroString(b, l.Cookie)
+	b = appendAvroString(b, l.ResultType)
+	b = appendAvroString(b, l.RequestID)
+	b = appendAvroString(b, l.HostHeader)
+	b = appendAvroString(b, l.RequestProtocol)
+	b = appendAvroLong(b, int64(l.RequestBytes))
+	b = appendAvroFloat(b, l.TimeTaken)
+	b = appendAvroString(b, l.XforwardedFor)
+	b = appendAvroString(b, l.SslProtocol)
+	b = appendAvroString(b, l.SslCipher)
+	b = appendAvroString(b, l.ResponseResultType)
+	b = appendAvroString(b, l.HTTPVersion)
+	b = appendAvroString(b, l.FleStatus)
+	b = appendAvroLong(b, int64(l.FleEncryptedFields))
+	return b
+}
+
+// UnmarshalAvro decodes b in Avro binary encoding of WebLogAvroSchema into l.
+func (l *WebLog) UnmarshalAvro(b []byte) error {
+	d := avroDecoder{b: b}
+	l.Time = d.time()
+	l.Location = d.string()
+	l.Bytes = uint64(d.long())
+	l.RequestIP = d.ip()
+	l.Method = d.string()
+	l.Host = d.string()
+	l.URI = d.string()
+	l.Status = uint16(d.long())
+	l.Referrer = d.string()
+	l.UserAgent = d.string()
+	l.QueryString = d.string()
+	l.Cookie = d.string()
+	l.ResultType = d.string()
+	l.RequestID = d.string()
+	l.HostHeader = d.string()
+	l.RequestProtocol = d.string()
+	l.RequestBytes = uint64(d.long())
+	l.TimeTaken = d.float()
+	l.XforwardedFor = d.string()
+	l.SslProtocol = d.string()
+	l.SslCipher = d.string()
+	l.ResponseResultType = d.string()
+	l.HTTPVersion = d.string()
+	l.FleStatus = d.string()
+	l.FleEncryptedFields = uint32(d.long())
+	return d.err
+}
+
+// AppendAvro appends l encoded in Avro binary encoding of RTMPLogAvroSchema
+// to b, and returns the extended buffer.
+func (l *RTMPLog) AppendAvro(b []byte) []byte {
+	b = appendAvroTime(b, l.Time)
+	b = appendAvroString(b, l.Location)
+	b = appendAvroString(b, ipString(l.RequestIP))
+	b = appendAvroString(b, l.EventType)
+	b = appendAvroLong(b, int64(l.Bytes))
+	b = appendAvroString(b, l.Status)
+	b = appendAvroString(b, l.ClientID)
+	b = appendAvroString(b, l.URI)
+	b = appendAvroString(b, l.QueryString)
+	b = appendAvroString(b, l.Referrer)
+	b = appendAvroString(b, l.PageURL)
+	b = appendAvroString(b, l.UserAgent)
+	b = appendAvroString(b, l.StreamName)
+	b = appendAvroString(b, l.StreamQuery)
+	b = appendAvroString(b, l.StreamFileExt)
+	b = appendAvroLong(b, int64(l.StreamID))
+	return b
+}
+
+// UnmarshalAvro decodes b in Avro binary encoding of RTMPLogAvroSchema into
+// l.
+func (l *RTMPLog) UnmarshalAvro(b []byte) error {
+	d := avroDecoder{b: b}
+	l.Time = d.time()
+	l.Location = d.string()
+	l.RequestIP = d.ip()
+	l.EventType = d.string()
+	l.Bytes = uint64(d.long())
+	l.Status = d.string()
+	l.ClientID = d.string()
+	l.URI = d.string()
+	l.QueryString = d.string()
+	l.Referrer = d.string()
+	l.PageURL = d.string()
+	l.UserAgent = d.string()
+	l.StreamName = d.string()
+	l.StreamQuery = d.string()
+	l.StreamFileExt = d.string()
+	l.StreamID = uint32(d.long())
+	return d.err
+}
+
+func appendAvroLong(b []byte, v int64) []byte {
+	// Zig-zag encoding in variable length
+	return binary.AppendUvarint(b, uint64(v<<1^v>>63))
+}
+
+func appendAvroString(b []byte, s string) []byte {
+	b = appendAvroLong(b, int64(len(s)))
+	return append(b, s...)
+}
+
+func appendAvroFloat(b []byte, f float32) []byte {
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(f))
+}
+
+func appendAvroTime(b []byte, t time.Time) []byte {
+	return appendAvroLong(b, t.Unix()*1000+int64(t.Nanosecond())/1e6)
+}
+
+// ipString returns ip as string, or empty string if ip is nil.
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// avroDecoder reads values from b in order. Once it fails, it keeps the
+// first error and returns zero values.
+type avroDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *avroDecoder) long() int64 {
+	if d.err != nil {
+		return 0
+	}
+	u, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errAvroShort
+		return 0
+	}
+	d.b = d.b[n:]
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (d *avroDecoder) string() string {
+	n := d.long()
+	if d.err != nil {
+		return ""
+	}
+	if n < 0 || n > int64(len(d.b)) {
+		d.err = errAvroShort
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+func (d *avroDecoder) float() float32 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.b) < 4 {
+		d.err = errAvroShort
+		return 0
+	}
+	f := math.Float32frombits(binary.LittleEndian.Uint32(d.b))
+	d.b = d.b[4:]
+	return f
+}
+
+func (d *avroDecoder) time() time.Time {
+	ms := d.long()
+	return time.Unix(ms/1000, ms%1000*1e6).UTC()
+}
+
+func (d *avroDecoder) ip() net.IP {
+	s := d.string()
+	if s == "" {
+		return nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil && d.err == nil {
+		d.err = fmt.Errorf("Invalid IP address: %s", s)
+	}
+	return ip
+}
@@ -0,0 +1,95 @@
+package cflogparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestAvroSchemaFiles(t *testing.T) {
+	tests := []struct {
+		file   string
+		schema string
+		fields []Field
+	}{
+		{"schema/weblog.avsc", WebLogAvroSchema, WebLogFields},
+		{"schema/rtmplog.avsc", RTMPLogAvroSchema, RTMPLogFields},
+	}
+	for _, tt := range tests {
+		b, err := ioutil.ReadFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(b)) != tt.schema {
+			t.Errorf("%s differs from the schema in code", tt.file)
+		}
+		var s struct {
+			Fields []struct {
+				Name string `json:"name"`
+			} `json:"fields"`
+		}
+		if err := json.Unmarshal([]byte(tt.schema), &s); err != nil {
+			t.Fatalf("%s: %s", tt.file, err)
+		}
+		if len(s.Fields) != len(tt.fields) {
+			t.Fatalf("%s: got %d fields, want %d", tt.file, len(s.Fields), len(tt.fields))
+		}
+		for i, f := range tt.fields {
+			if s.Fields[i].Name != f.Name {
+				t.Errorf("%s: field %d is %s, want %s", tt.file, i, s.Fields[i].Name, f.Name)
+			}
+		}
+	}
+}
+
+func TestAvroEncoding(t *testing.T) {
+	tests := []struct {
+		v   int64
+		out []byte
+	}{
+		{0, []byte{0x00}},
+		{-1, []byte{0x01}},
+		{1, []byte{0x02}},
+		{-64, []byte{0x7f}},
+		{64, []byte{0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		if got := appendAvroLong(nil, tt.v); !bytes.Equal(got, tt.out) {
+			t.Errorf("appendAvroLong(%d) = % x, want % x", tt.v, got, tt.out)
+		}
+	}
+	if got := appendAvroString(nil, "foo"); !bytes.Equal(got, []byte{0x06, 'f', 'o', 'o'}) {
+		t.Errorf("appendAvroString(foo) = % x", got)
+	}
+}
+
+func TestAvroRoundTrip(t *testing.T) {
+	for i, want := range codecRecords(t) {
+		var got interface{}
+		var err error
+		switch l := want.(type) {
+		case *WebLog:
+			b := l.AppendAvro(nil)
+			g := &WebLog{}
+			err, got = g.UnmarshalAvro(b), g
+			if (&WebLog{}).UnmarshalAvro(b[:len(b)-1]) == nil {
+				t.Errorf("record %d: expected error for truncated record", i)
+			}
+		case *RTMPLog:
+			b := l.AppendAvro(nil)
+			g := &RTMPLog{}
+			err, got = g.UnmarshalAvro(b), g
+			if (&RTMPLog{}).UnmarshalAvro(b[:len(b)-1]) == nil {
+				t.Errorf("record %d: expected error for truncated record", i)
+			}
+		}
+		if err != nil {
+			t.Fatalf("record %d: %s", i, err)
+		}
+		if g, w := jsonOf(t, got), jsonOf(t, want); g != w {
+			t.Errorf("record %d:\ngot  %s\nwant %s", i, g, w)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package cflogparser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encodings lists binary encodings supported by BinaryWriter and
+// BinaryReader.
+var Encodings = []string{"avro", "protobuf"}
+
+// DefaultMaxRecordSize is the default of BinaryReader.MaxRecordSize.
+const DefaultMaxRecordSize = 4 << 20
+
+var errBinaryShort = errors.New("Unexpected end of binary record")
+
+// BinaryWriter writes records in Avro binary encoding or as Protocol
+// Buffers messages. Each record is framed by its length in unsigned varint,
+// as Java's writeDelimitedTo of Protocol Buffers does, so that a stream of
+// records can be split again by BinaryReader.
+//
+// If SchemaID is positive, each record starts with the header of the wire
+// format of Confluent Schema Registry: magic byte 0 and 4-byte schema ID in
+// big endian, followed by message indexes for Protocol Buffers. Then a
+// record can be produced to Kafka as it is, after the length is stripped.
+//
+// Output is buffered, so Flush must be called at the end.
+type BinaryWriter struct {
+	SchemaID int
+
+	proto bool
+	w     *bufio.Writer
+	buf   []byte
+}
+
+// NewBinaryWriter returns a BinaryWriter writing records to w in encoding,
+// which is one of Encodings.
+func NewBinaryWriter(w io.Writer, encoding string) (*BinaryWriter, error) {
+	bw := &BinaryWriter{w: bufio.NewWriter(w)}
+	switch encoding {
+	case "avro":
+	case "protobuf":
+		bw.proto = true
+	default:
+		return nil, fmt.Errorf("Unknown encoding: %s", encoding)
+	}
+	return bw, nil
+}
+
+// Write writes rec, which must be *WebLog or *RTMPLog.
+func (bw *BinaryWriter) Write(rec interface{}) error {
+	b := bw.buf[:0]
+	if bw.SchemaID > 0 {
+		b = append(b, 0)
+		b = binary.BigEndian.AppendUint32(b, uint32(bw.SchemaID))
+	}
+	switch l := rec.(type) {
+	case *WebLog:
+		if bw.proto {
+			if bw.SchemaID > 0 {
+				// Message indexes [0], i.e. the first message in the
+				// schema, are encoded in a single 0.
+				b = append(b, 0)
+			}
+			b = l.AppendProto(b)
+		} else {
+			b = l.AppendAvro(b)
+		}
+	case *RTMPLog:
+		if bw.proto {
+			if bw.SchemaID > 0 {
+				// Message indexes [1]: count 1 and index 1 in zig-zag.
+				b = append(b, 2, 2)
+			}
+			b = l.AppendProto(b)
+		} else {
+			b = l.AppendAvro(b)
+		}
+	default:
+		return fmt.Errorf("Unexpected record type: %T", rec)
+	}
+	bw.buf = b
+	var n [binary.MaxVarintLen64]byte
+	if _, err := bw.w.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))]); err != nil {
+		return err
+	}
+	_, err := bw.w.Write(b)
+	return err
+}
+
+// Flush writes buffered records to the underlying writer.
+func (bw *BinaryWriter) Flush() error {
+	return bw.w.Flush()
+}
+
+// BinaryReader reads records written by BinaryWriter. Records are returned
+// as *WebLog or *RTMPLog.
+type BinaryReader struct {
+	// SchemaID is the schema ID of the record last read, if it has the
+	// header of Confluent Schema Registry, otherwise 0.
+	SchemaID int
+	// MaxRecordSize is the maximum length of a record, to fail on corrupt
+	// input rather than to allocate a huge buffer. It is
+	// DefaultMaxRecordSize if zero.
+	MaxRecordSize int
+
+	proto bool
+	rtmp  bool
+	r     *bufio.Reader
+	buf   []byte
+}
+
+// NewBinaryReader returns a BinaryReader reading records in encoding from
+// r. If rtmp is true, records are decoded as RTMPLog, otherwise as WebLog.
+func NewBinaryReader(r io.Reader, encoding string, rtmp bool) (*BinaryReader, error) {
+	br := &BinaryReader{rtmp: rtmp, r: bufio.NewReader(r)}
+	switch encoding {
+	case "avro":
+	case "protobuf":
+		br.proto = true
+	default:
+		return nil, fmt.Errorf("Unknown encoding: %s", encoding)
+	}
+	return br, nil
+}
+
+// Read returns the next record. It returns io.EOF at the end of input.
+//
+// Whether a record has the header of Confluent Schema Registry is told by
+// its first byte, because a record without it can't start with 0: the
+// first field of Avro is time, which is never zero for a log, and a
+// Protocol Buffers message never has a tag of 0.
+func (br *BinaryReader) Read() (interface{}, error) {
+	n, err := binary.ReadUvarint(br.r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, errBinaryShort
+	}
+	max := br.MaxRecordSize
+	if max <= 0 {
+		max = DefaultMaxRecordSize
+	}
+	if n > uint64(max) {
+		return nil, fmt.Errorf("Too large binary record: %d bytes", n)
+	}
+	if uint64(cap(br.buf)) < n {
+		br.buf = make([]byte, n)
+	}
+	b := br.buf[:n]
+	if _, err := io.ReadFull(br.r, b); err != nil {
+		return nil, errBinaryShort
+	}
+	br.SchemaID = 0
+	if len(b) >= 5 && b[0] == 0 {
+		br.SchemaID = int(binary.BigEndian.Uint32(b[1:]))
+		b = b[5:]
+		if br.proto {
+			// Message indexes are count followed by indexes, in zig-zag
+			// varint. A single 0 stands for [0]. They must point WebLog
+			// or RTMPLog, which are the first and second messages.
+			d := avroDecoder{b: b}
+			idx := []int64{0}
+			n := d.long()
+			if n < 0 || n > int64(len(b)) {
+				return nil, fmt.Errorf("Invalid count of message indexes: %d", n)
+			}
+			if n > 0 {
+				idx = make([]int64, n)
+				for i := range idx {
+					idx[i] = d.long()
+				}
+			}
+			if d.err != nil {
+				return nil, d.err
+			}
+			want := int64(0)
+			if br.rtmp {
+				want = 1
+			}
+			if len(idx) != 1 || idx[0] != want {
+				return nil, fmt.Errorf("Unexpected message indexes: %v", idx)
+			}
+			b = d.b
+		}
+	}
+	if br.rtmp {
+		l := &RTMPLog{}
+		if br.proto {
+			err = l.UnmarshalProto(b)
+		} else {
+			err = l.UnmarshalAvro(b)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	l := &WebLog{}
+	if br.proto {
+		err = l.UnmarshalProto(b)
+	} else {
+		err = l.UnmarshalAvro(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,147 @@
+package cflogparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+// codecRecords returns records with various values to test encodings.
+func codecRecords(t *testing.T) []interface{} {
+	t.Helper()
+	full := "2014-05-23\t01:13:11\tFRA2\t182\t2001:db8::1\tGET\td111111abcdef8.cloudfront.net\t/view/my/file.html\t200\twww.displaymyfiles.com\tMozilla/4.0%20(compatible;%20MSIE%205.0b1;%20Mac_PowerPC)\ta=1\tzip=98101\tRefreshHit\tMRVMF7KydIvxMWfJIglgwHQwZsbG2IhRJ07sn9AkKUFSHS9EXAMPLE==\td111111abcdef8.cloudfront.net\thttps\t12345678901\t0.001\t192.0.2.1\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\tRefreshHit\tHTTP/2.0\tProcessed\t4294967295"
+	var recs []interface{}
+	for _, line := range []string{full, webLine("23:59:59", "/caf%C3%A9")} {
+		l, err := ParseLineWeb(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, l)
+	}
+	for _, l := range readRTMPLogs(t, "testdata/sample-rtmp.log") {
+		recs = append(recs, l)
+	}
+	return recs
+}
+
+// jsonOf returns rec in JSON to compare records by their JSON tags.
+func jsonOf(t *testing.T, rec interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestBinaryWriterReader(t *testing.T) {
+	var web, rtmp []interface{}
+	for _, rec := range codecRecords(t) {
+		if _, ok := rec.(*RTMPLog); ok {
+			rtmp = append(rtmp, rec)
+		} else {
+			web = append(web, rec)
+		}
+	}
+	for _, enc := range Encodings {
+		for _, id := range []int{0, 42} {
+			testBinaryWriterReader(t, enc, id, false, web)
+			testBinaryWriterReader(t, enc, id, true, rtmp)
+		}
+	}
+}
+
+func testBinaryWriterReader(t *testing.T, enc string, id int, rtmp bool, recs []interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewBinaryWriter(&buf, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SchemaID = id
+	for _, rec := range recs {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, n := binary.Uvarint(buf.Bytes()); id > 0 && !bytes.HasPrefix(buf.Bytes()[n:], []byte{0, 0, 0, 0, byte(id)}) {
+		t.Errorf("%s: no header of schema registry: % x", enc, buf.Bytes()[:8])
+	}
+
+	r, err := NewBinaryReader(&buf, enc, rtmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range recs {
+		got, err := r.Read()
+		if err != nil {
+			t.Fatalf("%s: record %d: %s", enc, i, err)
+		}
+		if g, w := jsonOf(t, got), jsonOf(t, want); g != w {
+			t.Errorf("%s: record %d:\ngot  %s\nwant %s", enc, i, g, w)
+		}
+		if r.SchemaID != id {
+			t.Errorf("%s: got schema ID %d, want %d", enc, r.SchemaID, id)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("%s: expected EOF, got %v", enc, err)
+	}
+}
+
+func TestBinaryWriterMessageIndexes(t *testing.T) {
+	recs := codecRecords(t)
+	tests := []struct {
+		rec  interface{}
+		want []byte
+	}{
+		{recs[0], []byte{0, 0, 0, 0, 9, 0}},
+		{recs[len(recs)-1], []byte{0, 0, 0, 0, 9, 2, 2}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w, _ := NewBinaryWriter(&buf, "protobuf")
+		w.SchemaID = 9
+		w.Write(tt.rec)
+		w.Flush()
+		_, n := binary.Uvarint(buf.Bytes())
+		if b := buf.Bytes()[n:]; !bytes.HasPrefix(b, tt.want) || b[len(tt.want)] == 0 {
+			t.Errorf("%T: got header % x, want % x", tt.rec, b[:len(tt.want)+1], tt.want)
+		}
+		// Records of the other message are rejected.
+		_, isRTMP := tt.rec.(*RTMPLog)
+		r, _ := NewBinaryReader(bytes.NewReader(buf.Bytes()), "protobuf", !isRTMP)
+		if _, err := r.Read(); err == nil {
+			t.Errorf("%T: expected error for wrong message index", tt.rec)
+		}
+	}
+}
+
+func TestBinaryReaderTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewBinaryWriter(&buf, "avro")
+	w.Write(codecRecords(t)[0])
+	w.Flush()
+	r, _ := NewBinaryReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), "avro", false)
+	if _, err := r.Read(); err == nil || err == io.EOF {
+		t.Errorf("expected error for truncated record, got %v", err)
+	}
+	// Not framed by length
+	for _, b := range [][]byte{
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		{0xff, 0xff, 0xff, 0x7f},
+	} {
+		r, _ = NewBinaryReader(bytes.NewReader(b), "protobuf", false)
+		if _, err := r.Read(); err == nil || err == io.EOF {
+			t.Errorf("expected error for % x, got %v", b, err)
+		}
+	}
+	if _, err := NewBinaryWriter(&buf, "thrift"); err == nil {
+		t.Error("expected error for unknown encoding")
+	}
+}
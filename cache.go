@@ -0,0 +1,214 @@
+package cflogparser
+
+import (
+	"mime"
+	"path"
+	"sort"
+	"strings"
+)
+
+// CacheStats holds request and byte counts of Web distribution logs split
+// by x-edge-result-type (ResultType). Hit, RefreshHit and Miss are regarded
+// as cacheable; other result types such as Error or Redirect are counted
+// only in Requests and Bytes.
+type CacheStats struct {
+	Requests        uint64 `json:"requests"`
+	Bytes           uint64 `json:"bytes"`
+	Hit             uint64 `json:"hit"`
+	HitBytes        uint64 `json:"hit_bytes"`
+	RefreshHit      uint64 `json:"refresh_hit"`
+	RefreshHitBytes uint64 `json:"refresh_hit_bytes"`
+	Miss            uint64 `json:"miss"`
+	MissBytes       uint64 `json:"miss_bytes"`
+}
+
+// Add counts l.
+func (s *CacheStats) Add(l *WebLog) {
+	s.Requests++
+	s.Bytes += l.Bytes
+	switch l.ResultType {
+	case "Hit":
+		s.Hit++
+		s.HitBytes += l.Bytes
+	case "RefreshHit":
+		s.RefreshHit++
+		s.RefreshHitBytes += l.Bytes
+	case "Miss":
+		s.Miss++
+		s.MissBytes += l.Bytes
+	}
+}
+
+// HitRatio returns the ratio of Hit to cacheable requests.
+func (s *CacheStats) HitRatio() float64 {
+	return ratio(s.Hit, s.Hit+s.RefreshHit+s.Miss)
+}
+
+// ByteHitRatio returns the ratio of bytes served by Hit to bytes of
+// cacheable requests.
+func (s *CacheStats) ByteHitRatio() float64 {
+	return ratio(s.HitBytes, s.HitBytes+s.RefreshHitBytes+s.MissBytes)
+}
+
+func ratio(n, d uint64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// CacheGroup is CacheStats of a group of requests, such as URI prefix.
+type CacheGroup struct {
+	Name string `json:"name"`
+	CacheStats
+}
+
+// FragmentedURI is a URI whose cache is fragmented by query strings.
+type FragmentedURI struct {
+	URI             string `json:"uri"`
+	Misses          uint64 `json:"misses"`
+	DistinctQueries uint64 `json:"distinct_queries"`
+}
+
+// CacheReport aggregates WebLogs to analyze cache efficiency by URI prefix,
+// content type and edge location. It also finds URIs causing most Miss and
+// RefreshHit, and URIs whose misses are spread over many query strings,
+// in bounded memory: query strings are counted only for URIs tracked by
+// the TopK of misses with query strings, and forgotten when a URI is
+// evicted from it.
+type CacheReport struct {
+	Total        CacheStats
+	prefixDepth  int
+	prefixes     map[string]*CacheStats
+	contentTypes map[string]*CacheStats
+	locations    map[string]*CacheStats
+	missURIs     *TopK
+	queryURIs    *TopK
+	queries      *DistinctCounter
+}
+
+// NewCacheReport returns an empty CacheReport. URIs are grouped by their
+// first prefixDepth path segments, e.g. "/images/" for depth 1.
+func NewCacheReport(prefixDepth int) *CacheReport {
+	qs, _ := NewDistinctCounter(8)
+	return &CacheReport{
+		prefixDepth:  prefixDepth,
+		prefixes:     map[string]*CacheStats{},
+		contentTypes: map[string]*CacheStats{},
+		locations:    map[string]*CacheStats{},
+		missURIs:     NewTopK(10000),
+		queryURIs:    NewTopK(10000),
+		queries:      qs,
+	}
+}
+
+// Add counts l.
+func (r *CacheReport) Add(l *WebLog) {
+	r.Total.Add(l)
+	addCacheStats(r.prefixes, URIPrefix(l.URI, r.prefixDepth), l)
+	addCacheStats(r.contentTypes, ContentType(l.URI), l)
+	addCacheStats(r.locations, l.Location, l)
+	if l.ResultType == "Miss" || l.ResultType == "RefreshHit" {
+		r.missURIs.Add(l.URI, 1)
+		if l.QueryString != "" {
+			if evicted, ok := r.queryURIs.add(l.URI, 1); ok {
+				delete(r.queries.groups, evicted)
+			}
+			r.queries.Add(l.URI, l.QueryString)
+		}
+	}
+}
+
+func addCacheStats(m map[string]*CacheStats, key string, l *WebLog) {
+	s := m[key]
+	if s == nil {
+		s = &CacheStats{}
+		m[key] = s
+	}
+	s.Add(l)
+}
+
+// ByPrefix returns stats per URI prefix in descending order of requests.
+func (r *CacheReport) ByPrefix() []CacheGroup {
+	return sortCacheGroups(r.prefixes)
+}
+
+// ByContentType returns stats per content type in descending order of
+// requests.
+func (r *CacheReport) ByContentType() []CacheGroup {
+	return sortCacheGroups(r.contentTypes)
+}
+
+// ByLocation returns stats per edge location in descending order of
+// requests.
+func (r *CacheReport) ByLocation() []CacheGroup {
+	return sortCacheGroups(r.locations)
+}
+
+func sortCacheGroups(m map[string]*CacheStats) []CacheGroup {
+	gs := make([]CacheGroup, 0, len(m))
+	for k, s := range m {
+		gs = append(gs, CacheGroup{Name: k, CacheStats: *s})
+	}
+	sort.Slice(gs, func(i, j int) bool {
+		if gs[i].Requests != gs[j].Requests {
+			return gs[i].Requests > gs[j].Requests
+		}
+		return gs[i].Name < gs[j].Name
+	})
+	return gs
+}
+
+// MissURIs returns top n URIs causing Miss or RefreshHit.
+func (r *CacheReport) MissURIs(n int) []TopKEntry {
+	return r.missURIs.Top(n)
+}
+
+// FragmentedURIs returns at most n URIs that missed with more than one
+// distinct query string, in descending order of the number of distinct
+// query strings. The numbers are estimated with HyperLogLog, and those of
+// URIs once evicted from the TopK only count query strings since they came
+// back.
+func (r *CacheReport) FragmentedURIs(n int) []FragmentedURI {
+	var fs []FragmentedURI
+	for _, e := range r.queryURIs.Top(r.queryURIs.capacity) {
+		if d := r.queries.Count(e.Key); d > 1 {
+			fs = append(fs, FragmentedURI{URI: e.Key, Misses: e.Count, DistinctQueries: d})
+		}
+	}
+	sort.SliceStable(fs, func(i, j int) bool { return fs[i].DistinctQueries > fs[j].DistinctQueries })
+	if n < len(fs) {
+		fs = fs[:n]
+	}
+	return fs
+}
+
+// URIPrefix returns the first depth segments of uri followed by "/".
+// If uri has depth or fewer segments, it returns the directory part of uri.
+func URIPrefix(uri string, depth int) string {
+	if depth <= 0 || uri == "" {
+		return "/"
+	}
+	i := 0
+	for d := 0; d < depth; d++ {
+		j := strings.IndexByte(uri[i+1:], '/')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	return uri[:i+1]
+}
+
+// ContentType guesses content type of uri from its extension.
+// It returns "unknown" if the extension is not registered.
+func ContentType(uri string) string {
+	t := mime.TypeByExtension(path.Ext(uri))
+	if t == "" {
+		return "unknown"
+	}
+	if i := strings.IndexByte(t, ';'); i >= 0 {
+		t = t[:i]
+	}
+	return t
+}
@@ -0,0 +1,101 @@
+package cflogparser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestURIPrefix(t *testing.T) {
+	tests := []struct {
+		uri   string
+		depth int
+		out   string
+	}{
+		{"/view/my/file.html", 0, "/"},
+		{"/view/my/file.html", 1, "/view/"},
+		{"/view/my/file.html", 2, "/view/my/"},
+		{"/view/my/file.html", 3, "/view/my/"},
+		{"/file.html", 1, "/"},
+		{"/", 1, "/"},
+		{"", 1, "/"},
+	}
+	for _, test := range tests {
+		if p := URIPrefix(test.uri, test.depth); p != test.out {
+			t.Errorf("URIPrefix(%q, %d): got %q, want %q", test.uri, test.depth, p, test.out)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		uri string
+		out string
+	}{
+		{"/view/my/file.html", "text/html"},
+		{"/images/logo.png", "image/png"},
+		{"/api/v1/users", "unknown"},
+	}
+	for _, test := range tests {
+		if c := ContentType(test.uri); c != test.out {
+			t.Errorf("ContentType(%q): got %q, want %q", test.uri, c, test.out)
+		}
+	}
+}
+
+func TestCacheReport(t *testing.T) {
+	r := NewCacheReport(1)
+	add := func(loc, uri, query, result string, bytes uint64) {
+		r.Add(&WebLog{Location: loc, URI: uri, QueryString: query, ResultType: result, Bytes: bytes})
+	}
+	for i := 0; i < 6; i++ {
+		add("NRT20", "/images/logo.png", "", "Hit", 1000)
+	}
+	add("NRT20", "/images/logo.png", "", "Miss", 1000)
+	add("FRA2", "/images/logo.png", "", "RefreshHit", 1000)
+	for i := 0; i < 5; i++ {
+		add("FRA2", "/search.html", fmt.Sprintf("q=%d", i), "Miss", 100)
+	}
+	add("FRA2", "/search.html", "", "Error", 10)
+
+	if r.Total.Requests != 14 || r.Total.Bytes != 8510 {
+		t.Errorf("got %d requests %d bytes, want 14 and 8510", r.Total.Requests, r.Total.Bytes)
+	}
+	if h := r.Total.HitRatio(); h != 6.0/13 {
+		t.Errorf("got hit ratio %f, want %f", h, 6.0/13)
+	}
+	if h := r.Total.ByteHitRatio(); h != 6000.0/8500 {
+		t.Errorf("got byte hit ratio %f, want %f", h, 6000.0/8500)
+	}
+
+	ps := r.ByPrefix()
+	if len(ps) != 2 || ps[0].Name != "/images/" || ps[0].Requests != 8 || ps[1].Name != "/" {
+		t.Errorf("unexpected prefixes: %+v", ps)
+	}
+	cs := r.ByContentType()
+	if len(cs) != 2 || cs[0].Name != "image/png" || cs[1].Name != "text/html" || cs[1].HitRatio() != 0 {
+		t.Errorf("unexpected content types: %+v", cs)
+	}
+	ls := r.ByLocation()
+	if len(ls) != 2 || ls[0].Name != "FRA2" || ls[0].Miss != 5 || ls[1].Hit != 6 {
+		t.Errorf("unexpected locations: %+v", ls)
+	}
+
+	ms := r.MissURIs(10)
+	if len(ms) != 2 || ms[0].Key != "/search.html" || ms[0].Count != 5 || ms[1].Count != 2 {
+		t.Errorf("unexpected miss URIs: %+v", ms)
+	}
+	fs := r.FragmentedURIs(10)
+	if len(fs) != 1 || fs[0].URI != "/search.html" || fs[0].Misses != 5 || fs[0].DistinctQueries != 5 {
+		t.Errorf("unexpected fragmented URIs: %+v", fs)
+	}
+}
+
+func TestCacheReportBounded(t *testing.T) {
+	r := NewCacheReport(1)
+	for i := 0; i < 30000; i++ {
+		r.Add(&WebLog{URI: fmt.Sprintf("/u/%d", i), QueryString: "v=1", ResultType: "Miss"})
+	}
+	if n := len(r.queries.groups); n != r.queryURIs.capacity {
+		t.Errorf("got %d HyperLogLogs, want %d", n, r.queryURIs.capacity)
+	}
+}
@@ -0,0 +1,154 @@
+package cflogparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// TableDialects lists dialects supported by CreateTable:
+//
+//	athena-tsv      Athena/Hive external table of raw log files in TSV
+//	athena-parquet  Athena/Hive external table of Parquet files written by
+//	                the parquet package, partitioned by date and distribution
+//	bigquery        BigQuery schema in JSON
+//	clickhouse      ClickHouse MergeTree table partitioned by date
+var TableDialects = []string{"athena-tsv", "athena-parquet", "bigquery", "clickhouse"}
+
+// TableOptions configures CreateTable.
+type TableOptions struct {
+	Name     string // Name of table; "cloudfront_logs" if empty
+	Location string // Location of data, e.g. "s3://bucket/prefix/", required by Athena
+	RTMP     bool   // Define table of RTMPLog instead of WebLog
+}
+
+// column is a column of table in a dialect.
+type column struct {
+	name, typ string
+}
+
+// CreateTable returns a definition of table of WebLog or RTMPLog in dialect,
+// which is one of TableDialects. Columns are generated from WebLogFields or
+// RTMPLogFields, so they follow the struct and ParseLineWeb and
+// ParseLineRTMP.
+//
+// Columns of the raw log are URL-encoded and "-" for empty, as CloudFront
+// writes them, while those of the other dialects hold decoded values.
+func CreateTable(dialect string, opts TableOptions) (string, error) {
+	if opts.Name == "" {
+		opts.Name = "cloudfront_logs"
+	}
+	fields := WebLogFields
+	if opts.RTMP {
+		fields = RTMPLogFields
+	}
+	switch dialect {
+	case "athena-tsv", "athena-parquet":
+		if opts.Location == "" {
+			return "", fmt.Errorf("Location is required for %s", dialect)
+		}
+	case "bigquery", "clickhouse":
+	default:
+		return "", fmt.Errorf("Unknown dialect: %s", dialect)
+	}
+
+	var cols []column
+	for i, f := range fields {
+		if i == 0 && dialect == "athena-tsv" {
+			// Date and time are split in two columns in the raw log.
+			cols = append(cols, column{"date", "DATE"}, column{"time", "STRING"})
+			continue
+		}
+		cols = append(cols, column{f.Name, columnType(dialect, f.Type)})
+	}
+
+	var b strings.Builder
+	switch dialect {
+	case "athena-tsv":
+		writeColumns(&b, "CREATE EXTERNAL TABLE IF NOT EXISTS `"+opts.Name+"` (", cols)
+		b.WriteString("ROW FORMAT DELIMITED\n")
+		b.WriteString("FIELDS TERMINATED BY '\\t'\n")
+		fmt.Fprintf(&b, "LOCATION '%s'\n", opts.Location)
+		// Skip "#Version" and "#Fields" lines
+		b.WriteString("TBLPROPERTIES ('skip.header.line.count'='2');\n")
+	case "athena-parquet":
+		writeColumns(&b, "CREATE EXTERNAL TABLE IF NOT EXISTS `"+opts.Name+"` (", cols)
+		b.WriteString("PARTITIONED BY (`date` STRING, `distribution` STRING)\n")
+		b.WriteString("STORED AS PARQUET\n")
+		fmt.Fprintf(&b, "LOCATION '%s';\n", opts.Location)
+	case "bigquery":
+		type bqField struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+			Mode string `json:"mode"`
+		}
+		bq := make([]bqField, len(cols))
+		for i, c := range cols {
+			bq[i] = bqField{c.name, c.typ, "NULLABLE"}
+		}
+		j, err := json.MarshalIndent(bq, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		b.Write(j)
+		b.WriteString("\n")
+	case "clickhouse":
+		writeColumns(&b, "CREATE TABLE IF NOT EXISTS `"+opts.Name+"` (", cols)
+		b.WriteString("ENGINE = MergeTree\n")
+		b.WriteString("PARTITION BY toDate(time)\n")
+		b.WriteString("ORDER BY time;\n")
+	}
+	return b.String(), nil
+}
+
+// writeColumns writes head and the list of columns in parentheses, quoting
+// names by backticks, which both Hive and ClickHouse accept.
+func writeColumns(b *strings.Builder, head string, cols []column) {
+	b.WriteString(head)
+	b.WriteString("\n")
+	for i, c := range cols {
+		fmt.Fprintf(b, "  `%s` %s", c.name, c.typ)
+		if i < len(cols)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(")\n")
+}
+
+// columnType returns the type of column for a field of type t in dialect.
+// Unsigned integers are mapped into signed types wide enough to hold them
+// if the dialect doesn't have unsigned ones.
+func columnType(dialect string, t reflect.Type) string {
+	var names [6]string // time, string, uint16, uint32, uint64, float32
+	switch dialect {
+	case "athena-tsv", "athena-parquet":
+		names = [...]string{"TIMESTAMP", "STRING", "INT", "BIGINT", "BIGINT", "FLOAT"}
+	case "bigquery":
+		names = [...]string{"TIMESTAMP", "STRING", "INTEGER", "INTEGER", "INTEGER", "FLOAT"}
+	case "clickhouse":
+		names = [...]string{"DateTime('UTC')", "String", "UInt16", "UInt32", "UInt64", "Float32"}
+	}
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return names[0]
+	case reflect.TypeOf(net.IP{}):
+		return names[1]
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return names[1]
+	case reflect.Uint16:
+		return names[2]
+	case reflect.Uint32:
+		return names[3]
+	case reflect.Uint64:
+		return names[4]
+	case reflect.Float32:
+		return names[5]
+	}
+	panic("unsupported field type: " + t.String())
+}
@@ -0,0 +1,127 @@
+package cflogparser
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// columnLines returns lines of column definitions in ddl.
+func columnLines(ddl string) []string {
+	var cols []string
+	for _, line := range strings.Split(ddl, "\n") {
+		if strings.HasPrefix(line, "  `") {
+			cols = append(cols, strings.TrimSuffix(strings.TrimSpace(line), ","))
+		}
+	}
+	return cols
+}
+
+func TestCreateTableAthenaTSV(t *testing.T) {
+	opts := TableOptions{Location: "s3://bucket/logs/"}
+	for _, rtmp := range []bool{false, true} {
+		opts.RTMP = rtmp
+		ddl, err := CreateTable("athena-tsv", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Columns must match fields of the raw log.
+		var rec interface{} = &WebLog{}
+		if rtmp {
+			rec = &RTMPLog{}
+		}
+		cols := columnLines(ddl)
+		if n := len(strings.Split(FormatLine(rec), "\t")); len(cols) != n {
+			t.Errorf("rtmp=%v: got %d columns, want %d", rtmp, len(cols), n)
+		}
+		if cols[0] != "`date` DATE" || cols[1] != "`time` STRING" {
+			t.Errorf("unexpected date and time: %v", cols[:2])
+		}
+		if !strings.Contains(ddl, "LOCATION 's3://bucket/logs/'") || !strings.Contains(ddl, "FIELDS TERMINATED BY '\\t'") {
+			t.Errorf("unexpected DDL:\n%s", ddl)
+		}
+	}
+}
+
+func TestCreateTableAthenaParquet(t *testing.T) {
+	ddl, err := CreateTable("athena-parquet", TableOptions{Name: "web", Location: "s3://bucket/parquet/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := columnLines(ddl)
+	if len(cols) != len(WebLogFields) {
+		t.Fatalf("got %d columns, want %d", len(cols), len(WebLogFields))
+	}
+	for _, want := range []string{"`time` TIMESTAMP", "`status` INT", "`bytes` BIGINT", "`time_taken` FLOAT", "`fle_encrypted_fields` BIGINT"} {
+		if !strings.Contains(ddl, "\n  "+want) {
+			t.Errorf("missing column %s", want)
+		}
+	}
+	for _, want := range []string{"CREATE EXTERNAL TABLE IF NOT EXISTS `web` (", "PARTITIONED BY (`date` STRING, `distribution` STRING)", "STORED AS PARQUET"} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("missing %q in:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestCreateTableBigQuery(t *testing.T) {
+	out, err := CreateTable("bigquery", TableOptions{RTMP: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema []struct{ Name, Type, Mode string }
+	if err := json.Unmarshal([]byte(out), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if len(schema) != len(RTMPLogFields) {
+		t.Fatalf("got %d fields, want %d", len(schema), len(RTMPLogFields))
+	}
+	for i, f := range RTMPLogFields {
+		if schema[i].Name != f.Name {
+			t.Errorf("field %d is %s, want %s", i, schema[i].Name, f.Name)
+		}
+	}
+	if schema[0].Type != "TIMESTAMP" || schema[4].Type != "INTEGER" || schema[2].Type != "STRING" {
+		t.Errorf("unexpected types: %+v", schema[:5])
+	}
+}
+
+func TestCreateTableClickHouse(t *testing.T) {
+	ddl, err := CreateTable("clickhouse", TableOptions{Name: "rtmp", RTMP: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CREATE TABLE IF NOT EXISTS `rtmp` (\n" +
+		"  `time` DateTime('UTC'),\n" +
+		"  `location` String,\n" +
+		"  `request_ip` String,\n" +
+		"  `event_type` String,\n" +
+		"  `bytes` UInt64,\n" +
+		"  `status` String,\n" +
+		"  `client_id` String,\n" +
+		"  `uri` String,\n" +
+		"  `query_string` String,\n" +
+		"  `referrer` String,\n" +
+		"  `page_url` String,\n" +
+		"  `user_agent` String,\n" +
+		"  `stream_name` String,\n" +
+		"  `stream_query` String,\n" +
+		"  `stream_file_ext` String,\n" +
+		"  `stream_id` UInt32\n" +
+		")\n" +
+		"ENGINE = MergeTree\n" +
+		"PARTITION BY toDate(time)\n" +
+		"ORDER BY time;\n"
+	if ddl != want {
+		t.Errorf("got:\n%s\nwant:\n%s", ddl, want)
+	}
+}
+
+func TestCreateTableErrors(t *testing.T) {
+	if _, err := CreateTable("mysql", TableOptions{}); err == nil {
+		t.Error("expected error for unknown dialect")
+	}
+	if _, err := CreateTable("athena-tsv", TableOptions{}); err == nil {
+		t.Error("expected error for missing location")
+	}
+}
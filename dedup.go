@@ -0,0 +1,155 @@
+package cflogparser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DedupKey returns a key identifying rec, which must be *WebLog or *RTMPLog.
+// It is RequestID for WebLog. RTMPLog has no unique ID, so the key is
+// composed of time, client ID, event type, stream ID, stream name and bytes,
+// which identify an event in a connection. It returns "" if rec has no key.
+func DedupKey(rec interface{}) string {
+	switch l := rec.(type) {
+	case *WebLog:
+		return l.RequestID
+	case *RTMPLog:
+		return strings.Join([]string{
+			l.Time.Format(time.RFC3339),
+			l.ClientID,
+			l.EventType,
+			strconv.FormatUint(uint64(l.StreamID), 10),
+			l.StreamName,
+			strconv.FormatUint(l.Bytes, 10),
+		}, "\t")
+	}
+	return ""
+}
+
+// KeySet is a set of keys seen so far, used by Dedup.
+type KeySet interface {
+	// TestAndAdd adds key to the set, and reports whether it was already
+	// in the set.
+	TestAndAdd(key string) bool
+}
+
+// ExactSet is a KeySet that remembers every key. It never reports a false
+// duplicate, but its memory grows with the number of keys.
+type ExactSet map[string]struct{}
+
+// NewExactSet returns an empty ExactSet.
+func NewExactSet() ExactSet {
+	return ExactSet{}
+}
+
+// TestAndAdd implements KeySet.
+func (s ExactSet) TestAndAdd(key string) bool {
+	if _, ok := s[key]; ok {
+		return true
+	}
+	s[key] = struct{}{}
+	return false
+}
+
+// BloomFilter is a KeySet of bounded memory. It may report a key that was
+// not added as a duplicate at the configured false positive rate, but never
+// misses a key that was added.
+type BloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    int
+}
+
+// NewBloomFilter returns a BloomFilter sized for n keys with false positive
+// rate fpRate. The rate gets higher if more than n keys are added.
+func NewBloomFilter(n int, fpRate float64) (*BloomFilter, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("Number of keys must be positive: %d", n)
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("False positive rate must be between 0 and 1: %g", fpRate)
+	}
+	m := math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	words := (uint64(m) + 63) / 64
+	return &BloomFilter{bits: make([]uint64, words), m: words * 64, k: k}, nil
+}
+
+// TestAndAdd implements KeySet.
+func (f *BloomFilter) TestAndAdd(key string) bool {
+	// Kirsch-Mitzenmacher double hashing derives k indices from two hashes.
+	h1 := hash64String(key)
+	h2 := fmix64(h1^0x9e3779b97f4a7c15) | 1
+	seen := true
+	for i := 0; i < f.k; i++ {
+		idx := (h1 + uint64(i)*h2) % f.m
+		w, b := idx/64, uint64(1)<<(idx%64)
+		if f.bits[w]&b == 0 {
+			seen = false
+			f.bits[w] |= b
+		}
+	}
+	return seen
+}
+
+// Dedup drops records whose DedupKey has been seen already. Records without
+// key are never dropped.
+type Dedup struct {
+	set     KeySet
+	dropped uint64
+}
+
+// NewDedup returns a Dedup remembering keys in set.
+func NewDedup(set KeySet) *Dedup {
+	return &Dedup{set: set}
+}
+
+// Duplicate reports whether rec is a duplicate of a record seen before, and
+// remembers it otherwise.
+func (d *Dedup) Duplicate(rec interface{}) bool {
+	key := DedupKey(rec)
+	if key == "" {
+		return false
+	}
+	if d.set.TestAndAdd(key) {
+		d.dropped++
+		return true
+	}
+	return false
+}
+
+// Dropped returns the number of duplicates found so far.
+func (d *Dedup) Dropped() uint64 {
+	return d.dropped
+}
+
+// DedupReader is a RecordReader that skips duplicate records of another
+// RecordReader.
+type DedupReader struct {
+	RecordReader
+	*Dedup
+}
+
+// NewDedupReader returns a DedupReader of r remembering keys in set.
+func NewDedupReader(r RecordReader, set KeySet) *DedupReader {
+	return &DedupReader{r, NewDedup(set)}
+}
+
+// Read returns the next record that is not a duplicate.
+func (r *DedupReader) Read() (interface{}, error) {
+	for {
+		rec, err := r.RecordReader.Read()
+		if err != nil {
+			return nil, err
+		}
+		if !r.Duplicate(rec) {
+			return rec, nil
+		}
+	}
+}
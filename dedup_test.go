@@ -0,0 +1,78 @@
+package cflogparser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDedupReader(t *testing.T) {
+	in := webLines(
+		webLine("00:00:00", "/a"),
+		webLine("00:00:01", "/b"),
+		webLine("00:00:00", "/a"),
+	)
+	// webLine gives the same RequestID to every line, so make them unique
+	// by URI except for the duplicate.
+	in = strings.Replace(in, "Hit\tid\t", "Hit\tid-a\t", 1)
+	in = strings.Replace(in, "Hit\tid\t", "Hit\tid-b\t", 1)
+	in = strings.Replace(in, "Hit\tid\t", "Hit\tid-a\t", 1)
+	r := NewDedupReader(NewReader(strings.NewReader(in), false), NewExactSet())
+	got := strings.Join(readURIs(t, r), " ")
+	if got != "/a /b" {
+		t.Errorf("got %s, want /a /b", got)
+	}
+	if r.Dropped() != 1 {
+		t.Errorf("got %d dropped, want 1", r.Dropped())
+	}
+}
+
+func TestDedupKeyRTMP(t *testing.T) {
+	logs := readRTMPLogs(t, "testdata/sample-rtmp.log")
+	d := NewDedup(NewExactSet())
+	for _, l := range logs {
+		if d.Duplicate(l) {
+			t.Errorf("unexpected duplicate: %+v", l)
+		}
+	}
+	for _, l := range logs {
+		c := *l
+		if !d.Duplicate(&c) {
+			t.Errorf("duplicate not detected: %+v", l)
+		}
+	}
+	if d.Duplicate(&WebLog{}) {
+		t.Error("record without key must not be a duplicate")
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	const n = 10000
+	f, err := NewBloomFilter(n, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if f.TestAndAdd(fmt.Sprintf("req-%d", i)) && i < 100 {
+			t.Errorf("false positive in nearly empty filter: %d", i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !f.TestAndAdd(fmt.Sprintf("req-%d", i)) {
+			t.Fatalf("false negative: %d", i)
+		}
+	}
+	const m = 1000
+	fp := 0
+	for i := 0; i < m; i++ {
+		if f.TestAndAdd(fmt.Sprintf("other-%d", i)) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / m; rate > 0.02 {
+		t.Errorf("false positive rate too high: %g", rate)
+	}
+	if _, err := NewBloomFilter(n, 1); err == nil {
+		t.Error("expected error for invalid rate")
+	}
+}
@@ -0,0 +1,209 @@
+package cflogparser
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// PeriodStats aggregates WebLogs of a period to be compared with another
+// period by Diff. URIs and user agents are counted with TopK of the given
+// capacity, so memory usage is bounded.
+type PeriodStats struct {
+	Requests   uint64
+	Cache      CacheStats
+	statuses   map[uint16]uint64
+	uris       *TopK
+	userAgents *TopK
+	latency    *QuantileSketch
+}
+
+// NewPeriodStats returns an empty PeriodStats.
+func NewPeriodStats(capacity int) *PeriodStats {
+	return &PeriodStats{
+		statuses:   map[uint16]uint64{},
+		uris:       NewTopK(capacity),
+		userAgents: NewTopK(capacity),
+		latency:    NewQuantileSketch(0.01),
+	}
+}
+
+// Add counts l.
+func (s *PeriodStats) Add(l *WebLog) {
+	s.Requests++
+	s.Cache.Add(l)
+	s.statuses[l.Status]++
+	s.uris.Add(l.URI, 1)
+	s.userAgents.Add(l.UserAgent, 1)
+	s.latency.Add(float64(l.TimeTaken))
+}
+
+// Latency returns the q-quantile of TimeTaken.
+func (s *PeriodStats) Latency(q float64) float64 {
+	return s.latency.Quantile(q)
+}
+
+// Kinds of Change.
+const (
+	ChangeURI       = "uri"
+	ChangeStatus    = "status"
+	ChangeCacheHit  = "cache_hit_ratio"
+	ChangeLatency   = "latency"
+	ChangeUserAgent = "user_agent"
+)
+
+// Change is a difference between two periods. Before and After are shares
+// of requests for uri, status and user_agent, hit ratios for
+// cache_hit_ratio, and quantiles in seconds for latency. PValue is the
+// probability that the difference is observed by chance, so smaller is
+// more significant.
+type Change struct {
+	Kind   string  `json:"kind"`
+	Key    string  `json:"key"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	PValue float64 `json:"p_value"`
+	Note   string  `json:"note,omitempty"`
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s %s: %g -> %g (p=%.3g)", c.Kind, c.Key, c.Before, c.After, c.PValue)
+	if c.Note != "" {
+		s += " " + c.Note
+	}
+	return s
+}
+
+// Diff compares two periods and returns changes whose p-value is at most
+// alpha, in ascending order of p-value. Per-URI and per-user-agent shares
+// are compared with two-proportion z-test, status code mix and cache hit
+// ratio likewise, and latency quantiles with QuantileTest. URIs and user
+// agents seen only in one period are noted as "new" or "vanished".
+func Diff(before, after *PeriodStats, alpha float64) []Change {
+	var cs []Change
+	add := func(c Change) {
+		if c.PValue <= alpha {
+			cs = append(cs, c)
+		}
+	}
+
+	for _, c := range diffTopK(ChangeURI, before.uris, after.uris, before.Requests, after.Requests) {
+		add(c)
+	}
+	for _, c := range diffTopK(ChangeUserAgent, before.userAgents, after.userAgents, before.Requests, after.Requests) {
+		add(c)
+	}
+
+	codes := map[uint16]bool{}
+	for s := range before.statuses {
+		codes[s] = true
+	}
+	for s := range after.statuses {
+		codes[s] = true
+	}
+	for s := range codes {
+		b, a := before.statuses[s], after.statuses[s]
+		add(Change{
+			Kind:   ChangeStatus,
+			Key:    strconv.Itoa(int(s)),
+			Before: ratio(b, before.Requests),
+			After:  ratio(a, after.Requests),
+			PValue: proportionTest(b, before.Requests, a, after.Requests),
+		})
+	}
+
+	bc, ac := before.Cache, after.Cache
+	add(Change{
+		Kind:   ChangeCacheHit,
+		Key:    "hit",
+		Before: bc.HitRatio(),
+		After:  ac.HitRatio(),
+		PValue: proportionTest(bc.Hit, bc.Hit+bc.RefreshHit+bc.Miss, ac.Hit, ac.Hit+ac.RefreshHit+ac.Miss),
+	})
+
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		add(Change{
+			Kind:   ChangeLatency,
+			Key:    fmt.Sprintf("p%g", q*100),
+			Before: before.Latency(q),
+			After:  after.Latency(q),
+			PValue: QuantileTest(before.latency, after.latency, q),
+		})
+	}
+
+	sort.SliceStable(cs, func(i, j int) bool {
+		if cs[i].PValue != cs[j].PValue {
+			return cs[i].PValue < cs[j].PValue
+		}
+		return math.Abs(cs[i].After-cs[i].Before) > math.Abs(cs[j].After-cs[j].Before)
+	})
+	return cs
+}
+
+// diffTopK compares shares of keys counted by TopKs. The count of a key
+// missing in one TopK is at most its ErrorBound, which is taken as the
+// count so that the test is conservative. Such a key is reported, noted as
+// "new" or "vanished", only if its count in the other TopK exceeds the
+// bound, i.e. it is certainly less frequent in the period missing it.
+func diffTopK(kind string, before, after *TopK, nb, na uint64) []Change {
+	type pair struct {
+		counts [2]uint64
+		found  [2]bool
+	}
+	pairs := map[string]*pair{}
+	for i, tk := range []*TopK{before, after} {
+		for _, e := range tk.Top(tk.capacity) {
+			p := pairs[e.Key]
+			if p == nil {
+				p = &pair{}
+				pairs[e.Key] = p
+			}
+			p.counts[i] = e.Count
+			p.found[i] = true
+		}
+	}
+	eb, ea := before.ErrorBound(), after.ErrorBound()
+	var cs []Change
+	for k, p := range pairs {
+		c := p.counts
+		var note string
+		switch {
+		case !p.found[0]:
+			if c[1] <= eb {
+				continue
+			}
+			c[0], note = eb, "new"
+		case !p.found[1]:
+			if c[0] <= ea {
+				continue
+			}
+			c[1], note = ea, "vanished"
+		}
+		cs = append(cs, Change{
+			Kind:   kind,
+			Key:    k,
+			Before: ratio(c[0], nb),
+			After:  ratio(c[1], na),
+			PValue: proportionTest(c[0], nb, c[1], na),
+			Note:   note,
+		})
+	}
+	return cs
+}
+
+// proportionTest returns two-sided p-value of two-proportion z-test between
+// x1/n1 and x2/n2.
+func proportionTest(x1, n1, x2, n2 uint64) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	p1, p2 := float64(x1)/float64(n1), float64(x2)/float64(n2)
+	p := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(p * (1 - p) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 1
+	}
+	z := math.Abs(p1-p2) / se
+	return math.Erfc(z / math.Sqrt2)
+}
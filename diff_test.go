@@ -0,0 +1,88 @@
+package cflogparser
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	before, after := NewPeriodStats(100), NewPeriodStats(100)
+	for i := 0; i < 2000; i++ {
+		l := &WebLog{URI: "/a", Status: 200, ResultType: "Hit", UserAgent: "curl", TimeTaken: float32(r.ExpFloat64() * 0.1)}
+		if i%2 == 0 {
+			l.URI = "/b"
+		}
+		before.Add(l)
+	}
+	for i := 0; i < 2000; i++ {
+		l := &WebLog{URI: "/a", Status: 200, ResultType: "Hit", UserAgent: "curl", TimeTaken: float32(r.ExpFloat64() * 0.2)}
+		switch i % 10 {
+		case 0, 2, 4, 6, 8:
+			l.URI = "/b"
+		case 1, 3:
+			l.URI = "/c"
+			l.UserAgent = "NewBot/1.0"
+			l.Status = 500
+			l.ResultType = "Error"
+		}
+		after.Add(l)
+	}
+
+	cs := Diff(before, after, 0.001)
+	found := map[string]Change{}
+	for _, c := range cs {
+		found[c.Kind+" "+c.Key] = c
+	}
+	for _, k := range []string{"uri /a", "uri /c", "status 200", "status 500", "user_agent NewBot/1.0", "user_agent curl", "latency p50", "latency p99"} {
+		if _, ok := found[k]; !ok {
+			t.Errorf("%s is not reported", k)
+		}
+	}
+	if _, ok := found["uri /b"]; ok {
+		t.Error("uri /b should not be reported")
+	}
+	if c := found["user_agent NewBot/1.0"]; c.Note != "new" || c.Before != 0 || c.After != 0.2 {
+		t.Errorf("unexpected change: %s", c)
+	}
+	if c, ok := found["cache_hit_ratio hit"]; ok {
+		// Errors are not cacheable, so hit ratio is 1 in both periods.
+		t.Errorf("cache hit ratio should not be reported: %s", c)
+	}
+	for i := 1; i < len(cs); i++ {
+		if cs[i-1].PValue > cs[i].PValue {
+			t.Errorf("changes are not sorted by p-value: %s, %s", cs[i-1], cs[i])
+		}
+	}
+}
+
+func TestDiffEvicted(t *testing.T) {
+	before, after := NewPeriodStats(10), NewPeriodStats(10)
+	for i := 0; i < 5000; i++ {
+		// Long tail of user agents, which makes TopKs evict keys.
+		l := &WebLog{URI: "/", Status: 200, UserAgent: fmt.Sprintf("agent/%d", i%500)}
+		before.Add(l)
+		if i%5 == 0 {
+			l = &WebLog{URI: "/", Status: 200, UserAgent: "NewBot/1.0"}
+		}
+		after.Add(l)
+	}
+	if before.userAgents.ErrorBound() == 0 || after.userAgents.ErrorBound() == 0 {
+		t.Fatal("TopKs should have evicted keys")
+	}
+	var found bool
+	for _, c := range Diff(before, after, 0.001) {
+		if c.Kind == ChangeUserAgent && c.Key == "NewBot/1.0" {
+			found = true
+			if c.Note != "new" || c.After != 0.2 || c.Before != ratio(before.userAgents.ErrorBound(), 5000) {
+				t.Errorf("unexpected change: %s", c)
+			}
+		} else if c.Kind == ChangeUserAgent {
+			t.Errorf("unexpected change: %s", c)
+		}
+	}
+	if !found {
+		t.Error("new user agent is not reported")
+	}
+}
@@ -0,0 +1,232 @@
+package cflogparser
+
+import (
+	"sort"
+	"time"
+)
+
+// Sources of errors classified by ErrorSource.
+const (
+	OriginError = "origin"
+	EdgeError   = "edge"
+)
+
+// IsError reports whether l is an error, i.e. its status is 4xx or 5xx, or
+// CloudFront reports its result type as Error, LimitExceeded or
+// CapacityExceeded.
+func IsError(l *WebLog) bool {
+	switch l.ResultType {
+	case "Error", "LimitExceeded", "CapacityExceeded":
+		return true
+	}
+	return l.Status >= 400
+}
+
+// ErrorSource guesses whether error l is caused by origin or by CloudFront
+// edge. CloudFront logs errors of origin, such as 500, 503 and 4xx, with
+// result type Error as well as its own errors, so an error is regarded as
+// edge-side only if CloudFront generated it by itself: LimitExceeded,
+// CapacityExceeded, statuses that CloudFront returns for malformed or too
+// large requests (400, 405, 411, 413, 414 and 494), or no response at all
+// (status 0). Others, including 502 and 504 for an unreachable origin, are
+// origin-side.
+func ErrorSource(l *WebLog) string {
+	switch l.ResultType {
+	case "LimitExceeded", "CapacityExceeded":
+		return EdgeError
+	}
+	switch l.Status {
+	case 0, 400, 405, 411, 413, 414, 494:
+		return EdgeError
+	}
+	return OriginError
+}
+
+// ErrorCount counts requests and errors split by their sources.
+type ErrorCount struct {
+	Requests     uint64 `json:"requests"`
+	Errors       uint64 `json:"errors"`
+	OriginErrors uint64 `json:"origin_errors"`
+	EdgeErrors   uint64 `json:"edge_errors"`
+}
+
+func (c *ErrorCount) add(l *WebLog) {
+	c.Requests++
+	if !IsError(l) {
+		return
+	}
+	c.Errors++
+	if ErrorSource(l) == OriginError {
+		c.OriginErrors++
+	} else {
+		c.EdgeErrors++
+	}
+}
+
+// ErrorRate returns the ratio of errors to requests.
+func (c *ErrorCount) ErrorRate() float64 {
+	return ratio(c.Errors, c.Requests)
+}
+
+// ErrorGroup is ErrorCount of a group of requests, such as edge location.
+type ErrorGroup struct {
+	Name string `json:"name"`
+	ErrorCount
+}
+
+// ErrorBucket is ErrorCount of a time window starting at Time.
+type ErrorBucket struct {
+	Time time.Time `json:"time"`
+	ErrorCount
+}
+
+// ErrorCluster is a set of errors having the same status, result type and
+// source.
+type ErrorCluster struct {
+	Status           uint16    `json:"status"`
+	ResultType       string    `json:"result_type"`
+	Source           string    `json:"source"`
+	Count            uint64    `json:"count"`
+	FirstSeen        time.Time `json:"first_seen"`
+	LastSeen         time.Time `json:"last_seen"`
+	SampleRequestIDs []string  `json:"sample_request_ids"`
+	uris             *TopK
+	locations        *TopK
+}
+
+// TopURIs returns top n URIs in the cluster.
+func (c *ErrorCluster) TopURIs(n int) []TopKEntry {
+	return c.uris.Top(n)
+}
+
+// TopLocations returns top n edge locations in the cluster.
+func (c *ErrorCluster) TopLocations(n int) []TopKEntry {
+	return c.locations.Top(n)
+}
+
+type errorKey struct {
+	status     uint16
+	resultType string
+	source     string
+}
+
+// ErrorReport aggregates WebLogs to break down errors into clusters by
+// status, result type and source, with sample request IDs to be reported
+// to AWS support. It also counts errors per edge location and per time
+// window, so that origin-side errors and edge-side errors can be compared.
+type ErrorReport struct {
+	Total     ErrorCount
+	interval  time.Duration
+	samples   int
+	clusters  map[errorKey]*ErrorCluster
+	locations map[string]*ErrorCount
+	timeline  map[time.Time]*ErrorCount
+}
+
+// NewErrorReport returns an empty ErrorReport that counts errors for each
+// time window of interval, and keeps up to samples request IDs per cluster.
+func NewErrorReport(interval time.Duration, samples int) *ErrorReport {
+	return &ErrorReport{
+		interval:  interval,
+		samples:   samples,
+		clusters:  map[errorKey]*ErrorCluster{},
+		locations: map[string]*ErrorCount{},
+		timeline:  map[time.Time]*ErrorCount{},
+	}
+}
+
+// Add counts l.
+func (r *ErrorReport) Add(l *WebLog) {
+	r.Total.add(l)
+	loc := r.locations[l.Location]
+	if loc == nil {
+		loc = &ErrorCount{}
+		r.locations[l.Location] = loc
+	}
+	loc.add(l)
+	t := l.Time.Truncate(r.interval)
+	b := r.timeline[t]
+	if b == nil {
+		b = &ErrorCount{}
+		r.timeline[t] = b
+	}
+	b.add(l)
+
+	if !IsError(l) {
+		return
+	}
+
+	k := errorKey{l.Status, l.ResultType, ErrorSource(l)}
+	c := r.clusters[k]
+	if c == nil {
+		c = &ErrorCluster{
+			Status:     k.status,
+			ResultType: k.resultType,
+			Source:     k.source,
+			FirstSeen:  l.Time,
+			LastSeen:   l.Time,
+			uris:       NewTopK(1000),
+			locations:  NewTopK(100),
+		}
+		r.clusters[k] = c
+	}
+	c.Count++
+	if l.Time.Before(c.FirstSeen) {
+		c.FirstSeen = l.Time
+	}
+	if l.Time.After(c.LastSeen) {
+		c.LastSeen = l.Time
+	}
+	if len(c.SampleRequestIDs) < r.samples && l.RequestID != "" {
+		c.SampleRequestIDs = append(c.SampleRequestIDs, l.RequestID)
+	}
+	c.uris.Add(l.URI, 1)
+	c.locations.Add(l.Location, 1)
+}
+
+// Clusters returns error clusters in descending order of count.
+func (r *ErrorReport) Clusters() []*ErrorCluster {
+	cs := make([]*ErrorCluster, 0, len(r.clusters))
+	for _, c := range r.clusters {
+		cs = append(cs, c)
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].Count != cs[j].Count {
+			return cs[i].Count > cs[j].Count
+		}
+		if cs[i].Status != cs[j].Status {
+			return cs[i].Status < cs[j].Status
+		}
+		if cs[i].ResultType != cs[j].ResultType {
+			return cs[i].ResultType < cs[j].ResultType
+		}
+		return cs[i].Source < cs[j].Source
+	})
+	return cs
+}
+
+// ByLocation returns error counts per edge location in descending order of
+// errors.
+func (r *ErrorReport) ByLocation() []ErrorGroup {
+	gs := make([]ErrorGroup, 0, len(r.locations))
+	for k, c := range r.locations {
+		gs = append(gs, ErrorGroup{Name: k, ErrorCount: *c})
+	}
+	sort.Slice(gs, func(i, j int) bool {
+		if gs[i].Errors != gs[j].Errors {
+			return gs[i].Errors > gs[j].Errors
+		}
+		return gs[i].Name < gs[j].Name
+	})
+	return gs
+}
+
+// Timeline returns error counts per time window in chronological order.
+func (r *ErrorReport) Timeline() []ErrorBucket {
+	bs := make([]ErrorBucket, 0, len(r.timeline))
+	for t, c := range r.timeline {
+		bs = append(bs, ErrorBucket{Time: t, ErrorCount: *c})
+	}
+	sort.Slice(bs, func(i, j int) bool { return bs[i].Time.Before(bs[j].Time) })
+	return bs
+}
@@ -0,0 +1,102 @@
+package cflogparser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErrorSource(t *testing.T) {
+	tests := []struct {
+		status   uint16
+		result   string
+		response string
+		isError  bool
+		source   string
+	}{
+		{200, "Hit", "Hit", false, OriginError},
+		{404, "Error", "Miss", true, OriginError},
+		{404, "Error", "Hit", true, OriginError},
+		{502, "Error", "Error", true, OriginError},
+		{504, "Error", "Error", true, OriginError},
+		{503, "LimitExceeded", "LimitExceeded", true, EdgeError},
+		{503, "CapacityExceeded", "CapacityExceeded", true, EdgeError},
+		{500, "Error", "Error", true, OriginError},
+		{503, "Error", "Error", true, OriginError},
+		{403, "Error", "Error", true, OriginError},
+		{404, "Error", "Error", true, OriginError},
+		{400, "Error", "Error", true, EdgeError},
+		{413, "Error", "Error", true, EdgeError},
+		{494, "Error", "Error", true, EdgeError},
+		{0, "Error", "Error", true, EdgeError},
+	}
+	for _, test := range tests {
+		l := &WebLog{Status: test.status, ResultType: test.result, ResponseResultType: test.response}
+		if e := IsError(l); e != test.isError {
+			t.Errorf("%d %s: got IsError %v, want %v", test.status, test.result, e, test.isError)
+		}
+		if s := ErrorSource(l); test.isError && s != test.source {
+			t.Errorf("%d %s %s: got %s, want %s", test.status, test.result, test.response, s, test.source)
+		}
+	}
+}
+
+func TestErrorReport(t *testing.T) {
+	base := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	r := NewErrorReport(time.Minute, 2)
+	add := func(sec int, loc, uri string, status uint16, result, response, id string) {
+		r.Add(&WebLog{
+			Time:               base.Add(time.Duration(sec) * time.Second),
+			Location:           loc,
+			URI:                uri,
+			Status:             status,
+			ResultType:         result,
+			ResponseResultType: response,
+			RequestID:          id,
+		})
+	}
+	add(0, "NRT20", "/", 200, "Hit", "Hit", "a")
+	add(10, "NRT20", "/api", 504, "Error", "Error", "b")
+	add(20, "NRT20", "/api", 504, "Error", "Error", "c")
+	add(70, "FRA2", "/api", 504, "Error", "Error", "d")
+	add(80, "FRA2", "/img", 503, "LimitExceeded", "LimitExceeded", "e")
+	add(90, "FRA2", "/", 200, "Miss", "Miss", "f")
+
+	if r.Total != (ErrorCount{Requests: 6, Errors: 4, OriginErrors: 3, EdgeErrors: 1}) {
+		t.Errorf("unexpected total: %+v", r.Total)
+	}
+
+	cs := r.Clusters()
+	if len(cs) != 2 {
+		t.Fatalf("got %d clusters, want 2", len(cs))
+	}
+	c := cs[0]
+	if c.Status != 504 || c.Source != OriginError || c.Count != 3 {
+		t.Errorf("unexpected cluster: %+v", c)
+	}
+	if !reflect.DeepEqual(c.SampleRequestIDs, []string{"b", "c"}) {
+		t.Errorf("got samples %v, want [b c]", c.SampleRequestIDs)
+	}
+	if !c.FirstSeen.Equal(base.Add(10*time.Second)) || !c.LastSeen.Equal(base.Add(70*time.Second)) {
+		t.Errorf("unexpected time range: %s - %s", c.FirstSeen, c.LastSeen)
+	}
+	if us := c.TopURIs(10); len(us) != 1 || us[0].Key != "/api" || us[0].Count != 3 {
+		t.Errorf("unexpected URIs: %+v", us)
+	}
+	if ls := c.TopLocations(10); len(ls) != 2 || ls[0].Key != "NRT20" || ls[0].Count != 2 {
+		t.Errorf("unexpected locations: %+v", ls)
+	}
+	if cs[1].ResultType != "LimitExceeded" || cs[1].Source != EdgeError {
+		t.Errorf("unexpected cluster: %+v", cs[1])
+	}
+
+	ls := r.ByLocation()
+	if len(ls) != 2 || ls[0].Name != "FRA2" || ls[0].EdgeErrors != 1 || ls[0].ErrorRate() != 2.0/3 {
+		t.Errorf("unexpected locations: %+v", ls)
+	}
+
+	bs := r.Timeline()
+	if len(bs) != 2 || !bs[0].Time.Equal(base) || bs[0].Errors != 2 || bs[1].OriginErrors != 1 || bs[1].EdgeErrors != 1 {
+		t.Errorf("unexpected timeline: %+v", bs)
+	}
+}
@@ -0,0 +1,99 @@
+package cflogparser
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Field describes a field of WebLog or RTMPLog. Fields are identified by
+// their JSON names, e.g. "request_ip".
+type Field struct {
+	Name  string
+	Type  reflect.Type
+	index int
+}
+
+// WebLogFields lists fields of WebLog in the order of the log format.
+var WebLogFields = fieldsOf(reflect.TypeOf(WebLog{}))
+
+// RTMPLogFields lists fields of RTMPLog in the order of the log format.
+var RTMPLogFields = fieldsOf(reflect.TypeOf(RTMPLog{}))
+
+func fieldsOf(t reflect.Type) []Field {
+	fs := make([]Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		fs = append(fs, Field{Name: name, Type: sf.Type, index: i})
+	}
+	return fs
+}
+
+// LookupField returns the field named name of rec, which must be either
+// *WebLog or *RTMPLog.
+func LookupField(rec interface{}, name string) (Field, error) {
+	var fs []Field
+	switch rec.(type) {
+	case *WebLog:
+		fs = WebLogFields
+	case *RTMPLog:
+		fs = RTMPLogFields
+	default:
+		return Field{}, fmt.Errorf("Unsupported record type: %T", rec)
+	}
+	for _, f := range fs {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return Field{}, fmt.Errorf("Unknown field: %s", name)
+}
+
+// LookupFields returns fields of rec named names in the order. If names is
+// empty, it returns all fields of rec.
+func LookupFields(rec interface{}, names []string) ([]Field, error) {
+	if len(names) == 0 {
+		switch rec.(type) {
+		case *WebLog:
+			return WebLogFields, nil
+		case *RTMPLog:
+			return RTMPLogFields, nil
+		}
+		return nil, fmt.Errorf("Unsupported record type: %T", rec)
+	}
+	fs := make([]Field, 0, len(names))
+	for _, name := range names {
+		f, err := LookupField(rec, name)
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
+
+// Value returns the value of f in rec.
+func (f Field) Value(rec interface{}) interface{} {
+	return reflect.ValueOf(rec).Elem().Field(f.index).Interface()
+}
+
+// String returns the value of f in rec as string. Time is formatted in
+// RFC3339 and empty IP address is formatted as empty string.
+func (f Field) String(rec interface{}) string {
+	switch v := f.Value(rec).(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case net.IP:
+		if v == nil {
+			return ""
+		}
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,48 @@
+package cflogparser
+
+import (
+	"testing"
+)
+
+func TestFieldString(t *testing.T) {
+	web, err := ParseLineWeb(`2014-05-23	01:13:11	FRA2	182	192.0.2.10	GET	d111111abcdef8.cloudfront.net	/view/my/file.html	200	www.displaymyfiles.com	Mozilla/4.0%20(compatible;%20MSIE%205.0b1;%20Mac_PowerPC)	-	zip=98101	RefreshHit	MRVMF7KydIvxMWfJIglgwHQwZsbG2IhRJ07sn9AkKUFSHS9EXAMPLE==	d111111abcdef8.cloudfront.net	http	-	0.001	-	-	-	RefreshHit	HTTP/1.1	Processed	1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtmp, err := ParseLineRTMP(`2010-03-12	23:51:21	SEA4	192.0.2.222	play	3914	OK	bfd8a98bee0840d9b871b7f6ade9908f	rtmp://shqshne4jdp4b6.cloudfront.net/cfx/st	key=value	http://player.longtailvideo.com/player.swf	http://www.longtailvideo.com/support/jw-player-setup-wizard?example=204	LNX%2010,0,32,18	myvideo	p=2&q=4	flv	1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		rec  interface{}
+		name string
+		out  string
+	}{
+		{web, "time", "2014-05-23T01:13:11Z"},
+		{web, "request_ip", "192.0.2.10"},
+		{web, "uri", "/view/my/file.html"},
+		{web, "status", "200"},
+		{web, "time_taken", "0.001"},
+		{web, "user_agent", "Mozilla/4.0 (compatible; MSIE 5.0b1; Mac_PowerPC)"},
+		{rtmp, "client_id", "bfd8a98bee0840d9b871b7f6ade9908f"},
+		{rtmp, "stream_name", "myvideo"},
+		{rtmp, "stream_id", "1"},
+	}
+	for _, test := range tests {
+		f, err := LookupField(test.rec, test.name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if s := f.String(test.rec); s != test.out {
+			t.Errorf("%s: got %q, want %q", test.name, s, test.out)
+		}
+	}
+
+	if _, err := LookupField(web, "stream_name"); err == nil {
+		t.Error("stream_name should not be a field of WebLog")
+	}
+	if len(WebLogFields) != 25 || len(RTMPLogFields) != 16 {
+		t.Errorf("got %d and %d fields, want 25 and 16", len(WebLogFields), len(RTMPLogFields))
+	}
+}
@@ -0,0 +1,140 @@
+package cflogparser
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Header lines of log files as CloudFront writes them.
+var (
+	WebLogHeader = []string{
+		"#Version: 1.0",
+		"#Fields: date time x-edge-location sc-bytes c-ip cs-method cs(Host) cs-uri-stem sc-status cs(Referer) cs(User-Agent) cs-uri-query cs(Cookie) x-edge-result-type x-edge-request-id x-host-header cs-protocol cs-bytes time-taken x-forwarded-for ssl-protocol ssl-cipher x-edge-response-result-type cs-protocol-version fle-status fle-encrypted-fields",
+	}
+	RTMPLogHeader = []string{
+		"#Version: 1.0",
+		"#Fields: date time x-edge-location c-ip x-event sc-bytes x-cf-status x-cf-client-id cs-uri-stem cs-uri-query c-referrer x-page-url c-user-agent x-sname x-sname-query x-file-ext x-sid",
+	}
+)
+
+const hexDigits = "0123456789ABCDEF"
+
+// Escape escapes s as a field value of log. It is the reverse of Unescape:
+// control characters, space, '"', '\', '%' and non-ASCII bytes are encoded
+// as "%XX". Note that "%2520", "%2522" and "%255C" are ambiguous in
+// CloudFront's log, so a value containing them literally, such as "%20",
+// is not restored exactly by Unescape.
+func Escape(s string) string {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if shouldEscape(s[i]) {
+			n++
+		}
+	}
+	if n == 0 {
+		return s
+	}
+	var builder strings.Builder
+	builder.Grow(len(s) + 2*n)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if shouldEscape(c) {
+			builder.WriteByte('%')
+			builder.WriteByte(hexDigits[c>>4])
+			builder.WriteByte(hexDigits[c&15])
+		} else {
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String()
+}
+
+func shouldEscape(c byte) bool {
+	return c <= ' ' || c >= 0x7f || c == '"' || c == '\\' || c == '%'
+}
+
+func formatString(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return Escape(s)
+}
+
+func formatIP(ip net.IP) string {
+	if ip == nil {
+		return "-"
+	}
+	return ip.String()
+}
+
+// FormatLineWeb formats l as a line of Web distribution log, without
+// trailing newline. The result can be parsed by ParseLineWeb.
+func FormatLineWeb(l *WebLog) string {
+	vals := []string{
+		l.Time.UTC().Format("2006-01-02"),
+		l.Time.UTC().Format("15:04:05"),
+		formatString(l.Location),
+		strconv.FormatUint(l.Bytes, 10),
+		formatIP(l.RequestIP),
+		formatString(l.Method),
+		formatString(l.Host),
+		formatString(l.URI),
+		strconv.FormatUint(uint64(l.Status), 10),
+		formatString(l.Referrer),
+		formatString(l.UserAgent),
+		formatString(l.QueryString),
+		formatString(l.Cookie),
+		formatString(l.ResultType),
+		formatString(l.RequestID),
+		formatString(l.HostHeader),
+		formatString(l.RequestProtocol),
+		strconv.FormatUint(l.RequestBytes, 10),
+		strconv.FormatFloat(float64(l.TimeTaken), 'f', -1, 32),
+		formatString(l.XforwardedFor),
+		formatString(l.SslProtocol),
+		formatString(l.SslCipher),
+		formatString(l.ResponseResultType),
+		formatString(l.HTTPVersion),
+		formatString(l.FleStatus),
+		strconv.FormatUint(uint64(l.FleEncryptedFields), 10),
+	}
+	return strings.Join(vals, "\t")
+}
+
+// FormatLineRTMP formats l as a line of RTMP distribution log, without
+// trailing newline. The result can be parsed by ParseLineRTMP.
+func FormatLineRTMP(l *RTMPLog) string {
+	vals := []string{
+		l.Time.UTC().Format("2006-01-02"),
+		l.Time.UTC().Format("15:04:05"),
+		formatString(l.Location),
+		formatIP(l.RequestIP),
+		formatString(l.EventType),
+		strconv.FormatUint(l.Bytes, 10),
+		formatString(l.Status),
+		formatString(l.ClientID),
+		formatString(l.URI),
+		formatString(l.QueryString),
+		formatString(l.Referrer),
+		formatString(l.PageURL),
+		formatString(l.UserAgent),
+		formatString(l.StreamName),
+		formatString(l.StreamQuery),
+		formatString(l.StreamFileExt),
+		strconv.FormatUint(uint64(l.StreamID), 10),
+	}
+	return strings.Join(vals, "\t")
+}
+
+// FormatLine formats rec, which must be *WebLog or *RTMPLog, by FormatLineWeb
+// or FormatLineRTMP. It returns "" for other types.
+func FormatLine(rec interface{}) string {
+	switch l := rec.(type) {
+	case *WebLog:
+		return FormatLineWeb(l)
+	case *RTMPLog:
+		return FormatLineRTMP(l)
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package cflogparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"/view/my/file.html", "/view/my/file.html"},
+		{"Mozilla/4.0 (compatible)", "Mozilla/4.0%20(compatible)"},
+		{"a\tb\"c\\d", "a%09b%22c%5Cd"},
+		{"100%", "100%25"},
+		{"café", "caf%C3%A9"},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.in); got != tt.out {
+			t.Errorf("Escape(%q) = %q, want %q", tt.in, got, tt.out)
+		}
+		if got := MustUnescape(tt.out); got != tt.in {
+			t.Errorf("Unescape(%q) = %q, want %q", tt.out, got, tt.in)
+		}
+	}
+}
+
+func TestFormatLineWeb(t *testing.T) {
+	lines := []string{
+		webLine("00:00:00", "/a b"),
+		`2014-05-23	01:13:12	LAX1	2390282	192.0.2.202	GET	d111111abcdef8.cloudfront.net	/soundtrack/happy.mp3	304	www.unknownsingers.com	Mozilla/4.0%20(compatible;%20MSIE%207.0;%20Windows%20NT%205.1)	a=b&c=d	zip=50158	Hit	xGN7KWpVEmB9Dp7ctcVFQC4E-nrcOcEKS3QyAez--06dV7TEXAMPLE==	d111111abcdef8.cloudfront.net	http	-	0.002	-	-	-	Hit	HTTP/1.1	-	-`,
+	}
+	for _, line := range lines {
+		want, err := ParseLineWeb(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := FormatLine(want)
+		got, err := ParseLineWeb(out)
+		if err != nil {
+			t.Fatalf("can't parse formatted line: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip failed:\n got %+v\nwant %+v", got, want)
+		}
+	}
+}
+
+func TestFormatLineRTMP(t *testing.T) {
+	for _, want := range readRTMPLogs(t, "testdata/sample-rtmp.log") {
+		got, err := ParseLineRTMP(FormatLine(want))
+		if err != nil {
+			t.Fatalf("can't parse formatted line: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip failed:\n got %+v\nwant %+v", got, want)
+		}
+	}
+}
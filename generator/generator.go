@@ -0,0 +1,395 @@
+// Package generator generates synthetic CloudFront access logs for tests,
+// load testing and fuzzing of consumers.
+//
+// Records are generated from a seeded random source, so the same Config
+// always gives the same log. Lines are formatted by cflogparser.FormatLine,
+// with optional CloudFront-style double escaping such as "%2520".
+package generator
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// Choice is a value chosen at random in proportion to Weight.
+type Choice struct {
+	Value  string
+	Weight float64
+}
+
+// Config configures Generator. Start from DefaultConfig to get realistic
+// ratios; empty lists, zero Start and non-positive Rate and Clients are
+// replaced with defaults by New.
+type Config struct {
+	Seed  int64
+	Start time.Time
+	Rate  float64 // Mean number of records per second
+
+	Hosts      []string
+	Locations  []Choice
+	URIs       []Choice // Request paths for Web, stream names for RTMP
+	Statuses   []Choice // HTTP status codes for Web
+	UserAgents []Choice
+	Referrers  []Choice // "" for no referrer
+
+	HitRatio     float64 // Fraction of successful requests served from cache
+	QueryRatio   float64 // Fraction of requests with query string
+	CookieRatio  float64 // Fraction of requests with cookie
+	ForwardRatio float64 // Fraction of requests via proxy with X-Forwarded-For
+	IPv6Ratio    float64 // Fraction of clients with IPv6 address
+	Clients      int     // Number of distinct clients
+
+	// DoubleEscapeRatio is the fraction of lines in which space, '"' and
+	// '\' are escaped twice, as CloudFront does in some fields.
+	DoubleEscapeRatio float64
+}
+
+// DefaultConfig returns the configuration used for zero fields of Config.
+func DefaultConfig() Config {
+	return Config{
+		Start: time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC),
+		Rate:  100,
+		Hosts: []string{"d111111abcdef8.cloudfront.net"},
+		Locations: []Choice{
+			{"IAD89-C1", 30}, {"NRT57-C2", 20}, {"FRA2-C1", 15}, {"LHR62-C2", 10},
+			{"SEA19-C1", 10}, {"GRU1-C1", 5}, {"SIN2-C1", 5}, {"SYD1-C1", 5},
+		},
+		URIs: []Choice{
+			{"/", 10}, {"/index.html", 10}, {"/css/main.css", 10}, {"/js/app.js", 10},
+			{"/img/logo.png", 10}, {"/img/photo 01.jpg", 3}, {"/api/v1/items", 10},
+			{"/video/intro.m3u8", 5}, {"/video/intro_1080p_0001.ts", 10},
+			{"/docs/naïve résumé.pdf", 1}, {"/search/\"quoted\"", 1}, {"/path\\with\\backslash", 1},
+			{"/favicon.ico", 5}, {"/robots.txt", 1},
+		},
+		Statuses: []Choice{
+			{"200", 88}, {"206", 2}, {"304", 4}, {"301", 1}, {"403", 1}, {"404", 2},
+			{"500", 0.5}, {"502", 0.5}, {"503", 0.7}, {"504", 0.3},
+		},
+		UserAgents: []Choice{
+			{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/77.0.3865.90 Safari/537.36", 40},
+			{"Mozilla/5.0 (iPhone; CPU iPhone OS 13_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0 Mobile/15E148 Safari/604.1", 25},
+			{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:69.0) Gecko/20100101 Firefox/69.0", 15},
+			{"curl/7.64.1", 5},
+			{"Googlebot/2.1 (+http://www.google.com/bot.html)", 5},
+			{"Player \"X\" 1.0 \\ test", 1},
+			{"", 1},
+		},
+		Referrers: []Choice{
+			{"", 50}, {"https://www.example.com/", 30}, {"https://www.google.com/", 15},
+			{"https://www.example.com/search?q=caf%C3%A9", 5},
+		},
+		HitRatio:          0.8,
+		QueryRatio:        0.2,
+		CookieRatio:       0.3,
+		ForwardRatio:      0.05,
+		IPv6Ratio:         0.2,
+		Clients:           10000,
+		DoubleEscapeRatio: 0.5,
+	}
+}
+
+func (c *Config) fillDefaults() {
+	d := DefaultConfig()
+	if c.Start.IsZero() {
+		c.Start = d.Start
+	}
+	if c.Rate <= 0 {
+		c.Rate = d.Rate
+	}
+	if len(c.Hosts) == 0 {
+		c.Hosts = d.Hosts
+	}
+	if len(c.Locations) == 0 {
+		c.Locations = d.Locations
+	}
+	if len(c.URIs) == 0 {
+		c.URIs = d.URIs
+	}
+	if len(c.Statuses) == 0 {
+		c.Statuses = d.Statuses
+	}
+	if len(c.UserAgents) == 0 {
+		c.UserAgents = d.UserAgents
+	}
+	if len(c.Referrers) == 0 {
+		c.Referrers = d.Referrers
+	}
+	if c.Clients <= 0 {
+		c.Clients = d.Clients
+	}
+}
+
+// Generator generates records of log in chronological order.
+type Generator struct {
+	cfg   Config
+	rnd   *rand.Rand
+	clock time.Time
+
+	// Pending RTMP events of sessions started so far, in chronological order
+	pending []*cflogparser.RTMPLog
+	sid     uint32
+}
+
+// New returns a Generator of cfg.
+func New(cfg Config) *Generator {
+	cfg.fillDefaults()
+	return &Generator{cfg: cfg, rnd: rand.New(rand.NewSource(cfg.Seed)), clock: cfg.Start}
+}
+
+func (g *Generator) choose(cs []Choice) string {
+	total := 0.0
+	for _, c := range cs {
+		total += c.Weight
+	}
+	x := g.rnd.Float64() * total
+	for _, c := range cs {
+		x -= c.Weight
+		if x < 0 {
+			return c.Value
+		}
+	}
+	return cs[len(cs)-1].Value
+}
+
+func (g *Generator) chance(p float64) bool {
+	return g.rnd.Float64() < p
+}
+
+// tick advances the clock by an exponentially distributed interval.
+func (g *Generator) tick() time.Time {
+	g.clock = g.clock.Add(time.Duration(g.rnd.ExpFloat64() / g.cfg.Rate * float64(time.Second)))
+	return g.clock.Truncate(time.Second)
+}
+
+// client returns an IP address of one of the clients. Client numbers are
+// drawn from a skewed distribution so that some clients are heavy.
+func (g *Generator) client() net.IP {
+	n := int(math.Pow(g.rnd.Float64(), 3) * float64(g.cfg.Clients))
+	// The address is derived from the client number by hashing, so that a
+	// client always has the same address.
+	h := fmix64(uint64(g.cfg.Seed^int64(n)) + 0x9e3779b97f4a7c15)
+	if float64(h>>11)/(1<<53) < g.cfg.IPv6Ratio {
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, net.ParseIP("2001:db8::"))
+		h = fmix64(h)
+		binary.BigEndian.PutUint64(ip[4:], h)
+		binary.BigEndian.PutUint32(ip[12:], uint32(fmix64(h)))
+		return ip
+	}
+	// Addresses in the benchmarking range 198.18.0.0/15 (RFC 2544)
+	return net.IPv4(198, 18+byte(n>>16&1), byte(n>>8), byte(n)).To16()
+}
+
+func (g *Generator) requestID() string {
+	b := make([]byte, 40)
+	g.rnd.Read(b)
+	return base64.URLEncoding.EncodeToString(b)
+}
+
+func (g *Generator) query() string {
+	params := []string{
+		"utm_source=newsletter", "page=" + strconv.Itoa(g.rnd.Intn(10)+1), "q=caf%C3%A9",
+		"token=" + strconv.FormatUint(g.rnd.Uint64(), 36), "email=user" + strconv.Itoa(g.rnd.Intn(100)) + "%40example.com",
+	}
+	n := g.rnd.Intn(3) + 1
+	g.rnd.Shuffle(len(params), func(i, j int) { params[i], params[j] = params[j], params[i] })
+	return strings.Join(params[:n], "&")
+}
+
+// Web returns the next record of Web distribution log.
+func (g *Generator) Web() *cflogparser.WebLog {
+	uri := g.choose(g.cfg.URIs)
+	status, _ := strconv.Atoi(g.choose(g.cfg.Statuses))
+	l := &cflogparser.WebLog{
+		Time:            g.tick(),
+		Location:        g.choose(g.cfg.Locations),
+		RequestIP:       g.client(),
+		Method:          "GET",
+		Host:            g.cfg.Hosts[g.rnd.Intn(len(g.cfg.Hosts))],
+		URI:             uri,
+		Status:          uint16(status),
+		Referrer:        g.choose(g.cfg.Referrers),
+		UserAgent:       g.choose(g.cfg.UserAgents),
+		RequestID:       g.requestID(),
+		RequestProtocol: "https",
+		RequestBytes:    uint64(200 + g.rnd.Intn(600)),
+		SslProtocol:     "TLSv1.2",
+		SslCipher:       "ECDHE-RSA-AES128-GCM-SHA256",
+		HTTPVersion:     "HTTP/1.1",
+	}
+	l.HostHeader = l.Host
+	if strings.HasPrefix(uri, "/api/") && g.chance(0.3) {
+		l.Method = "POST"
+		l.RequestBytes += uint64(g.rnd.Intn(4000))
+	}
+	if g.chance(0.5) {
+		l.HTTPVersion = "HTTP/2.0"
+	}
+	if g.chance(g.cfg.QueryRatio) {
+		l.QueryString = g.query()
+	}
+	if g.chance(g.cfg.CookieRatio) {
+		l.Cookie = "session=" + strconv.FormatUint(g.rnd.Uint64(), 36) + "; lang=ja"
+	}
+	if g.chance(g.cfg.ForwardRatio) {
+		l.XforwardedFor = g.client().String()
+	}
+
+	// Latency is log-normal, and misses take much longer than hits.
+	latency := math.Exp(g.rnd.NormFloat64()*0.8 - 5)
+	switch {
+	case status >= 500:
+		l.ResultType = "Error"
+		latency = math.Exp(g.rnd.NormFloat64()*0.5 - 1)
+		l.Bytes = uint64(400 + g.rnd.Intn(600))
+	case status >= 400:
+		l.ResultType = "Error"
+		l.Bytes = uint64(300 + g.rnd.Intn(300))
+	case status == 304 || status == 301:
+		l.ResultType = "Hit"
+		l.Bytes = uint64(200 + g.rnd.Intn(200))
+	case g.chance(g.cfg.HitRatio):
+		l.ResultType = "Hit"
+		if g.chance(0.05) {
+			l.ResultType = "RefreshHit"
+		}
+		l.Bytes = g.size(uri)
+	default:
+		l.ResultType = "Miss"
+		latency += math.Exp(g.rnd.NormFloat64()*0.7 - 2.5)
+		l.Bytes = g.size(uri)
+	}
+	l.ResponseResultType = l.ResultType
+	l.TimeTaken = float32(math.Round(latency*1000) / 1000)
+	return l
+}
+
+func (g *Generator) size(uri string) uint64 {
+	switch {
+	case strings.HasSuffix(uri, ".ts"):
+		return uint64(500000 + g.rnd.Intn(1500000))
+	case strings.HasSuffix(uri, ".jpg"), strings.HasSuffix(uri, ".png"), strings.HasSuffix(uri, ".pdf"):
+		return uint64(10000 + g.rnd.Intn(300000))
+	}
+	return uint64(500 + g.rnd.Intn(50000))
+}
+
+// RTMP returns the next event of RTMP distribution log. Events are generated
+// by connection: connect, then plays of streams with pauses, seeks and
+// stops, then disconnect. Events of concurrent connections are interleaved.
+func (g *Generator) RTMP() *cflogparser.RTMPLog {
+	for len(g.pending) == 0 || g.pending[0].Time.After(g.clock) {
+		g.connection()
+	}
+	l := g.pending[0]
+	g.pending = g.pending[1:]
+	return l
+}
+
+func (g *Generator) connection() {
+	start := g.tick()
+	clientID := strconv.FormatUint(g.rnd.Uint64(), 16) + strconv.FormatUint(g.rnd.Uint64(), 16)
+	host := g.cfg.Hosts[g.rnd.Intn(len(g.cfg.Hosts))]
+	base := cflogparser.RTMPLog{
+		Location:    g.choose(g.cfg.Locations),
+		RequestIP:   g.client(),
+		Status:      "OK",
+		ClientID:    clientID,
+		URI:         "rtmp://" + host + "/cfx/st",
+		QueryString: "key=value",
+		Referrer:    "http://player.example.com/player.swf",
+		PageURL:     g.choose(g.cfg.Referrers),
+		UserAgent:   "LNX 10,0,32,18",
+	}
+	var events []*cflogparser.RTMPLog
+	t := start
+	var bytes uint64 = 2000 + uint64(g.rnd.Intn(100))
+	add := func(ev, stream string, sid uint32) {
+		l := base
+		l.Time = t
+		l.EventType = ev
+		l.Bytes = bytes
+		if stream != "" {
+			l.StreamName = stream
+			l.StreamFileExt = "mp4"
+			l.StreamQuery = "p=" + strconv.Itoa(g.rnd.Intn(50))
+			l.StreamID = sid
+		}
+		events = append(events, &l)
+	}
+	add("connect", "", 0)
+
+	bitrate := float64(100000 + g.rnd.Intn(400000)) // bytes per second
+	for n := g.rnd.Intn(3) + 1; n > 0; n-- {
+		g.sid++
+		sid := g.sid
+		stream := strings.TrimPrefix(g.choose(g.cfg.URIs), "/")
+		t = t.Add(time.Duration(1+g.rnd.Intn(5)) * time.Second)
+		add("play", stream, sid)
+		for g.chance(0.4) {
+			d := time.Duration(g.rnd.ExpFloat64()*60) * time.Second
+			t = t.Add(d)
+			bytes += uint64(d.Seconds() * bitrate)
+			if g.chance(0.5) {
+				add("pause", stream, sid)
+				t = t.Add(time.Duration(g.rnd.ExpFloat64()*20) * time.Second)
+				add("unpause", stream, sid)
+			} else {
+				add("seek", stream, sid)
+			}
+		}
+		d := time.Duration(g.rnd.ExpFloat64()*120) * time.Second
+		t = t.Add(d)
+		bytes += uint64(d.Seconds() * bitrate)
+		add("stop", stream, sid)
+	}
+	t = t.Add(time.Duration(g.rnd.Intn(10)) * time.Second)
+	bytes += 2000
+	// Some connections end without disconnect, as when clients crash.
+	if g.chance(0.95) {
+		add("disconnect", "", 0)
+	}
+
+	g.pending = append(g.pending, events...)
+	sort.SliceStable(g.pending, func(i, j int) bool { return g.pending[i].Time.Before(g.pending[j].Time) })
+}
+
+// Line formats rec, which must be *cflogparser.WebLog or
+// *cflogparser.RTMPLog, as a line of log, with double escaping at the
+// configured ratio.
+func (g *Generator) Line(rec interface{}) string {
+	line := cflogparser.FormatLine(rec)
+	if g.chance(g.cfg.DoubleEscapeRatio) {
+		line = doubleEscaper.Replace(line)
+	}
+	return line
+}
+
+var doubleEscaper = strings.NewReplacer("%20", "%2520", "%22", "%2522", "%5C", "%255C")
+
+// Header returns header lines of Web or RTMP distribution log.
+func Header(rtmp bool) []string {
+	if rtmp {
+		return cflogparser.RTMPLogHeader
+	}
+	return cflogparser.WebLogHeader
+}
+
+// fmix64 is the finalizer of MurmurHash3, which mixes bits of h well.
+func fmix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+func TestWeb(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 42
+	g := New(cfg)
+	var last *cflogparser.WebLog
+	statuses := map[uint16]int{}
+	doubled := 0
+	const n = 5000
+	for i := 0; i < n; i++ {
+		want := g.Web()
+		line := g.Line(want)
+		if strings.Contains(line, "%2520") {
+			doubled++
+		}
+		got, err := cflogparser.ParseLineWeb(line)
+		if err != nil {
+			t.Fatalf("can't parse generated line: %v: %s", err, line)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("round trip failed:\n got %+v\nwant %+v", got, want)
+		}
+		if last != nil && got.Time.Before(last.Time) {
+			t.Fatalf("not in chronological order: %s after %s", got.Time, last.Time)
+		}
+		last = got
+		statuses[got.Status]++
+	}
+	if r := float64(statuses[200]) / n; r < 0.85 || r > 0.91 {
+		t.Errorf("got %g of status 200, want about 0.88", r)
+	}
+	if doubled == 0 {
+		t.Error("no double escaped lines")
+	}
+	if d := last.Time.Sub(cfg.Start).Seconds(); d < 40 || d > 60 {
+		t.Errorf("%d records took %gs, want about 50s at rate 100", n, d)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	for _, rtmp := range []bool{false, true} {
+		var out [3]string
+		for i, seed := range []int64{1, 1, 2} {
+			g := New(Config{Seed: seed, DoubleEscapeRatio: 0.5})
+			var b strings.Builder
+			for j := 0; j < 100; j++ {
+				if rtmp {
+					b.WriteString(g.Line(g.RTMP()))
+				} else {
+					b.WriteString(g.Line(g.Web()))
+				}
+			}
+			out[i] = b.String()
+		}
+		if out[0] != out[1] {
+			t.Error("same seed gave different logs")
+		}
+		if out[0] == out[2] {
+			t.Error("different seeds gave the same log")
+		}
+	}
+}
+
+func TestRTMP(t *testing.T) {
+	g := New(DefaultConfig())
+	var plays []*cflogparser.RTMPPlay
+	z := cflogparser.NewRTMPSessionizer(nil, func(p *cflogparser.RTMPPlay) { plays = append(plays, p) })
+	var last *cflogparser.RTMPLog
+	for i := 0; i < 2000; i++ {
+		want := g.RTMP()
+		got, err := cflogparser.ParseLineRTMP(g.Line(want))
+		if err != nil {
+			t.Fatalf("can't parse generated line: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("round trip failed:\n got %+v\nwant %+v", got, want)
+		}
+		if last != nil && got.Time.Before(last.Time) {
+			t.Fatalf("not in chronological order: %s after %s", got.Time, last.Time)
+		}
+		last = got
+		z.Add(got)
+	}
+	z.Flush()
+	if len(plays) < 100 {
+		t.Errorf("got only %d plays", len(plays))
+	}
+}
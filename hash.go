@@ -0,0 +1,52 @@
+package cflogparser
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// hash64 returns a well-mixed 64-bit hash of b. It mixes 8-byte blocks in
+// the way of MurmurHash3 and finishes with its fmix64, but is not
+// MurmurHash3 itself. It takes no seed, so that results are stable across
+// processes and can be serialized and merged.
+func hash64(b []byte) uint64 {
+	const (
+		c1 = 0x87c37b91114253d5
+		c2 = 0x4cf5ad432745937f
+	)
+	h := uint64(len(b))
+	for len(b) >= 8 {
+		k := binary.LittleEndian.Uint64(b)
+		k *= c1
+		k = bits.RotateLeft64(k, 31)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft64(h, 27)*5 + 0x52dce729
+		b = b[8:]
+	}
+	if len(b) > 0 {
+		var k uint64
+		for i := len(b) - 1; i >= 0; i-- {
+			k = k<<8 | uint64(b[i])
+		}
+		k *= c1
+		k = bits.RotateLeft64(k, 31)
+		k *= c2
+		h ^= k
+	}
+	return fmix64(h)
+}
+
+// hash64String works as the same as hash64, but takes a string.
+func hash64String(s string) uint64 {
+	return hash64([]byte(s))
+}
+
+func fmix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,157 @@
+package cflogparser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomIPs generates n random IPv4 addresses, which may contain duplicates,
+// and returns them with the exact number of distinct ones.
+func randomIPs(r *rand.Rand, n int) ([]string, int) {
+	ips := make([]string, n)
+	exact := map[string]bool{}
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.%d.%d.%d", r.Intn(64), r.Intn(256), r.Intn(256))
+		exact[ips[i]] = true
+	}
+	return ips, len(exact)
+}
+
+func TestHyperLogLogAccuracy(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, p := range []uint8{10, 12, 14} {
+		for _, n := range []int{100, 5000, 200000} {
+			h, err := NewHyperLogLog(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ips, exact := randomIPs(r, n)
+			for _, ip := range ips {
+				h.AddString(ip)
+			}
+			got := h.Count()
+			stderr := 1.04 / math.Sqrt(float64(uint(1)<<p))
+			if e := math.Abs(float64(got)-float64(exact)) / float64(exact); e > 3*stderr {
+				t.Errorf("precision %d: got %d, want %d (error %.4f > %.4f)", p, got, exact, e, 3*stderr)
+			}
+		}
+	}
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	ips, exact := randomIPs(r, 50000)
+	a, _ := NewHyperLogLog(14)
+	b, _ := NewHyperLogLog(14)
+	whole, _ := NewHyperLogLog(14)
+	for i, ip := range ips {
+		if i%2 == 0 {
+			a.AddString(ip)
+		} else {
+			b.AddString(ip)
+		}
+		whole.AddString(ip)
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Count() != whole.Count() {
+		t.Errorf("merged count %d differs from count of whole %d", a.Count(), whole.Count())
+	}
+	if e := math.Abs(float64(a.Count())-float64(exact)) / float64(exact); e > 0.03 {
+		t.Errorf("got %d, want %d", a.Count(), exact)
+	}
+
+	c, _ := NewHyperLogLog(12)
+	if err := a.Merge(c); err == nil {
+		t.Error("merging different precisions should fail")
+	}
+}
+
+func TestHyperLogLogMarshal(t *testing.T) {
+	h, _ := NewHyperLogLog(8)
+	for i := 0; i < 1000; i++ {
+		h.AddString(fmt.Sprint(i))
+	}
+	b, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h2 HyperLogLog
+	if err := h2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if h2.Precision() != 8 || h2.Count() != h.Count() {
+		t.Errorf("got precision %d count %d, want 8 and %d", h2.Precision(), h2.Count(), h.Count())
+	}
+	if err := h2.UnmarshalBinary(b[:10]); err == nil {
+		t.Error("truncated data should fail")
+	}
+}
+
+func TestInvalidPrecision(t *testing.T) {
+	for _, p := range []uint8{0, 3, 19} {
+		if _, err := NewHyperLogLog(p); err == nil {
+			t.Errorf("precision %d should be rejected", p)
+		}
+	}
+}
+
+func TestDistinctCounter(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	days := []string{"2019-10-01", "2019-10-02", "2019-10-03"}
+	exact := map[string]map[string]bool{}
+	c1, _ := NewDistinctCounter(14)
+	c2, _ := NewDistinctCounter(14)
+	for i := 0; i < 30000; i++ {
+		day := days[r.Intn(len(days))]
+		ip := fmt.Sprintf("192.0.%d.%d", r.Intn(40), r.Intn(256))
+		if exact[day] == nil {
+			exact[day] = map[string]bool{}
+		}
+		exact[day][ip] = true
+		if i < 15000 {
+			c1.Add(day, ip)
+		} else {
+			c2.Add(day, ip)
+		}
+	}
+
+	b, err := c2.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored DistinctCounter
+	if err := restored.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := c1.Merge(&restored); err != nil {
+		t.Fatal(err)
+	}
+
+	if gs := c1.Groups(); len(gs) != len(days) {
+		t.Errorf("got groups %v, want %v", gs, days)
+	}
+	for _, day := range days {
+		got, want := c1.Count(day), len(exact[day])
+		if e := math.Abs(float64(got)-float64(want)) / float64(want); e > 0.03 {
+			t.Errorf("%s: got %d, want %d", day, got, want)
+		}
+	}
+	if c1.Count("no-such-day") != 0 {
+		t.Error("unknown group should count 0")
+	}
+
+	// Lengths of group names too large to fit, including ones overflowing
+	// when added to the size of registers.
+	for _, n := range []uint64{uint64(len(b)), 1<<64 - 3} {
+		d := binary.AppendUvarint([]byte{b[0], b[1]}, n)
+		d = append(d, make([]byte, 1<<14)...)
+		if err := restored.UnmarshalBinary(d); err == nil {
+			t.Errorf("expected error for length %d", n)
+		}
+	}
+}
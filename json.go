@@ -0,0 +1,303 @@
+package cflogparser
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// JSONOptions configures AppendJSON. The zero value produces the same JSON
+// as encoding/json does with the struct tags.
+type JSONOptions struct {
+	// EpochMillis encodes time as milliseconds since the Unix epoch, instead
+	// of string in RFC3339.
+	EpochMillis bool
+	// OmitEmpty omits properties of empty strings, zero numbers and nil IP
+	// addresses.
+	OmitEmpty bool
+	// CamelCase makes keys in camel case, e.g. "requestIp", instead of the
+	// snake case of the JSON tags.
+	CamelCase bool
+}
+
+// Keys of properties, quoted and followed by colon, in snake case and camel
+// case
+var (
+	webJSONKeys  = jsonKeys(WebLogFields)
+	rtmpJSONKeys = jsonKeys(RTMPLogFields)
+)
+
+func jsonKeys(fs []Field) [2][]string {
+	var keys [2][]string
+	for _, f := range fs {
+		keys[0] = append(keys[0], strconv.Quote(f.Name)+":")
+		keys[1] = append(keys[1], strconv.Quote(camelCase(f.Name))+":")
+	}
+	return keys
+}
+
+// camelCase converts snake_case into camelCase.
+func camelCase(s string) string {
+	words := strings.Split(s, "_")
+	for i := 1; i < len(words); i++ {
+		if words[i] != "" {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// MarshalJSON implements json.Marshaler without reflection. The result is
+// the same as the default of encoding/json.
+func (l *WebLog) MarshalJSON() ([]byte, error) {
+	return l.AppendJSON(nil, JSONOptions{}), nil
+}
+
+// AppendJSON appends l encoded as JSON object to b, and returns the
+// extended buffer.
+func (l *WebLog) AppendJSON(b []byte, opts JSONOptions) []byte {
+	e := newJSONEncoder(b, opts, webJSONKeys)
+	e.time(0, l.Time)
+	e.string(1, l.Location)
+	e.uint(2, l.Bytes)
+	e.ip(3, l.RequestIP)
+	e.string(4, l.Method)
+	e.string(5, l.Host)
+	e.string(6, l.URI)
+	e.uint(7, uint64(l.Status))
+	e.string(8, l.Referrer)
+	e.string(9, l.UserAgent)
+	e.string(10, l.QueryString)
+	e.string(11, l.Cookie)
+	e.string(12, l.ResultType)
+	e.string(13, l.RequestID)
+	e.string(14, l.HostHeader)
+	e.string(15, l.RequestProtocol)
+	e.uint(16, l.RequestBytes)
+	e.float(17, l.TimeTaken)
+	e.string(18, l.XforwardedFor)
+	e.string(19, l.SslProtocol)
+	e.string(20, l.SslCipher)
+	e.string(21, l.ResponseResultType)
+	e.string(22, l.HTTPVersion)
+	e.string(23, l.FleStatus)
+	e.uint(24, uint64(l.FleEncryptedFields))
+	return append(e.b, '}')
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts JSON written by
+// AppendJSON with any options: keys in snake case or camel case, and time
+// in RFC3339 or in milliseconds since the Unix epoch. Missing properties
+// are left zero.
+func (l *WebLog) UnmarshalJSON(b []byte) error {
+	*l = WebLog{}
+	return unmarshalJSON(b, reflect.ValueOf(l).Elem(), WebLogFields)
+}
+
+// MarshalJSON implements json.Marshaler without reflection. The result is
+// the same as the default of encoding/json.
+func (l *RTMPLog) MarshalJSON() ([]byte, error) {
+	return l.AppendJSON(nil, JSONOptions{}), nil
+}
+
+// AppendJSON appends l encoded as JSON object to b, and returns the
+// extended buffer.
+func (l *RTMPLog) AppendJSON(b []byte, opts JSONOptions) []byte {
+	e := newJSONEncoder(b, opts, rtmpJSONKeys)
+	e.time(0, l.Time)
+	e.string(1, l.Location)
+	e.ip(2, l.RequestIP)
+	e.string(3, l.EventType)
+	e.uint(4, l.Bytes)
+	e.string(5, l.Status)
+	e.string(6, l.ClientID)
+	e.string(7, l.URI)
+	e.string(8, l.QueryString)
+	e.string(9, l.Referrer)
+	e.string(10, l.PageURL)
+	e.string(11, l.UserAgent)
+	e.string(12, l.StreamName)
+	e.string(13, l.StreamQuery)
+	e.string(14, l.StreamFileExt)
+	e.uint(15, uint64(l.StreamID))
+	return append(e.b, '}')
+}
+
+// UnmarshalJSON implements json.Unmarshaler. See WebLog.UnmarshalJSON.
+func (l *RTMPLog) UnmarshalJSON(b []byte) error {
+	*l = RTMPLog{}
+	return unmarshalJSON(b, reflect.ValueOf(l).Elem(), RTMPLogFields)
+}
+
+// jsonEncoder appends properties of an object to b. Each method takes the
+// index of field to look up the key.
+type jsonEncoder struct {
+	b     []byte
+	opts  JSONOptions
+	keys  []string
+	comma bool
+}
+
+func newJSONEncoder(b []byte, opts JSONOptions, keys [2][]string) jsonEncoder {
+	e := jsonEncoder{b: append(b, '{'), opts: opts, keys: keys[0]}
+	if opts.CamelCase {
+		e.keys = keys[1]
+	}
+	return e
+}
+
+func (e *jsonEncoder) key(i int) {
+	if e.comma {
+		e.b = append(e.b, ',')
+	}
+	e.comma = true
+	e.b = append(e.b, e.keys[i]...)
+}
+
+func (e *jsonEncoder) string(i int, s string) {
+	if s == "" && e.opts.OmitEmpty {
+		return
+	}
+	e.key(i)
+	e.b = appendJSONQuote(e.b, s, true)
+}
+
+func (e *jsonEncoder) uint(i int, v uint64) {
+	if v == 0 && e.opts.OmitEmpty {
+		return
+	}
+	e.key(i)
+	e.b = strconv.AppendUint(e.b, v, 10)
+}
+
+// float formats f in the same way as encoding/json does.
+func (e *jsonEncoder) float(i int, f float32) {
+	if f == 0 && e.opts.OmitEmpty {
+		return
+	}
+	e.key(i)
+	format := byte('f')
+	if abs := float32(math.Abs(float64(f))); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	e.b = strconv.AppendFloat(e.b, float64(f), format, -1, 32)
+	if n := len(e.b); format == 'e' && e.b[n-4] == 'e' && e.b[n-3] == '-' && e.b[n-2] == '0' {
+		// Clean up e-09 to e-9
+		e.b[n-2] = e.b[n-1]
+		e.b = e.b[:n-1]
+	}
+}
+
+func (e *jsonEncoder) time(i int, t time.Time) {
+	if t.IsZero() && e.opts.OmitEmpty {
+		return
+	}
+	e.key(i)
+	if e.opts.EpochMillis {
+		e.b = strconv.AppendInt(e.b, t.Unix()*1000+int64(t.Nanosecond())/1e6, 10)
+		return
+	}
+	e.b = append(e.b, '"')
+	e.b = t.AppendFormat(e.b, time.RFC3339Nano)
+	e.b = append(e.b, '"')
+}
+
+func (e *jsonEncoder) ip(i int, ip net.IP) {
+	if ip == nil && e.opts.OmitEmpty {
+		return
+	}
+	e.key(i)
+	e.b = appendJSONQuote(e.b, ipString(ip), true)
+}
+
+// appendJSONQuote appends s as JSON string, escaping characters in the same
+// way as encoding/json does, including invalid UTF-8. HTML characters are
+// escaped only if escapeHTML is true.
+func appendJSONQuote(b []byte, s string, escapeHTML bool) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && (!escapeHTML || c != '<' && c != '>' && c != '&') {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid in JSON, but not in JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+// unmarshalJSON decodes properties of object b into fields of v.
+func unmarshalJSON(b []byte, v reflect.Value, fields []Field) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		raw, ok := m[f.Name]
+		if !ok {
+			if raw, ok = m[camelCase(f.Name)]; !ok {
+				continue
+			}
+		}
+		fv := v.Field(f.index)
+		if t, ok := fv.Addr().Interface().(*time.Time); ok && len(raw) > 0 && raw[0] != '"' && raw[0] != 'n' {
+			// Milliseconds since the Unix epoch
+			var ms int64
+			if err := json.Unmarshal(raw, &ms); err != nil {
+				return err
+			}
+			*t = time.Unix(ms/1000, ms%1000*1e6).UTC()
+			continue
+		}
+		if err := json.Unmarshal(raw, fv.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
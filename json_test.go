@@ -0,0 +1,119 @@
+package cflogparser
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Types without MarshalJSON, to get JSON by reflection of encoding/json
+type (
+	plainWebLog  WebLog
+	plainRTMPLog RTMPLog
+)
+
+func plainJSON(t *testing.T, rec interface{}) string {
+	t.Helper()
+	var v interface{}
+	switch l := rec.(type) {
+	case *WebLog:
+		v = (*plainWebLog)(l)
+	case *RTMPLog:
+		v = (*plainRTMPLog)(l)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func appendJSON(rec interface{}, opts JSONOptions) []byte {
+	switch l := rec.(type) {
+	case *WebLog:
+		return l.AppendJSON(nil, opts)
+	case *RTMPLog:
+		return l.AppendJSON(nil, opts)
+	}
+	return nil
+}
+
+func TestMarshalJSONCompat(t *testing.T) {
+	recs := codecRecords(t)
+	recs = append(recs,
+		&WebLog{},
+		&WebLog{URI: "<a&b>\"\\\x01\x1f\t\n\r\u2028\u2029\xff€/", TimeTaken: 1e-7},
+		&WebLog{Time: time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC), TimeTaken: 123456.79},
+		&WebLog{TimeTaken: 1e22},
+		&RTMPLog{StreamName: "テスト"},
+	)
+	for i, rec := range recs {
+		want := plainJSON(t, rec)
+		if got := string(appendJSON(rec, JSONOptions{})); got != want {
+			t.Errorf("record %d:\ngot  %s\nwant %s", i, got, want)
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("record %d: json.Marshal:\ngot  %s\nwant %s", i, b, want)
+		}
+	}
+}
+
+func TestAppendJSONOptions(t *testing.T) {
+	l := &WebLog{
+		Time:      time.Date(2014, 5, 23, 1, 13, 11, 0, time.UTC),
+		URI:       "/index.html",
+		Status:    200,
+		TimeTaken: 0.5,
+	}
+	got := string(l.AppendJSON([]byte("x"), JSONOptions{EpochMillis: true, OmitEmpty: true, CamelCase: true}))
+	want := `x{"time":1400807591000,"uri":"/index.html","status":200,"timeTaken":0.5}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	got = string(l.AppendJSON(nil, JSONOptions{CamelCase: true}))
+	for _, key := range []string{`"requestIp":""`, `"fleEncryptedFields":0`, `"xforwardedFor":""`} {
+		if !strings.Contains(got, key) {
+			t.Errorf("missing %s in %s", key, got)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var opts []JSONOptions
+	for i := 0; i < 8; i++ {
+		opts = append(opts, JSONOptions{EpochMillis: i&1 != 0, OmitEmpty: i&2 != 0, CamelCase: i&4 != 0})
+	}
+	for i, want := range codecRecords(t) {
+		for _, o := range opts {
+			b := appendJSON(want, o)
+			var got interface{}
+			var err error
+			switch want.(type) {
+			case *WebLog:
+				l := &WebLog{Location: "garbage"}
+				err, got = json.Unmarshal(b, l), l
+			case *RTMPLog:
+				l := &RTMPLog{Location: "garbage"}
+				err, got = json.Unmarshal(b, l), l
+			}
+			if err != nil {
+				t.Fatalf("record %d %+v: %s", i, o, err)
+			}
+			if g, w := plainJSON(t, got), plainJSON(t, want); g != w {
+				t.Errorf("record %d %+v:\ngot  %s\nwant %s", i, o, g, w)
+			}
+		}
+	}
+	var l WebLog
+	if err := json.Unmarshal([]byte(`{"status":"200"}`), &l); err == nil {
+		t.Error("expected error for string status")
+	}
+	if err := json.Unmarshal([]byte(`{"request_ip":"x"}`), &l); err == nil {
+		t.Error("expected error for invalid IP address")
+	}
+}
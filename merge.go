@@ -0,0 +1,206 @@
+package cflogparser
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RecordReader is implemented by Reader and MergeReader.
+type RecordReader interface {
+	Read() (interface{}, error)
+	Line() string
+	Close() error
+}
+
+// MergeReader merges records of multiple RecordReaders, each of which must
+// be in chronological order, into a single stream in global chronological
+// order. Records at the same time are ordered by the index of reader, and
+// then by their order in the reader, so the result is deterministic.
+type MergeReader struct {
+	readers []RecordReader
+	heap    mergeHeap
+	started bool
+	line    string
+	err     []error
+	temps   []string
+}
+
+type mergeItem struct {
+	rec  interface{}
+	time time.Time
+	line string
+	src  int
+}
+
+// NewMergeReader returns a MergeReader of rs.
+func NewMergeReader(rs ...RecordReader) *MergeReader {
+	return &MergeReader{readers: rs}
+}
+
+// fill reads the next record from the i-th reader into the heap. Errors
+// are kept to be returned by Read. Parse errors are skipped, while other
+// errors end the reader.
+func (m *MergeReader) fill(i int) {
+	for {
+		rec, err := m.readers[i].Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			m.err = append(m.err, err)
+			var pe *ParseError
+			if errors.As(err, &pe) {
+				continue
+			}
+			return
+		}
+		heap.Push(&m.heap, &mergeItem{rec: rec, time: RecordTime(rec), line: m.readers[i].Line(), src: i})
+		return
+	}
+}
+
+// Read returns the next record. It returns io.EOF at the end of all inputs,
+// and *ParseError for a malformed line, after which reading can be
+// continued. Other errors of a reader are returned after the records read
+// before them, and the rest of the readers can still be read.
+func (m *MergeReader) Read() (interface{}, error) {
+	if !m.started {
+		m.started = true
+		for i := range m.readers {
+			m.fill(i)
+		}
+	}
+	if len(m.err) > 0 {
+		err := m.err[0]
+		m.err = m.err[1:]
+		return nil, err
+	}
+	if len(m.heap) == 0 {
+		return nil, io.EOF
+	}
+	it := heap.Pop(&m.heap).(*mergeItem)
+	m.line = it.line
+	m.fill(it.src)
+	return it.rec, nil
+}
+
+// Line returns the raw line of the record last read.
+func (m *MergeReader) Line() string {
+	return m.line
+}
+
+// Close closes all readers and removes temporary files made by SortReader.
+func (m *MergeReader) Close() error {
+	var err error
+	for _, r := range m.readers {
+		if e := r.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	for _, t := range m.temps {
+		os.Remove(t)
+	}
+	return err
+}
+
+type mergeHeap []*mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].time.Equal(h[j].time) {
+		return h[i].time.Before(h[j].time)
+	}
+	return h[i].src < h[j].src
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*mergeItem))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// SortReader sorts lines of log in r in chronological order by external
+// merge sort, for inputs that are out of order and too large to sort in
+// memory. It splits r into chunks of at most chunkSize lines (1000000 if
+// not positive), sorts each chunk stably and writes it to a temporary file
+// in dir (os.TempDir() if empty), and returns a MergeReader of the chunks.
+// Temporary files are removed when the MergeReader is closed. Comment lines
+// are dropped, and lines with invalid time are put first so that they are
+// reported as *ParseError earlier.
+func SortReader(r io.Reader, rtmp bool, chunkSize int, dir string) (*MergeReader, error) {
+	type entry struct {
+		time time.Time
+		line string
+	}
+	if chunkSize <= 0 {
+		chunkSize = 1000000
+	}
+	m := &MergeReader{}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	chunk := make([]entry, 0, chunkSize)
+
+	flush := func() error {
+		sort.SliceStable(chunk, func(i, j int) bool { return chunk[i].time.Before(chunk[j].time) })
+		f, err := ioutil.TempFile(dir, "cflogsort-")
+		if err != nil {
+			return err
+		}
+		m.temps = append(m.temps, f.Name())
+		w := bufio.NewWriter(f)
+		for _, e := range chunk {
+			w.WriteString(e.line)
+			w.WriteByte('\n')
+		}
+		if err := w.Flush(); err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return err
+		}
+		m.readers = append(m.readers, NewReader(f, rtmp))
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, _ := lineTime(line)
+		chunk = append(chunk, entry{t, line})
+		if len(chunk) >= chunkSize {
+			if err := flush(); err != nil {
+				m.Close()
+				return nil, err
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,140 @@
+package cflogparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// webLine returns a line of Web distribution log at the given time
+// ("HH:MM:SS" on 2019-10-01) with the given URI.
+func webLine(hms, uri string) string {
+	return fmt.Sprintf("2019-10-01\t%s\tFRA2\t182\t192.0.2.10\tGET\td111111abcdef8.cloudfront.net\t%s\t200\t-\tcurl/7.64.1\t-\t-\tHit\tid\td111111abcdef8.cloudfront.net\thttps\t100\t0.001\t-\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\tHit\tHTTP/1.1\t-\t-", hms, uri)
+}
+
+func webLines(lines ...string) string {
+	return "#Version: 1.0\n" + strings.Join(lines, "\n") + "\n"
+}
+
+func readURIs(t *testing.T, r RecordReader) []string {
+	t.Helper()
+	var uris []string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return uris
+		}
+		var pe *ParseError
+		if errors.As(err, &pe) {
+			uris = append(uris, "error")
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		uris = append(uris, rec.(*WebLog).URI)
+		if !strings.Contains(r.Line(), rec.(*WebLog).URI) {
+			t.Errorf("line does not match record: %s", r.Line())
+		}
+	}
+}
+
+func TestMergeReader(t *testing.T) {
+	a := NewReader(strings.NewReader(webLines(
+		webLine("00:00:00", "/a1"),
+		webLine("00:00:02", "/a2"),
+		webLine("00:00:02", "/a3"),
+	)), false)
+	b := NewReader(strings.NewReader(webLines(
+		webLine("00:00:01", "/b1"),
+		"broken",
+		webLine("00:00:02", "/b2"),
+		webLine("00:00:05", "/b3"),
+	)), false)
+	c := NewReader(strings.NewReader(""), false)
+	m := NewMergeReader(a, b, c)
+	got := strings.Join(readURIs(t, m), " ")
+	want := "/a1 /b1 error /a2 /a3 /b2 /b3"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if err := m.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+// failingReader returns err after n records of RecordReader.
+type failingReader struct {
+	RecordReader
+	n   int
+	err error
+}
+
+func (r *failingReader) Read() (interface{}, error) {
+	if r.n == 0 {
+		return nil, r.err
+	}
+	r.n--
+	return r.RecordReader.Read()
+}
+
+func TestMergeReaderError(t *testing.T) {
+	errRead := errors.New("read error")
+	a := &failingReader{RecordReader: NewReader(strings.NewReader(webLines(
+		webLine("00:00:00", "/a1"),
+		webLine("00:00:02", "/a2"),
+	)), false), n: 1, err: errRead}
+	b := NewReader(strings.NewReader(webLines(
+		webLine("00:00:01", "/b1"),
+		webLine("00:00:03", "/b2"),
+	)), false)
+	m := NewMergeReader(a, b)
+	var got []string
+	for {
+		rec, err := m.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if err != errRead {
+				t.Fatal(err)
+			}
+			got = append(got, "error")
+			continue
+		}
+		got = append(got, rec.(*WebLog).URI)
+	}
+	if s := strings.Join(got, " "); s != "/a1 error /b1 /b2" {
+		t.Errorf("got %s, want /a1 error /b1 /b2", s)
+	}
+}
+
+func TestSortReader(t *testing.T) {
+	in := webLines(
+		webLine("00:00:03", "/3"),
+		webLine("00:00:01", "/1a"),
+		webLine("00:00:04", "/4"),
+		webLine("00:00:01", "/1b"),
+		"broken",
+		webLine("00:00:02", "/2"),
+		webLine("00:00:00", "/0"),
+		webLine("00:00:01", "/1c"),
+	)
+	m, err := SortReader(strings.NewReader(in), false, 3, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(readURIs(t, m), " ")
+	want := "error /0 /1a /1b /1c /2 /3 /4"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if len(m.temps) != 3 {
+		t.Errorf("got %d chunks, want 3", len(m.temps))
+	}
+	if err := m.Close(); err != nil {
+		t.Error(err)
+	}
+}
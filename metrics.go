@@ -0,0 +1,168 @@
+package cflogparser
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MetricNames lists metrics that can be taken from BucketMetrics by Value.
+var MetricNames = []string{"requests", "error_rate", "bytes", "latency"}
+
+// BucketMetrics holds metrics of WebLogs in a time bucket starting at Time.
+type BucketMetrics struct {
+	Time         time.Time `json:"time"`
+	Requests     uint64    `json:"requests"`
+	Errors       uint64    `json:"errors"`
+	Bytes        uint64    `json:"bytes"`
+	TimeTakenSum float64   `json:"time_taken_sum"`
+	uris         *TopK
+	ips          *TopK
+}
+
+// ErrorRate returns the ratio of errors to requests. See IsError for what
+// is regarded as error.
+func (m *BucketMetrics) ErrorRate() float64 {
+	return ratio(m.Errors, m.Requests)
+}
+
+// MeanTimeTaken returns the average of TimeTaken in seconds.
+func (m *BucketMetrics) MeanTimeTaken() float64 {
+	if m.Requests == 0 {
+		return 0
+	}
+	return m.TimeTakenSum / float64(m.Requests)
+}
+
+// Value returns the metric named name, which must be one of MetricNames.
+func (m *BucketMetrics) Value(name string) (float64, error) {
+	switch name {
+	case "requests":
+		return float64(m.Requests), nil
+	case "error_rate":
+		return m.ErrorRate(), nil
+	case "bytes":
+		return float64(m.Bytes), nil
+	case "latency":
+		return m.MeanTimeTaken(), nil
+	}
+	return 0, fmt.Errorf("Unknown metric: %s", name)
+}
+
+// TopURIs returns top n URIs in the bucket. It returns nil if the Bucketer
+// does not track contributors.
+func (m *BucketMetrics) TopURIs(n int) []TopKEntry {
+	if m.uris == nil {
+		return nil
+	}
+	return m.uris.Top(n)
+}
+
+// TopIPs returns top n client IP addresses in the bucket. It returns nil if
+// the Bucketer does not track contributors.
+func (m *BucketMetrics) TopIPs(n int) []TopKEntry {
+	if m.ips == nil {
+		return nil
+	}
+	return m.ips.Top(n)
+}
+
+func (m *BucketMetrics) add(l *WebLog) {
+	m.Requests++
+	if IsError(l) {
+		m.Errors++
+	}
+	m.Bytes += l.Bytes
+	m.TimeTakenSum += float64(l.TimeTaken)
+	if m.uris != nil {
+		m.uris.Add(l.URI, 1)
+		m.ips.Add(l.RequestIP.String(), 1)
+	}
+}
+
+// Bucketer aggregates WebLogs into BucketMetrics per key, such as Host or
+// Location, and per time bucket of fixed interval.
+type Bucketer struct {
+	interval     time.Duration
+	key          func(*WebLog) string
+	contributors int
+	series       map[string]map[time.Time]*BucketMetrics
+}
+
+// NewBucketer returns an empty Bucketer. If key is nil, all logs are
+// aggregated under the empty key. If contributors is positive, each bucket
+// tracks top URIs and client IPs with TopK of that capacity. Interval must
+// be positive.
+func NewBucketer(interval time.Duration, key func(*WebLog) string, contributors int) (*Bucketer, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("Invalid interval: %s", interval)
+	}
+	if key == nil {
+		key = func(*WebLog) string { return "" }
+	}
+	return &Bucketer{
+		interval:     interval,
+		key:          key,
+		contributors: contributors,
+		series:       map[string]map[time.Time]*BucketMetrics{},
+	}, nil
+}
+
+// Add counts l.
+func (b *Bucketer) Add(l *WebLog) {
+	k := b.key(l)
+	s := b.series[k]
+	if s == nil {
+		s = map[time.Time]*BucketMetrics{}
+		b.series[k] = s
+	}
+	t := l.Time.Truncate(b.interval)
+	m := s[t]
+	if m == nil {
+		m = &BucketMetrics{Time: t}
+		if b.contributors > 0 {
+			m.uris = NewTopK(b.contributors)
+			m.ips = NewTopK(b.contributors)
+		}
+		s[t] = m
+	}
+	m.add(l)
+}
+
+// Keys returns all keys in sorted order.
+func (b *Bucketer) Keys() []string {
+	ks := make([]string, 0, len(b.series))
+	for k := range b.series {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+// Series returns metrics of key in chronological order. Buckets without
+// any log between the first and the last are filled with zero metrics,
+// so that the series is sampled at regular intervals.
+func (b *Bucketer) Series(key string) []*BucketMetrics {
+	s := b.series[key]
+	if len(s) == 0 {
+		return nil
+	}
+	var first, last time.Time
+	for t := range s {
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	ms := make([]*BucketMetrics, 0, int(last.Sub(first)/b.interval)+1)
+	for t := first; !t.After(last); t = t.Add(b.interval) {
+		m := s[t]
+		if m == nil {
+			m = &BucketMetrics{Time: t}
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
@@ -0,0 +1,72 @@
+package cflogparser
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBucketer(t *testing.T) {
+	base := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	b, err := NewBucketer(time.Minute, func(l *WebLog) string { return l.Location }, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := func(sec int, loc, uri, ip string, status uint16, bytes uint64, taken float32) {
+		b.Add(&WebLog{
+			Time:      base.Add(time.Duration(sec) * time.Second),
+			Location:  loc,
+			URI:       uri,
+			RequestIP: net.ParseIP(ip),
+			Status:    status,
+			Bytes:     bytes,
+			TimeTaken: taken,
+		})
+	}
+	add(0, "NRT20", "/a", "192.0.2.1", 200, 100, 0.25)
+	add(30, "NRT20", "/a", "192.0.2.2", 500, 100, 0.75)
+	add(150, "NRT20", "/b", "192.0.2.1", 200, 300, 0.5)
+	add(10, "FRA2", "/a", "192.0.2.3", 200, 10, 0.5)
+
+	if ks := b.Keys(); !reflect.DeepEqual(ks, []string{"FRA2", "NRT20"}) {
+		t.Errorf("got keys %v", ks)
+	}
+	s := b.Series("NRT20")
+	if len(s) != 3 {
+		t.Fatalf("got %d buckets, want 3", len(s))
+	}
+	if !s[1].Time.Equal(base.Add(time.Minute)) || s[1].Requests != 0 {
+		t.Errorf("missing bucket should be filled with zero: %+v", s[1])
+	}
+	m := s[0]
+	if m.Requests != 2 || m.Errors != 1 || m.Bytes != 200 || m.ErrorRate() != 0.5 || m.MeanTimeTaken() != 0.5 {
+		t.Errorf("unexpected metrics: %+v", m)
+	}
+	for _, test := range []struct {
+		name string
+		out  float64
+	}{{"requests", 2}, {"error_rate", 0.5}, {"bytes", 200}, {"latency", 0.5}} {
+		if v, err := m.Value(test.name); err != nil || v != test.out {
+			t.Errorf("%s: got %f (%v), want %f", test.name, v, err, test.out)
+		}
+	}
+	if _, err := m.Value("foo"); err == nil {
+		t.Error("unknown metric should fail")
+	}
+	if us := m.TopURIs(1); len(us) != 1 || us[0].Key != "/a" || us[0].Count != 2 {
+		t.Errorf("unexpected URIs: %+v", us)
+	}
+	if ips := m.TopIPs(5); len(ips) != 2 {
+		t.Errorf("unexpected IPs: %+v", ips)
+	}
+	if b.Series("HKG62") != nil {
+		t.Error("unknown key should have no series")
+	}
+}
+
+func TestNewBucketerInvalidInterval(t *testing.T) {
+	if _, err := NewBucketer(0, nil, 0); err == nil {
+		t.Error("expected error for zero interval")
+	}
+}
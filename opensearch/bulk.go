@@ -0,0 +1,220 @@
+package opensearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// DefaultIndex is the default of Options.Index.
+const DefaultIndex = "cloudfront-{2006.01.02}"
+
+// Options configures BulkWriter.
+type Options struct {
+	// Index is the name of index. A part enclosed in braces is a layout of
+	// time.Format, replaced by the date of each record in UTC, e.g.
+	// "cloudfront-{2006.01.02}" for daily indexes. It is DefaultIndex if
+	// empty.
+	Index string
+}
+
+// IndexName returns the name of index for l.
+func (o Options) IndexName(l *cflogparser.WebLog) string {
+	index := o.Index
+	if index == "" {
+		index = DefaultIndex
+	}
+	i := strings.IndexByte(index, '{')
+	j := strings.IndexByte(index, '}')
+	if i < 0 || j < i {
+		return index
+	}
+	return index[:i] + l.Time.UTC().Format(index[i+1:j]) + index[j+1:]
+}
+
+// BulkWriter writes records as a body of _bulk API: each record is written
+// in an action line and a document line. RequestID is used as the document
+// ID, so that indexing the same records again doesn't make duplicates.
+type BulkWriter struct {
+	opts Options
+	w    io.Writer
+	buf  []byte
+}
+
+// NewBulkWriter returns a BulkWriter writing to w.
+func NewBulkWriter(w io.Writer, opts Options) *BulkWriter {
+	return &BulkWriter{opts: opts, w: w}
+}
+
+// Write writes rec, which must be *WebLog.
+func (bw *BulkWriter) Write(rec interface{}) error {
+	l, ok := rec.(*cflogparser.WebLog)
+	if !ok {
+		return fmt.Errorf("Unexpected record type: %T", rec)
+	}
+	meta := map[string]string{"_index": bw.opts.IndexName(l)}
+	if l.RequestID != "" {
+		meta["_id"] = l.RequestID
+	}
+	action, err := json.Marshal(map[string]interface{}{"index": meta})
+	if err != nil {
+		return err
+	}
+	doc, err := json.Marshal(Document(l))
+	if err != nil {
+		return err
+	}
+	b := append(bw.buf[:0], action...)
+	b = append(b, '\n')
+	b = append(b, doc...)
+	b = append(b, '\n')
+	bw.buf = b
+	_, err = bw.w.Write(b)
+	return err
+}
+
+// Client calls APIs of Elasticsearch or OpenSearch.
+type Client struct {
+	// URL is the base URL, e.g. "http://localhost:9200". User and password
+	// for basic authentication can be given in it.
+	URL string
+	// HTTPClient is used for requests. It is http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// BulkError is returned by Client.Bulk if some of actions failed.
+type BulkError struct {
+	Failed int    // Number of failed actions
+	Reason string // Reason of the first failure
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d actions failed: %s", e.Failed, e.Reason)
+}
+
+// Bulk sends body of _bulk API. If some of actions fail, it returns
+// *BulkError.
+func (c *Client) Bulk(body []byte) error {
+	res, err := c.do("POST", "/_bulk", "application/x-ndjson", body)
+	if err != nil {
+		return err
+	}
+	var r struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(res, &r); err != nil {
+		return err
+	}
+	if !r.Errors {
+		return nil
+	}
+	e := &BulkError{}
+	for _, item := range r.Items {
+		for _, result := range item {
+			if result.Status >= 300 {
+				if e.Failed == 0 {
+					e.Reason = string(result.Error)
+				}
+				e.Failed++
+			}
+		}
+	}
+	return e
+}
+
+// PutIndexTemplate puts body, e.g. of IndexTemplate, as the index template
+// named name.
+func (c *Client) PutIndexTemplate(name string, body []byte) error {
+	_, err := c.do("PUT", "/_index_template/"+name, "application/json", body)
+	return err
+}
+
+func (c *Client) do(method, path, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(c.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, res.Status, bytes.TrimSpace(b))
+	}
+	return b, nil
+}
+
+// Indexer sends records to Client by _bulk API in batches.
+type Indexer struct {
+	c    *Client
+	bw   *BulkWriter
+	buf  bytes.Buffer
+	n    int
+	size int
+
+	// Indexed and Failed count records sent so far.
+	Indexed, Failed int
+}
+
+// NewIndexer returns an Indexer sending batches of size records to c.
+func NewIndexer(c *Client, opts Options, size int) *Indexer {
+	ix := &Indexer{c: c, size: size}
+	ix.bw = NewBulkWriter(&ix.buf, opts)
+	return ix
+}
+
+// Write adds rec, which must be *WebLog, to the batch, and sends the batch
+// if it gets full.
+func (ix *Indexer) Write(rec interface{}) error {
+	if err := ix.bw.Write(rec); err != nil {
+		return err
+	}
+	ix.n++
+	if ix.n >= ix.size {
+		return ix.Flush()
+	}
+	return nil
+}
+
+// Flush sends records in the batch, if any. *BulkError is returned if some
+// of them failed, after which Indexer can be used further.
+func (ix *Indexer) Flush() error {
+	if ix.n == 0 {
+		return nil
+	}
+	err := ix.c.Bulk(ix.buf.Bytes())
+	n := ix.n
+	ix.buf.Reset()
+	ix.n = 0
+	if be, ok := err.(*BulkError); ok {
+		ix.Failed += be.Failed
+		ix.Indexed += n - be.Failed
+		return err
+	}
+	if err != nil {
+		ix.Failed += n
+		return err
+	}
+	ix.Indexed += n
+	return nil
+}
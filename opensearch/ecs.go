@@ -0,0 +1,172 @@
+// Package opensearch indexes WebLog into Elasticsearch or OpenSearch by the
+// _bulk API, with fields mapped into Elastic Common Schema (ECS).
+//
+// Fields which ECS doesn't define, such as edge location and result type,
+// are put under "aws.cloudfront", as Filebeat does.
+package opensearch
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// ecsField maps a field of WebLog into a field of ECS.
+type ecsField struct {
+	name  string // Dotted name in ECS
+	field string // JSON name of WebLog field
+	typ   string // Type of mapping
+	conv  func(interface{}) interface{}
+}
+
+var ecsFields = []ecsField{
+	{"@timestamp", "time", "date", nil},
+	{"source.ip", "request_ip", "ip", nil},
+	{"http.request.method", "method", "keyword", nil},
+	{"http.request.bytes", "request_bytes", "long", nil},
+	{"http.request.referrer", "referrer", "keyword", nil},
+	{"http.request.id", "request_id", "keyword", nil},
+	{"http.response.status_code", "status", "long", nil},
+	{"http.response.bytes", "bytes", "long", nil},
+	{"http.version", "http_version", "keyword", trimPrefix("HTTP/")},
+	{"url.domain", "host_header", "keyword", nil},
+	{"url.path", "uri", "keyword", nil},
+	{"url.query", "query_string", "keyword", nil},
+	{"url.scheme", "request_protocol", "keyword", nil},
+	{"user_agent.original", "user_agent", "keyword", nil},
+	{"network.forwarded_ip", "xforwarded_for", "keyword", nil},
+	{"tls.version", "ssl_protocol", "keyword", trimPrefix("TLSv")},
+	{"tls.cipher", "ssl_cipher", "keyword", nil},
+	{"event.duration", "time_taken", "long", secondsToNanos},
+	{"aws.cloudfront.edge_location", "location", "keyword", nil},
+	{"aws.cloudfront.domain", "host", "keyword", nil},
+	{"aws.cloudfront.cookie", "cookie", "keyword", nil},
+	{"aws.cloudfront.edge_result_type", "result_type", "keyword", nil},
+	{"aws.cloudfront.edge_response_result_type", "response_result_type", "keyword", nil},
+	{"aws.cloudfront.fle_status", "fle_status", "keyword", nil},
+	{"aws.cloudfront.fle_encrypted_fields", "fle_encrypted_fields", "long", nil},
+}
+
+// Fields of WebLog in the order of ecsFields
+var webFields []cflogparser.Field
+
+func init() {
+	for _, e := range ecsFields {
+		f, err := cflogparser.LookupField(&cflogparser.WebLog{}, e.field)
+		if err != nil {
+			panic(err)
+		}
+		webFields = append(webFields, f)
+	}
+}
+
+func trimPrefix(prefix string) func(interface{}) interface{} {
+	return func(v interface{}) interface{} {
+		return strings.TrimPrefix(v.(string), prefix)
+	}
+}
+
+// secondsToNanos converts TimeTaken in seconds into nanoseconds, the unit of
+// event.duration, rounding off errors of float32 in microseconds.
+func secondsToNanos(v interface{}) interface{} {
+	return int64(float64(v.(float32))*1e6+0.5) * 1000
+}
+
+// Document returns l as a document in ECS. Empty strings and zero numbers
+// are omitted, except for status and bytes.
+func Document(l *cflogparser.WebLog) map[string]interface{} {
+	doc := map[string]interface{}{
+		"ecs":   map[string]interface{}{"version": "8.0.0"},
+		"event": map[string]interface{}{"kind": "event", "category": []string{"web"}, "dataset": "aws.cloudfront"},
+	}
+	for i, e := range ecsFields {
+		v := webFields[i].Value(l)
+		switch x := v.(type) {
+		case time.Time:
+			v = x.UTC().Format(time.RFC3339)
+		case net.IP:
+			if x == nil {
+				continue
+			}
+			v = x.String()
+		case string:
+			if x == "" {
+				continue
+			}
+		case float32:
+			if x == 0 {
+				continue
+			}
+		case uint32:
+			if x == 0 {
+				continue
+			}
+		case uint64:
+			if x == 0 && e.field == "request_bytes" {
+				continue
+			}
+		}
+		if e.conv != nil {
+			v = e.conv(v)
+		}
+		put(doc, e.name, v)
+	}
+	return doc
+}
+
+// put sets v at dotted name in nested objects of m.
+func put(m map[string]interface{}, name string, v interface{}) {
+	keys := strings.Split(name, ".")
+	for _, k := range keys[:len(keys)-1] {
+		sub, ok := m[k].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			m[k] = sub
+		}
+		m = sub
+	}
+	m[keys[len(keys)-1]] = v
+}
+
+// IndexTemplate returns a body of composable index template for indexes
+// matching patterns, e.g. "cloudfront-*", with mappings of fields of
+// Document. It is to be put by PUT _index_template/<name>.
+func IndexTemplate(patterns ...string) ([]byte, error) {
+	props := map[string]interface{}{}
+	types := map[string]string{
+		"ecs.version":    "keyword",
+		"event.kind":     "keyword",
+		"event.category": "keyword",
+		"event.dataset":  "keyword",
+	}
+	for _, e := range ecsFields {
+		types[e.name] = e.typ
+	}
+	for name, typ := range types {
+		path := "properties." + strings.Replace(name, ".", ".properties.", -1)
+		m := map[string]interface{}{"type": typ}
+		if typ == "keyword" {
+			m["ignore_above"] = 1024
+		}
+		put(props, path, m)
+	}
+	// Text for full-text search on user agent
+	put(props, "properties.user_agent.properties.original.fields", map[string]interface{}{
+		"text": map[string]interface{}{"type": "text"},
+	})
+	return json.MarshalIndent(map[string]interface{}{
+		"index_patterns": patterns,
+		"template": map[string]interface{}{
+			"settings": map[string]interface{}{
+				"index": map[string]interface{}{"codec": "best_compression"},
+			},
+			"mappings": map[string]interface{}{
+				"dynamic":    false,
+				"properties": props["properties"],
+			},
+		},
+	}, "", "  ")
+}
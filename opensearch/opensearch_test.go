@@ -0,0 +1,227 @@
+package opensearch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+const line = "2019-10-01\t12:34:56\tFRA2\t182\t192.0.2.10\tGET\td111111abcdef8.cloudfront.net\t/index.html\t200\t-\tMozilla/5.0\ta=1\t-\tHit\tid-1\texample.com\thttps\t100\t0.001\t-\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\tHit\tHTTP/1.1\t-\t-"
+
+func webLog(t *testing.T, id, uri string) *cflogparser.WebLog {
+	t.Helper()
+	l, err := cflogparser.ParseLineWeb(strings.Replace(strings.Replace(line, "id-1", id, 1), "/index.html", uri, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// lookup returns the value at dotted name in nested objects of m.
+func lookup(m map[string]interface{}, name string) interface{} {
+	var v interface{} = m
+	for _, k := range strings.Split(name, ".") {
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = sub[k]
+	}
+	return v
+}
+
+func TestDocument(t *testing.T) {
+	b, err := json.Marshal(Document(webLog(t, "id-1", "/index.html")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(b, &doc)
+	tests := map[string]interface{}{
+		"@timestamp":                   "2019-10-01T12:34:56Z",
+		"source.ip":                    "192.0.2.10",
+		"http.request.method":          "GET",
+		"http.request.id":              "id-1",
+		"http.response.status_code":    200.0,
+		"http.response.bytes":          182.0,
+		"http.request.bytes":           100.0,
+		"http.version":                 "1.1",
+		"url.domain":                   "example.com",
+		"url.path":                     "/index.html",
+		"url.query":                    "a=1",
+		"url.scheme":                   "https",
+		"tls.version":                  "1.2",
+		"event.duration":               1000000.0,
+		"event.dataset":                "aws.cloudfront",
+		"aws.cloudfront.edge_location": "FRA2",
+		"http.request.referrer":        nil,
+		"aws.cloudfront.cookie":        nil,
+		"aws.cloudfront.fle_status":    nil,
+	}
+	for name, want := range tests {
+		if got := lookup(doc, name); got != want {
+			t.Errorf("%s: got %#v, want %#v", name, got, want)
+		}
+	}
+}
+
+func TestIndexName(t *testing.T) {
+	l := webLog(t, "id-1", "/")
+	tests := []struct{ index, want string }{
+		{"", "cloudfront-2019.10.01"},
+		{"cf-{2006-01}-web", "cf-2019-10-web"},
+		{"static", "static"},
+	}
+	for _, tt := range tests {
+		if got := (Options{Index: tt.index}).IndexName(l); got != tt.want {
+			t.Errorf("IndexName(%q) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestIndexTemplate(t *testing.T) {
+	b, err := IndexTemplate("cloudfront-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tmpl map[string]interface{}
+	if err := json.Unmarshal(b, &tmpl); err != nil {
+		t.Fatal(err)
+	}
+	props := lookup(tmpl, "template.mappings.properties").(map[string]interface{})
+	// Every field of documents must be mapped.
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if sub, ok := v.(map[string]interface{}); ok {
+				walk(prefix+k+".properties.", sub)
+				continue
+			}
+			if lookup(props, prefix+k+".type") == nil {
+				t.Errorf("%s%s is not mapped", prefix, k)
+			}
+		}
+	}
+	walk("", Document(webLog(t, "id-1", "/")))
+	for name, want := range map[string]string{
+		"@timestamp.type":                                      "date",
+		"source.properties.ip.type":                            "ip",
+		"http.properties.response.properties.status_code.type": "long",
+		"url.properties.path.type":                             "keyword",
+	} {
+		if got := lookup(props, name); got != want {
+			t.Errorf("%s: got %v, want %s", name, got, want)
+		}
+	}
+}
+
+// standIn is a minimal server of _bulk API, which keeps documents by index
+// and ID, and fails documents of uri "/bad".
+type standIn struct {
+	mu       sync.Mutex
+	docs     map[string]map[string]interface{}
+	template []byte
+	auth     string
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = r.Header.Get("Authorization")
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/_index_template/"):
+		s.template = body
+		w.Write([]byte(`{"acknowledged":true}`))
+		return
+	case r.Method != "POST" || r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson":
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+	var items []interface{}
+	errors := false
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		var action struct {
+			Index struct {
+				Index string `json:"_index"`
+				ID    string `json:"_id"`
+			} `json:"index"`
+		}
+		json.Unmarshal(sc.Bytes(), &action)
+		sc.Scan()
+		var doc map[string]interface{}
+		json.Unmarshal(sc.Bytes(), &doc)
+		if lookup(doc, "url.path") == "/bad" {
+			errors = true
+			items = append(items, map[string]interface{}{"index": map[string]interface{}{
+				"status": 400, "error": map[string]string{"type": "mapper_parsing_exception"},
+			}})
+			continue
+		}
+		s.docs[action.Index.Index+"/"+action.Index.ID] = doc
+		items = append(items, map[string]interface{}{"index": map[string]interface{}{"status": 201}})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": errors, "items": items})
+}
+
+func TestIndexer(t *testing.T) {
+	s := &standIn{docs: map[string]map[string]interface{}{}}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	c := &Client{URL: strings.Replace(srv.URL, "http://", "http://user:pass@", 1)}
+
+	tmpl, _ := IndexTemplate("cloudfront-*")
+	if err := c.PutIndexTemplate("cloudfront", tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s.template, tmpl) {
+		t.Error("template is not put")
+	}
+	if s.auth != "Basic dXNlcjpwYXNz" {
+		t.Errorf("got authorization %q", s.auth)
+	}
+
+	ix := NewIndexer(c, Options{}, 2)
+	recs := []*cflogparser.WebLog{webLog(t, "a", "/a"), webLog(t, "b", "/b"), webLog(t, "c", "/bad")}
+	// The same records twice, to test idempotency
+	var errs []error
+	for i := 0; i < 2; i++ {
+		for _, rec := range recs {
+			if err := ix.Write(rec); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if err := ix.Flush(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("got %d errors, want 2: %v", len(errs), errs)
+	}
+	if be, ok := errs[0].(*BulkError); !ok || be.Failed != 1 || !strings.Contains(be.Reason, "mapper_parsing_exception") {
+		t.Errorf("unexpected error: %#v", errs[0])
+	}
+	if ix.Indexed != 4 || ix.Failed != 2 {
+		t.Errorf("got indexed %d and failed %d, want 4 and 2", ix.Indexed, ix.Failed)
+	}
+	if len(s.docs) != 2 || s.docs["cloudfront-2019.10.01/a"] == nil || s.docs["cloudfront-2019.10.01/b"] == nil {
+		t.Errorf("unexpected documents: %v", s.docs)
+	}
+	if err := ix.Write(&cflogparser.RTMPLog{}); err == nil {
+		t.Error("expected error for RTMPLog")
+	}
+
+	c.URL = srv.URL + "/nowhere"
+	if err := c.Bulk([]byte("{}\n")); err == nil {
+		t.Error("expected error for HTTP error")
+	}
+}
@@ -0,0 +1,316 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ScopeName is the name of instrumentation scope of LogRecords.
+const ScopeName = "github.com/Maki-Daisuke/cflogparser/otlp"
+
+// Messages of ExportLogsServiceRequest in OTLP/JSON. Integers of 64 bits are
+// strings as the protobuf JSON mapping defines.
+type jsonRequest struct {
+	ResourceLogs []jsonResourceLogs `json:"resourceLogs"`
+}
+
+type jsonResourceLogs struct {
+	Resource  jsonResource    `json:"resource"`
+	ScopeLogs []jsonScopeLogs `json:"scopeLogs"`
+}
+
+type jsonResource struct {
+	Attributes []jsonKeyValue `json:"attributes,omitempty"`
+}
+
+type jsonScopeLogs struct {
+	Scope      jsonScope       `json:"scope"`
+	LogRecords []jsonLogRecord `json:"logRecords"`
+	SchemaURL  string          `json:"schemaUrl,omitempty"`
+}
+
+type jsonScope struct {
+	Name string `json:"name"`
+}
+
+type jsonLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano,omitempty"`
+	SeverityNumber       int            `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	Body                 *jsonAnyValue  `json:"body,omitempty"`
+	Attributes           []jsonKeyValue `json:"attributes,omitempty"`
+}
+
+type jsonKeyValue struct {
+	Key   string       `json:"key"`
+	Value jsonAnyValue `json:"value"`
+}
+
+type jsonAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *jsonArrayValue `json:"arrayValue,omitempty"`
+}
+
+type jsonArrayValue struct {
+	Values []jsonAnyValue `json:"values"`
+}
+
+// MarshalJSON encodes recs as ExportLogsServiceRequest of OTLP/JSON.
+func MarshalJSON(resource []Attribute, recs []LogRecord) ([]byte, error) {
+	sl := jsonScopeLogs{
+		Scope:      jsonScope{Name: ScopeName},
+		LogRecords: make([]jsonLogRecord, len(recs)),
+		SchemaURL:  SchemaURL,
+	}
+	for i, r := range recs {
+		jr := &sl.LogRecords[i]
+		jr.TimeUnixNano = jsonTime(r.Time)
+		jr.ObservedTimeUnixNano = jsonTime(r.ObservedTime)
+		jr.SeverityNumber = r.SeverityNumber
+		jr.SeverityText = r.SeverityText
+		if r.Body != "" {
+			body := r.Body
+			jr.Body = &jsonAnyValue{StringValue: &body}
+		}
+		attrs, err := jsonAttributes(r.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		jr.Attributes = attrs
+	}
+	attrs, err := jsonAttributes(resource)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonRequest{ResourceLogs: []jsonResourceLogs{{
+		Resource:  jsonResource{Attributes: attrs},
+		ScopeLogs: []jsonScopeLogs{sl},
+	}}})
+}
+
+func jsonTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func jsonAttributes(attrs []Attribute) ([]jsonKeyValue, error) {
+	kvs := make([]jsonKeyValue, len(attrs))
+	for i, a := range attrs {
+		kvs[i].Key = a.Key
+		switch v := a.Value.(type) {
+		case string:
+			kvs[i].Value.StringValue = &v
+		case bool:
+			kvs[i].Value.BoolValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			kvs[i].Value.IntValue = &s
+		case float64:
+			kvs[i].Value.DoubleValue = &v
+		case []string:
+			arr := &jsonArrayValue{Values: make([]jsonAnyValue, len(v))}
+			for j := range v {
+				arr.Values[j].StringValue = &v[j]
+			}
+			kvs[i].Value.ArrayValue = arr
+		default:
+			return nil, fmt.Errorf("Unexpected attribute type: %T", a.Value)
+		}
+	}
+	return kvs, nil
+}
+
+// Wire types of Protocol Buffers
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+var errProtoShort = errors.New("Unexpected end of Protocol Buffers message")
+
+// MarshalProto encodes recs as ExportLogsServiceRequest of OTLP/protobuf.
+func MarshalProto(resource []Attribute, recs []LogRecord) ([]byte, error) {
+	var scope, msg []byte
+	scope = appendProtoString(scope, 1, ScopeName)
+	var sl []byte
+	sl = appendProtoMessage(sl, 1, scope)
+	for _, r := range recs {
+		msg = msg[:0]
+		msg = appendProtoFixed64(msg, 1, protoTime(r.Time))
+		msg = appendProtoVarint(msg, 2, uint64(r.SeverityNumber))
+		msg = appendProtoString(msg, 3, r.SeverityText)
+		if r.Body != "" {
+			var body []byte
+			body = appendProtoTag(body, 1, protoBytes)
+			body = appendProtoLen(body, r.Body)
+			msg = appendProtoMessage(msg, 5, body)
+		}
+		var err error
+		if msg, err = appendProtoAttributes(msg, 6, r.Attributes); err != nil {
+			return nil, err
+		}
+		msg = appendProtoFixed64(msg, 11, protoTime(r.ObservedTime))
+		sl = appendProtoMessage(sl, 2, msg)
+	}
+	sl = appendProtoString(sl, 3, SchemaURL)
+
+	res, err := appendProtoAttributes(nil, 1, resource)
+	if err != nil {
+		return nil, err
+	}
+	var rl []byte
+	rl = appendProtoMessage(rl, 1, res)
+	rl = appendProtoMessage(rl, 2, sl)
+	return appendProtoMessage(nil, 1, rl), nil
+}
+
+func protoTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// appendProtoAttributes appends attrs as repeated KeyValue of field num.
+func appendProtoAttributes(b []byte, num int, attrs []Attribute) ([]byte, error) {
+	for _, a := range attrs {
+		var kv []byte
+		kv = appendProtoString(kv, 1, a.Key)
+		v, err := appendProtoAnyValue(nil, a.Value)
+		if err != nil {
+			return nil, err
+		}
+		kv = appendProtoMessage(kv, 2, v)
+		b = appendProtoMessage(b, num, kv)
+	}
+	return b, nil
+}
+
+// appendProtoAnyValue appends v as fields of AnyValue message. The field is
+// written even if it is zero, as it is a member of oneof.
+func appendProtoAnyValue(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		b = appendProtoTag(b, 1, protoBytes)
+		return appendProtoLen(b, v), nil
+	case bool:
+		b = appendProtoTag(b, 2, protoVarint)
+		if v {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case int64:
+		b = appendProtoTag(b, 3, protoVarint)
+		return binary.AppendUvarint(b, uint64(v)), nil
+	case float64:
+		b = appendProtoTag(b, 4, protoFixed64)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v)), nil
+	case []string:
+		var arr []byte
+		for _, s := range v {
+			var e []byte
+			e, _ = appendProtoAnyValue(e, s)
+			arr = appendProtoMessage(arr, 1, e)
+		}
+		return appendProtoMessage(b, 5, arr), nil
+	}
+	return nil, fmt.Errorf("Unexpected attribute type: %T", v)
+}
+
+func appendProtoTag(b []byte, num int, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(num<<3|wire))
+}
+
+func appendProtoLen(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendProtoVarint(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendProtoTag(b, num, protoVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendProtoFixed64(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendProtoTag(b, num, protoFixed64)
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+func appendProtoString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendProtoTag(b, num, protoBytes)
+	return appendProtoLen(b, s)
+}
+
+// appendProtoMessage appends m as an embedded message of field num. It is
+// written even if empty, as presence of messages matters.
+func appendProtoMessage(b []byte, num int, m []byte) []byte {
+	b = appendProtoTag(b, num, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(m)))
+	return append(b, m...)
+}
+
+// protoFields calls f for each field of message b. val is the value of
+// varint and fixed fields, and data is the content of length-delimited ones.
+func protoFields(b []byte, f func(num, wire int, val uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errProtoShort
+		}
+		b = b[n:]
+		num, wire := int(tag>>3), int(tag&7)
+		var val uint64
+		var data []byte
+		switch wire {
+		case protoVarint:
+			if val, n = binary.Uvarint(b); n <= 0 {
+				return errProtoShort
+			}
+			b = b[n:]
+		case protoFixed64:
+			if len(b) < 8 {
+				return errProtoShort
+			}
+			val, b = binary.LittleEndian.Uint64(b), b[8:]
+		case protoFixed32:
+			if len(b) < 4 {
+				return errProtoShort
+			}
+			val, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case protoBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errProtoShort
+			}
+			data, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return fmt.Errorf("Unsupported wire type %d of field %d", wire, num)
+		}
+		if err := f(num, wire, val, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
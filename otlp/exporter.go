@@ -0,0 +1,242 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// Encodings lists encodings supported by Exporter.
+var Encodings = []string{"json", "protobuf"}
+
+// Options configures Exporter.
+type Options struct {
+	// Encoding is one of Encodings. It is "json" if empty.
+	Encoding string
+	// Resource is attributes of the resource of LogRecords. It is
+	// DefaultResource if nil.
+	Resource []Attribute
+	// BatchSize is the number of LogRecords exported at once. It is 1000 if
+	// zero.
+	BatchSize int
+	// Header is added to requests of HTTP, e.g. for authentication.
+	Header http.Header
+	// HTTPClient is used for requests. It is http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// PartialSuccessError is returned if the endpoint rejected some of
+// LogRecords.
+type PartialSuccessError struct {
+	Rejected int64  // Number of rejected LogRecords
+	Message  string // Error message of the endpoint
+}
+
+func (e *PartialSuccessError) Error() string {
+	return fmt.Sprintf("%d log records rejected: %s", e.Rejected, e.Message)
+}
+
+// Exporter exports records in batches of ExportLogsServiceRequest.
+type Exporter struct {
+	opts    Options
+	marshal func([]Attribute, []LogRecord) ([]byte, error)
+	send    func([]byte) error
+	recs    []LogRecord
+
+	// Exported and Rejected count LogRecords exported so far.
+	Exported, Rejected int
+}
+
+func newExporter(opts Options) (*Exporter, error) {
+	e := &Exporter{opts: opts}
+	switch opts.Encoding {
+	case "", "json":
+		e.marshal = MarshalJSON
+	case "protobuf":
+		e.marshal = MarshalProto
+	default:
+		return nil, fmt.Errorf("Unknown encoding: %s", opts.Encoding)
+	}
+	if e.opts.Resource == nil {
+		e.opts.Resource = DefaultResource
+	}
+	if e.opts.BatchSize <= 0 {
+		e.opts.BatchSize = 1000
+	}
+	return e, nil
+}
+
+// NewFileExporter returns an Exporter writing requests to w. Requests in
+// JSON are written one per line, which is readable by otlpjsonfile receiver
+// of OpenTelemetry Collector. Requests in Protocol Buffers are prefixed by
+// their length in 4 bytes of big endian.
+func NewFileExporter(w io.Writer, opts Options) (*Exporter, error) {
+	e, err := newExporter(opts)
+	if err != nil {
+		return nil, err
+	}
+	var buf []byte
+	e.send = func(body []byte) error {
+		buf = buf[:0]
+		if e.opts.Encoding == "protobuf" {
+			buf = binary.BigEndian.AppendUint32(buf, uint32(len(body)))
+			buf = append(buf, body...)
+		} else {
+			buf = append(buf, body...)
+			buf = append(buf, '\n')
+		}
+		_, err := w.Write(buf)
+		return err
+	}
+	return e, nil
+}
+
+// NewHTTPExporter returns an Exporter posting requests to endpoint of
+// OTLP/HTTP, e.g. "http://localhost:4318/v1/logs".
+func NewHTTPExporter(endpoint string, opts Options) (*Exporter, error) {
+	e, err := newExporter(opts)
+	if err != nil {
+		return nil, err
+	}
+	contentType := "application/json"
+	if e.opts.Encoding == "protobuf" {
+		contentType = "application/x-protobuf"
+	}
+	e.send = func(body []byte) error {
+		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for k, vs := range e.opts.Header {
+			req.Header[k] = vs
+		}
+		req.Header.Set("Content-Type", contentType)
+		hc := e.opts.HTTPClient
+		if hc == nil {
+			hc = http.DefaultClient
+		}
+		res, err := hc.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("POST %s: %s: %s", endpoint, res.Status, bytes.TrimSpace(b))
+		}
+		if e.opts.Encoding == "protobuf" {
+			return partialSuccessProto(b)
+		}
+		return partialSuccessJSON(b)
+	}
+	return e, nil
+}
+
+// partialSuccessJSON returns *PartialSuccessError if ExportLogsServiceResponse
+// in b reports rejected LogRecords.
+func partialSuccessJSON(b []byte) error {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	var res struct {
+		PartialSuccess struct {
+			RejectedLogRecords json.Number `json:"rejectedLogRecords"`
+			ErrorMessage       string      `json:"errorMessage"`
+		} `json:"partialSuccess"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+	ps := res.PartialSuccess
+	if ps.RejectedLogRecords == "" && ps.ErrorMessage == "" {
+		return nil
+	}
+	n, _ := ps.RejectedLogRecords.Int64()
+	return &PartialSuccessError{Rejected: n, Message: ps.ErrorMessage}
+}
+
+// partialSuccessProto is partialSuccessJSON for OTLP/protobuf.
+func partialSuccessProto(b []byte) error {
+	var ps *PartialSuccessError
+	err := protoFields(b, func(num, wire int, _ uint64, data []byte) error {
+		if num != 1 || wire != protoBytes {
+			return nil
+		}
+		ps = &PartialSuccessError{}
+		return protoFields(data, func(num, wire int, val uint64, data []byte) error {
+			switch {
+			case num == 1 && wire == protoVarint:
+				ps.Rejected = int64(val)
+			case num == 2 && wire == protoBytes:
+				ps.Message = string(data)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if ps == nil || ps.Rejected == 0 && ps.Message == "" {
+		return nil
+	}
+	return ps
+}
+
+// Write adds rec, which must be either *WebLog or LogRecord, to the batch,
+// and exports the batch if it gets full. ObservedTime of rec is set to the
+// current time if zero.
+func (e *Exporter) Write(rec interface{}) error {
+	var r LogRecord
+	switch rec := rec.(type) {
+	case *cflogparser.WebLog:
+		r = NewLogRecord(rec)
+	case LogRecord:
+		r = rec
+	default:
+		return fmt.Errorf("Unexpected record type: %T", rec)
+	}
+	if r.ObservedTime.IsZero() {
+		r.ObservedTime = time.Now()
+	}
+	e.recs = append(e.recs, r)
+	if len(e.recs) >= e.opts.BatchSize {
+		return e.Flush()
+	}
+	return nil
+}
+
+// Flush exports LogRecords in the batch, if any. *PartialSuccessError is
+// returned if some of them are rejected, after which Exporter can be used
+// further.
+func (e *Exporter) Flush() error {
+	if len(e.recs) == 0 {
+		return nil
+	}
+	n := len(e.recs)
+	body, err := e.marshal(e.opts.Resource, e.recs)
+	e.recs = e.recs[:0]
+	if err == nil {
+		err = e.send(body)
+	}
+	if ps, ok := err.(*PartialSuccessError); ok {
+		e.Rejected += int(ps.Rejected)
+		e.Exported += n - int(ps.Rejected)
+		return err
+	}
+	if err != nil {
+		e.Rejected += n
+		return err
+	}
+	e.Exported += n
+	return nil
+}
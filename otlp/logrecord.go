@@ -0,0 +1,125 @@
+// Package otlp converts WebLog into LogRecord of the OpenTelemetry log data
+// model, and exports them by OTLP in JSON or Protocol Buffers, to a file or
+// to an OTLP/HTTP endpoint such as OpenTelemetry Collector.
+//
+// Attributes follow the semantic conventions of HTTP. Fields of CloudFront
+// which the conventions don't define, such as edge location and result type,
+// are put under "aws.cloudfront".
+package otlp
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// SchemaURL is the version of semantic conventions which attributes follow.
+const SchemaURL = "https://opentelemetry.io/schemas/1.26.0"
+
+// Severity numbers of the log data model
+const (
+	SeverityInfo  = 9
+	SeverityWarn  = 13
+	SeverityError = 17
+)
+
+// Attribute is a key-value pair of LogRecord or resource. Value is one of
+// string, bool, int64, float64 and []string.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// DefaultResource is the resource of LogRecords if Options.Resource is nil.
+var DefaultResource = []Attribute{
+	{"service.name", "cloudfront"},
+	{"cloud.provider", "aws"},
+}
+
+// LogRecord is a record of the log data model.
+type LogRecord struct {
+	Time           time.Time
+	ObservedTime   time.Time
+	SeverityNumber int
+	SeverityText   string
+	Body           string
+	Attributes     []Attribute
+}
+
+// NewLogRecord converts l into LogRecord. Body is the original line of l.
+// Severity is ERROR for status 5xx, WARN for 4xx, and INFO otherwise.
+// Empty strings and zero numbers are omitted from attributes, except for
+// status and bytes. ObservedTime is left zero, which is set by Exporter.
+func NewLogRecord(l *cflogparser.WebLog) LogRecord {
+	r := LogRecord{
+		Time:           l.Time,
+		SeverityNumber: SeverityInfo,
+		SeverityText:   "INFO",
+		Body:           cflogparser.FormatLineWeb(l),
+	}
+	switch {
+	case l.Status >= 500:
+		r.SeverityNumber, r.SeverityText = SeverityError, "ERROR"
+	case l.Status >= 400:
+		r.SeverityNumber, r.SeverityText = SeverityWarn, "WARN"
+	}
+
+	attrs := make([]Attribute, 0, 24)
+	str := func(key, v string) {
+		if v != "" {
+			attrs = append(attrs, Attribute{key, v})
+		}
+	}
+	str("http.request.method", l.Method)
+	str("url.path", l.URI)
+	str("url.query", l.QueryString)
+	str("url.scheme", l.RequestProtocol)
+	str("server.address", l.HostHeader)
+	attrs = append(attrs, Attribute{"http.response.status_code", int64(l.Status)})
+	if l.RequestIP != nil {
+		attrs = append(attrs, Attribute{"client.address", l.RequestIP.String()})
+	}
+	str("user_agent.original", l.UserAgent)
+	if l.HTTPVersion != "" {
+		attrs = append(attrs, Attribute{"network.protocol.name", "http"})
+		str("network.protocol.version", strings.TrimPrefix(l.HTTPVersion, "HTTP/"))
+	}
+	if l.RequestBytes != 0 {
+		attrs = append(attrs, Attribute{"http.request.size", int64(l.RequestBytes)})
+	}
+	attrs = append(attrs, Attribute{"http.response.size", int64(l.Bytes)})
+	if l.Referrer != "" {
+		attrs = append(attrs, Attribute{"http.request.header.referer", []string{l.Referrer}})
+	}
+	if l.XforwardedFor != "" {
+		attrs = append(attrs, Attribute{"http.request.header.x-forwarded-for", []string{l.XforwardedFor}})
+	}
+	if strings.HasPrefix(l.SslProtocol, "TLSv") {
+		attrs = append(attrs, Attribute{"tls.protocol.name", "tls"})
+		str("tls.protocol.version", strings.TrimPrefix(l.SslProtocol, "TLSv"))
+	}
+	str("tls.cipher", l.SslCipher)
+	str("aws.cloudfront.request_id", l.RequestID)
+	str("aws.cloudfront.domain", l.Host)
+	str("aws.cloudfront.edge_location", l.Location)
+	str("aws.cloudfront.edge_result_type", l.ResultType)
+	str("aws.cloudfront.edge_response_result_type", l.ResponseResultType)
+	if l.TimeTaken != 0 {
+		attrs = append(attrs, Attribute{"aws.cloudfront.time_taken", float32To64(l.TimeTaken)})
+	}
+	str("aws.cloudfront.fle_status", l.FleStatus)
+	if l.FleEncryptedFields != 0 {
+		attrs = append(attrs, Attribute{"aws.cloudfront.fle_encrypted_fields", int64(l.FleEncryptedFields)})
+	}
+	r.Attributes = attrs
+	return r
+}
+
+// float32To64 converts f into float64 of the shortest decimal, e.g. 0.001
+// rather than 0.0010000000474974513.
+func float32To64(f float32) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return v
+}
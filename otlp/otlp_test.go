@@ -0,0 +1,389 @@
+package otlp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+const line = "2019-10-01\t12:34:56\tFRA2\t182\t192.0.2.10\tGET\td111111abcdef8.cloudfront.net\t/index.html\t200\thttps://example.com/\tMozilla/5.0\ta=1\t-\tHit\tid-1\texample.com\thttps\t100\t0.001\t-\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\tHit\tHTTP/1.1\t-\t-"
+
+func webLog(t *testing.T, status string) *cflogparser.WebLog {
+	t.Helper()
+	l, err := cflogparser.ParseLineWeb(strings.Replace(line, "\t200\t", "\t"+status+"\t", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func attrMap(attrs []Attribute) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, a := range attrs {
+		m[a.Key] = a.Value
+	}
+	return m
+}
+
+func TestNewLogRecord(t *testing.T) {
+	l := webLog(t, "200")
+	r := NewLogRecord(l)
+	if !r.Time.Equal(l.Time) || r.SeverityNumber != SeverityInfo || r.SeverityText != "INFO" {
+		t.Errorf("unexpected record: %+v", r)
+	}
+	if r.Body != cflogparser.FormatLineWeb(l) {
+		t.Errorf("got body %q", r.Body)
+	}
+	want := map[string]interface{}{
+		"http.request.method":                      "GET",
+		"url.path":                                 "/index.html",
+		"url.query":                                "a=1",
+		"url.scheme":                               "https",
+		"server.address":                           "example.com",
+		"http.response.status_code":                int64(200),
+		"client.address":                           "192.0.2.10",
+		"user_agent.original":                      "Mozilla/5.0",
+		"network.protocol.name":                    "http",
+		"network.protocol.version":                 "1.1",
+		"http.request.size":                        int64(100),
+		"http.response.size":                       int64(182),
+		"http.request.header.referer":              []string{"https://example.com/"},
+		"tls.protocol.name":                        "tls",
+		"tls.protocol.version":                     "1.2",
+		"tls.cipher":                               "ECDHE-RSA-AES128-GCM-SHA256",
+		"aws.cloudfront.request_id":                "id-1",
+		"aws.cloudfront.domain":                    "d111111abcdef8.cloudfront.net",
+		"aws.cloudfront.edge_location":             "FRA2",
+		"aws.cloudfront.edge_result_type":          "Hit",
+		"aws.cloudfront.edge_response_result_type": "Hit",
+		"aws.cloudfront.time_taken":                0.001,
+	}
+	if got := attrMap(r.Attributes); !reflect.DeepEqual(got, want) {
+		t.Errorf("got attributes %v, want %v", got, want)
+	}
+
+	for status, sev := range map[string]int{"304": SeverityInfo, "404": SeverityWarn, "503": SeverityError} {
+		if r := NewLogRecord(webLog(t, status)); r.SeverityNumber != sev {
+			t.Errorf("status %s: got severity %d, want %d", status, r.SeverityNumber, sev)
+		}
+	}
+}
+
+func records(t *testing.T) []LogRecord {
+	t.Helper()
+	var recs []LogRecord
+	for _, status := range []string{"200", "404", "503"} {
+		r := NewLogRecord(webLog(t, status))
+		r.ObservedTime = time.Date(2019, 10, 1, 13, 0, 0, 0, time.UTC)
+		recs = append(recs, r)
+	}
+	recs[0].Attributes = append(recs[0].Attributes, Attribute{"cached", true}, Attribute{"zero", int64(0)})
+	return recs
+}
+
+// decodeJSON returns attributes of resource and each record, with times and
+// severity of records, in a form comparable with decodeProto.
+func decodeJSON(t *testing.T, b []byte) []map[string]string {
+	t.Helper()
+	var req jsonRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		t.Fatal(err)
+	}
+	conv := func(kvs []jsonKeyValue) map[string]string {
+		m := map[string]string{}
+		for _, kv := range kvs {
+			v := kv.Value
+			switch {
+			case v.StringValue != nil:
+				m[kv.Key] = *v.StringValue
+			case v.BoolValue != nil:
+				m[kv.Key] = strconv.FormatBool(*v.BoolValue)
+			case v.IntValue != nil:
+				m[kv.Key] = *v.IntValue
+			case v.DoubleValue != nil:
+				m[kv.Key] = strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64)
+			case v.ArrayValue != nil:
+				m[kv.Key] = "[" + *v.ArrayValue.Values[0].StringValue + "]"
+			}
+		}
+		return m
+	}
+	rl := req.ResourceLogs[0]
+	sl := rl.ScopeLogs[0]
+	res := conv(rl.Resource.Attributes)
+	res["scope"] = sl.Scope.Name
+	res["schema"] = sl.SchemaURL
+	ms := []map[string]string{res}
+	for _, r := range sl.LogRecords {
+		m := conv(r.Attributes)
+		m["time"] = r.TimeUnixNano
+		m["observed"] = r.ObservedTimeUnixNano
+		m["severity"] = strconv.Itoa(r.SeverityNumber) + " " + r.SeverityText
+		m["body"] = *r.Body.StringValue
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+// decodeProto is decodeJSON for OTLP/protobuf.
+func decodeProto(t *testing.T, b []byte) []map[string]string {
+	t.Helper()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	anyValue := func(data []byte) string {
+		var s string
+		must(protoFields(data, func(num, wire int, val uint64, data []byte) error {
+			switch num {
+			case 1:
+				s = string(data)
+			case 2:
+				s = strconv.FormatBool(val != 0)
+			case 3:
+				s = strconv.FormatInt(int64(val), 10)
+			case 4:
+				s = strconv.FormatFloat(math.Float64frombits(val), 'g', -1, 64)
+			case 5:
+				protoFields(data, func(_, _ int, _ uint64, data []byte) error {
+					return protoFields(data, func(_, _ int, _ uint64, data []byte) error {
+						s = "[" + string(data) + "]"
+						return nil
+					})
+				})
+			}
+			return nil
+		}))
+		return s
+	}
+	keyValue := func(m map[string]string, data []byte) {
+		var k, v string
+		must(protoFields(data, func(num, _ int, _ uint64, data []byte) error {
+			if num == 1 {
+				k = string(data)
+			} else {
+				v = anyValue(data)
+			}
+			return nil
+		}))
+		m[k] = v
+	}
+	var ms []map[string]string
+	must(protoFields(b, func(_, _ int, _ uint64, rl []byte) error {
+		res := map[string]string{}
+		ms = append(ms, res)
+		return protoFields(rl, func(num, _ int, _ uint64, data []byte) error {
+			if num == 1 {
+				return protoFields(data, func(_, _ int, _ uint64, data []byte) error {
+					keyValue(res, data)
+					return nil
+				})
+			}
+			return protoFields(data, func(num, _ int, _ uint64, data []byte) error {
+				switch num {
+				case 1:
+					return protoFields(data, func(_, _ int, _ uint64, data []byte) error {
+						res["scope"] = string(data)
+						return nil
+					})
+				case 3:
+					res["schema"] = string(data)
+					return nil
+				}
+				m := map[string]string{}
+				var sev, text string
+				ms = append(ms, m)
+				err := protoFields(data, func(num, _ int, val uint64, data []byte) error {
+					switch num {
+					case 1:
+						m["time"] = strconv.FormatUint(val, 10)
+					case 2:
+						sev = strconv.FormatUint(val, 10)
+					case 3:
+						text = string(data)
+					case 5:
+						m["body"] = anyValue(data)
+					case 6:
+						keyValue(m, data)
+					case 11:
+						m["observed"] = strconv.FormatUint(val, 10)
+					}
+					return nil
+				})
+				m["severity"] = sev + " " + text
+				return err
+			})
+		})
+	}))
+	return ms
+}
+
+func TestMarshal(t *testing.T) {
+	recs := records(t)
+	jb, err := MarshalJSON(DefaultResource, recs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb, err := MarshalProto(DefaultResource, recs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decodeJSON(t, jb)
+	if !reflect.DeepEqual(got, decodeProto(t, pb)) {
+		t.Errorf("JSON and protobuf differ:\n%v\n%v", got, decodeProto(t, pb))
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d maps, want 4", len(got))
+	}
+	for k, want := range map[string]string{"service.name": "cloudfront", "scope": ScopeName, "schema": SchemaURL} {
+		if got[0][k] != want {
+			t.Errorf("%s: got %q, want %q", k, got[0][k], want)
+		}
+	}
+	for k, want := range map[string]string{
+		"time":                        "1569933296000000000",
+		"observed":                    "1569934800000000000",
+		"severity":                    "9 INFO",
+		"http.response.status_code":   "200",
+		"http.request.header.referer": "[https://example.com/]",
+		"aws.cloudfront.time_taken":   "0.001",
+		"cached":                      "true",
+		"zero":                        "0",
+	} {
+		if got[1][k] != want {
+			t.Errorf("%s: got %q, want %q", k, got[1][k], want)
+		}
+	}
+	if got[3]["severity"] != "17 ERROR" {
+		t.Errorf("got severity %q", got[3]["severity"])
+	}
+
+	recs[0].Attributes = append(recs[0].Attributes, Attribute{"bad", 1})
+	if _, err := MarshalJSON(nil, recs); err == nil {
+		t.Error("expected error for int attribute")
+	}
+	if _, err := MarshalProto(nil, recs); err == nil {
+		t.Error("expected error for int attribute")
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	recs := records(t)
+	var buf bytes.Buffer
+	e, err := NewFileExporter(&buf, Options{BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recs {
+		if err := e.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(&buf)
+	n := 0
+	for sc.Scan() {
+		n += len(decodeJSON(t, sc.Bytes())) - 1
+	}
+	if n != 3 || e.Exported != 3 {
+		t.Errorf("got %d records, exported %d, want 3", n, e.Exported)
+	}
+
+	buf.Reset()
+	e, _ = NewFileExporter(&buf, Options{Encoding: "protobuf"})
+	e.Write(webLog(t, "200"))
+	e.Flush()
+	b := buf.Bytes()
+	size := binary.BigEndian.Uint32(b)
+	if int(size) != len(b)-4 {
+		t.Fatalf("got size %d, want %d", size, len(b)-4)
+	}
+	if m := decodeProto(t, b[4:]); len(m) != 2 || m[1]["observed"] == "" {
+		t.Errorf("unexpected request: %v", m)
+	}
+
+	if err := e.Write(&cflogparser.RTMPLog{}); err == nil {
+		t.Error("expected error for RTMPLog")
+	}
+	if _, err := NewFileExporter(&buf, Options{Encoding: "xml"}); err == nil {
+		t.Error("expected error for unknown encoding")
+	}
+}
+
+func TestHTTPExporter(t *testing.T) {
+	var got []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/logs" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		switch r.Header.Get("Content-Type") {
+		case "application/json":
+			got = decodeJSON(t, b)
+			w.Write([]byte(`{"partialSuccess":{"rejectedLogRecords":"1","errorMessage":"too old"}}`))
+		case "application/x-protobuf":
+			got = decodeProto(t, b)
+			if len(got) == 2 {
+				// Empty response means success.
+				return
+			}
+			// partial_success { rejected_log_records: 2, error_message: "x" }
+			w.Write([]byte{0x0a, 0x05, 0x08, 0x02, 0x12, 0x01, 'x'})
+		}
+	}))
+	defer srv.Close()
+	recs := records(t)
+	header := http.Header{"Authorization": {"Bearer token"}}
+
+	e, err := NewHTTPExporter(srv.URL+"/v1/logs", Options{Header: header})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recs {
+		e.Write(r)
+	}
+	err = e.Flush()
+	if ps, ok := err.(*PartialSuccessError); !ok || ps.Rejected != 1 || ps.Message != "too old" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(got) != 4 || e.Exported != 2 || e.Rejected != 1 {
+		t.Errorf("got %d maps, exported %d and rejected %d", len(got), e.Exported, e.Rejected)
+	}
+
+	e, _ = NewHTTPExporter(srv.URL+"/v1/logs", Options{Encoding: "protobuf", Header: header, BatchSize: 1})
+	if err := e.Write(recs[0]); err != nil {
+		t.Error(err)
+	}
+	e.opts.BatchSize = 2
+	e.Write(recs[1])
+	err = e.Write(recs[2])
+	if ps, ok := err.(*PartialSuccessError); !ok || ps.Rejected != 2 || ps.Message != "x" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if e.Exported != 1 || e.Rejected != 2 {
+		t.Errorf("exported %d and rejected %d, want 1 and 2", e.Exported, e.Rejected)
+	}
+
+	e, _ = NewHTTPExporter(srv.URL+"/v1/logs", Options{})
+	e.Write(recs[0])
+	if err := e.Flush(); err == nil || e.Rejected != 1 {
+		t.Errorf("expected error for HTTP error: %v", err)
+	}
+}
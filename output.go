@@ -0,0 +1,195 @@
+package cflogparser
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// OutputFormats lists formats supported by RecordWriter.
+var OutputFormats = []string{"ndjson", "csv", "tsv", "logfmt", "table"}
+
+// RecordWriter writes selected fields of records in one of OutputFormats:
+//
+//	ndjson  one JSON object per line, with properties in the order of fields
+//	csv     comma-separated values with header line
+//	tsv     tab-separated values with header line
+//	logfmt  key=value pairs separated by space
+//	table   columns aligned by space with header line, for reading by human
+//
+// Output may be buffered, so Flush must be called at the end. The table
+// format buffers all records to align columns.
+type RecordWriter struct {
+	// TimeFormat is a layout of time.Format for the Time field, or "unix"
+	// or "unixms" for seconds or milliseconds since the Unix epoch. It is
+	// RFC3339 if empty.
+	TimeFormat string
+
+	format string
+	fields []Field
+	w      io.Writer
+	csv    *csv.Writer
+	tab    *tabwriter.Writer
+	buf    []byte
+	header bool
+}
+
+// NewRecordWriter returns a RecordWriter writing fields of records to w in
+// format.
+func NewRecordWriter(w io.Writer, format string, fields []Field) (*RecordWriter, error) {
+	rw := &RecordWriter{format: format, fields: fields, w: w}
+	switch format {
+	case "ndjson", "logfmt":
+		rw.header = true
+	case "csv":
+		rw.csv = csv.NewWriter(w)
+	case "tsv":
+		rw.csv = csv.NewWriter(w)
+		rw.csv.Comma = '\t'
+	case "table":
+		rw.tab = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	default:
+		return nil, fmt.Errorf("Unknown format: %s", format)
+	}
+	return rw, nil
+}
+
+func (rw *RecordWriter) writeHeader() error {
+	if rw.header {
+		return nil
+	}
+	rw.header = true
+	names := make([]string, len(rw.fields))
+	for i, f := range rw.fields {
+		names[i] = f.Name
+	}
+	if rw.csv != nil {
+		return rw.csv.Write(names)
+	}
+	_, err := io.WriteString(rw.tab, strings.Join(names, "\t")+"\n")
+	return err
+}
+
+// Write writes rec, which must be *WebLog or *RTMPLog.
+func (rw *RecordWriter) Write(rec interface{}) error {
+	if err := rw.writeHeader(); err != nil {
+		return err
+	}
+	switch rw.format {
+	case "ndjson":
+		return rw.writeJSON(rec)
+	case "logfmt":
+		return rw.writeLogfmt(rec)
+	case "table":
+		vals := make([]string, len(rw.fields))
+		for i, f := range rw.fields {
+			vals[i] = tableEscaper.Replace(rw.String(f, rec))
+		}
+		_, err := io.WriteString(rw.tab, strings.Join(vals, "\t")+"\n")
+		return err
+	}
+	vals := make([]string, len(rw.fields))
+	for i, f := range rw.fields {
+		vals[i] = rw.String(f, rec)
+	}
+	return rw.csv.Write(vals)
+}
+
+var tableEscaper = strings.NewReplacer("\t", `\t`, "\n", `\n`)
+
+// Flush writes buffered output.
+func (rw *RecordWriter) Flush() error {
+	if err := rw.writeHeader(); err != nil {
+		return err
+	}
+	if rw.csv != nil {
+		rw.csv.Flush()
+		return rw.csv.Error()
+	}
+	if rw.tab != nil {
+		return rw.tab.Flush()
+	}
+	return nil
+}
+
+// String returns the value of f in rec as string, formatting time with
+// TimeFormat.
+func (rw *RecordWriter) String(f Field, rec interface{}) string {
+	if t, ok := f.Value(rec).(time.Time); ok {
+		return rw.formatTime(t)
+	}
+	return f.String(rec)
+}
+
+func (rw *RecordWriter) formatTime(t time.Time) string {
+	switch rw.TimeFormat {
+	case "":
+		return t.Format(time.RFC3339)
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixms":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return t.Format(rw.TimeFormat)
+}
+
+func (rw *RecordWriter) writeJSON(rec interface{}) error {
+	b := append(rw.buf[:0], '{')
+	for i, f := range rw.fields {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendQuote(b, f.Name)
+		b = append(b, ':')
+		switch v := f.Value(rec).(type) {
+		case time.Time:
+			s := rw.formatTime(v)
+			if rw.TimeFormat == "unix" || rw.TimeFormat == "unixms" {
+				b = append(b, s...)
+			} else {
+				b = appendJSONQuote(b, s, false)
+			}
+		case string:
+			b = appendJSONQuote(b, v, false)
+		case net.IP:
+			b = appendJSONQuote(b, f.String(rec), false)
+		default:
+			j, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			b = append(b, j...)
+		}
+	}
+	b = append(b, '}', '\n')
+	rw.buf = b
+	_, err := rw.w.Write(b)
+	return err
+}
+
+func (rw *RecordWriter) writeLogfmt(rec interface{}) error {
+	b := rw.buf[:0]
+	for i, f := range rw.fields {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = append(b, f.Name...)
+		b = append(b, '=')
+		s := rw.String(f, rec)
+		if strings.ContainsAny(s, " =\"\\\t\n") {
+			b = strconv.AppendQuote(b, s)
+		} else {
+			b = append(b, s...)
+		}
+	}
+	b = append(b, '\n')
+	rw.buf = b
+	_, err := rw.w.Write(b)
+	return err
+}
@@ -0,0 +1,81 @@
+package cflogparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRecordWriter(t *testing.T) {
+	rec := &WebLog{
+		Time:      time.Date(2019, 10, 1, 12, 34, 56, 0, time.UTC),
+		RequestIP: net.ParseIP("192.0.2.10"),
+		URI:       "/a b",
+		Status:    200,
+		UserAgent: `say "hi", <me>`,
+		TimeTaken: 0.25,
+	}
+	fields, err := LookupFields(rec, []string{"time", "status", "uri", "request_ip", "user_agent", "time_taken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format, timeFormat, want string
+	}{
+		{"ndjson", "", `{"time":"2019-10-01T12:34:56Z","status":200,"uri":"/a b","request_ip":"192.0.2.10","user_agent":"say \"hi\", <me>","time_taken":0.25}` + "\n"},
+		{"ndjson", "unix", `{"time":1569933296,"status":200,"uri":"/a b","request_ip":"192.0.2.10","user_agent":"say \"hi\", <me>","time_taken":0.25}` + "\n"},
+		{"csv", "2006-01-02 15:04:05", "time,status,uri,request_ip,user_agent,time_taken\n2019-10-01 12:34:56,200,/a b,192.0.2.10,\"say \"\"hi\"\", <me>\",0.25\n"},
+		{"tsv", "unixms", "time\tstatus\turi\trequest_ip\tuser_agent\ttime_taken\n1569933296000\t200\t/a b\t192.0.2.10\t\"say \"\"hi\"\", <me>\"\t0.25\n"},
+		{"logfmt", "", `time=2019-10-01T12:34:56Z status=200 uri="/a b" request_ip=192.0.2.10 user_agent="say \"hi\", <me>" time_taken=0.25` + "\n"},
+		{"table", "", "time                  status  uri   request_ip  user_agent      time_taken\n2019-10-01T12:34:56Z  200     /a b  192.0.2.10  say \"hi\", <me>  0.25\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w, err := NewRecordWriter(&buf, tt.format, fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.TimeFormat = tt.timeFormat
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.format, buf.String(), tt.want)
+		}
+	}
+
+	if _, err := NewRecordWriter(nil, "xml", fields); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestRecordWriterAllFields(t *testing.T) {
+	for _, rec := range []interface{}{&WebLog{}, &RTMPLog{}} {
+		fields, err := LookupFields(rec, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		w, _ := NewRecordWriter(&buf, "ndjson", fields)
+		w.Write(rec)
+		var got, want map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("invalid JSON: %v: %s", err, buf.String())
+		}
+		b, _ := json.Marshal(rec)
+		json.Unmarshal(b, &want)
+		if len(got) != len(want) {
+			t.Errorf("%T: got %d properties, want %d", rec, len(got), len(want))
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%T: got %v for %s, want %v", rec, got[k], k, v)
+			}
+		}
+	}
+}
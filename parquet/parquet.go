@@ -0,0 +1,212 @@
+// Package parquet writes WebLog and RTMPLog into Apache Parquet files, to be
+// queried by Athena, Spark and other engines of data lakes.
+//
+// The schema mirrors fields of WebLog and RTMPLog with their JSON names.
+// Time is stored as timestamp in milliseconds, IP addresses as strings, and
+// low-cardinality strings such as location, method and result type are
+// dictionary encoded.
+package parquet
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	pq "github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// WebRow is a row of Parquet file for WebLog.
+type WebRow struct {
+	Time               int64   `parquet:"name=time, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	Location           string  `parquet:"name=location, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Bytes              int64   `parquet:"name=bytes, type=INT64, convertedtype=UINT_64"`
+	RequestIP          string  `parquet:"name=request_ip, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Method             string  `parquet:"name=method, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Host               string  `parquet:"name=host, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	URI                string  `parquet:"name=uri, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Status             int32   `parquet:"name=status, type=INT32, convertedtype=UINT_16"`
+	Referrer           string  `parquet:"name=referrer, type=BYTE_ARRAY, convertedtype=UTF8"`
+	UserAgent          string  `parquet:"name=user_agent, type=BYTE_ARRAY, convertedtype=UTF8"`
+	QueryString        string  `parquet:"name=query_string, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Cookie             string  `parquet:"name=cookie, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ResultType         string  `parquet:"name=result_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	RequestID          string  `parquet:"name=request_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	HostHeader         string  `parquet:"name=host_header, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	RequestProtocol    string  `parquet:"name=request_protocol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	RequestBytes       int64   `parquet:"name=request_bytes, type=INT64, convertedtype=UINT_64"`
+	TimeTaken          float32 `parquet:"name=time_taken, type=FLOAT"`
+	XforwardedFor      string  `parquet:"name=xforwarded_for, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SslProtocol        string  `parquet:"name=ssl_protocol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	SslCipher          string  `parquet:"name=ssl_cipher, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ResponseResultType string  `parquet:"name=response_result_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	HTTPVersion        string  `parquet:"name=http_version, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FleStatus          string  `parquet:"name=fle_status, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FleEncryptedFields int32   `parquet:"name=fle_encrypted_fields, type=INT32, convertedtype=UINT_32"`
+}
+
+// NewWebRow converts l into WebRow.
+func NewWebRow(l *cflogparser.WebLog) *WebRow {
+	return &WebRow{
+		Time:               millis(l),
+		Location:           l.Location,
+		Bytes:              int64(l.Bytes),
+		RequestIP:          ipString(l.RequestIP),
+		Method:             l.Method,
+		Host:               l.Host,
+		URI:                l.URI,
+		Status:             int32(l.Status),
+		Referrer:           l.Referrer,
+		UserAgent:          l.UserAgent,
+		QueryString:        l.QueryString,
+		Cookie:             l.Cookie,
+		ResultType:         l.ResultType,
+		RequestID:          l.RequestID,
+		HostHeader:         l.HostHeader,
+		RequestProtocol:    l.RequestProtocol,
+		RequestBytes:       int64(l.RequestBytes),
+		TimeTaken:          l.TimeTaken,
+		XforwardedFor:      l.XforwardedFor,
+		SslProtocol:        l.SslProtocol,
+		SslCipher:          l.SslCipher,
+		ResponseResultType: l.ResponseResultType,
+		HTTPVersion:        l.HTTPVersion,
+		FleStatus:          l.FleStatus,
+		FleEncryptedFields: int32(l.FleEncryptedFields),
+	}
+}
+
+// RTMPRow is a row of Parquet file for RTMPLog.
+type RTMPRow struct {
+	Time          int64  `parquet:"name=time, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	Location      string `parquet:"name=location, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	RequestIP     string `parquet:"name=request_ip, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EventType     string `parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Bytes         int64  `parquet:"name=bytes, type=INT64, convertedtype=UINT_64"`
+	Status        string `parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ClientID      string `parquet:"name=client_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	URI           string `parquet:"name=uri, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	QueryString   string `parquet:"name=query_string, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Referrer      string `parquet:"name=referrer, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PageURL       string `parquet:"name=page_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	UserAgent     string `parquet:"name=user_agent, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	StreamName    string `parquet:"name=stream_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StreamQuery   string `parquet:"name=stream_query, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StreamFileExt string `parquet:"name=stream_file_ext, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	StreamID      int32  `parquet:"name=stream_id, type=INT32, convertedtype=UINT_32"`
+}
+
+// NewRTMPRow converts l into RTMPRow.
+func NewRTMPRow(l *cflogparser.RTMPLog) *RTMPRow {
+	return &RTMPRow{
+		Time:          millis(l),
+		Location:      l.Location,
+		RequestIP:     ipString(l.RequestIP),
+		EventType:     l.EventType,
+		Bytes:         int64(l.Bytes),
+		Status:        l.Status,
+		ClientID:      l.ClientID,
+		URI:           l.URI,
+		QueryString:   l.QueryString,
+		Referrer:      l.Referrer,
+		PageURL:       l.PageURL,
+		UserAgent:     l.UserAgent,
+		StreamName:    l.StreamName,
+		StreamQuery:   l.StreamQuery,
+		StreamFileExt: l.StreamFileExt,
+		StreamID:      int32(l.StreamID),
+	}
+}
+
+func millis(rec interface{}) int64 {
+	return cflogparser.RecordTime(rec).UnixNano() / 1e6
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// Options configures Writer. Zero values mean defaults.
+type Options struct {
+	// RowGroupSize is the approximate size of a row group in bytes
+	// (default 128MiB).
+	RowGroupSize int64
+
+	// Compression is one of "snappy" (default), "gzip", "zstd" and "none".
+	Compression string
+
+	// Parallel is the number of goroutines to encode pages (default 4).
+	Parallel int64
+
+	// MaxOpenPartitions is the maximum number of partitions whose files are
+	// kept open by PartitionedWriter (default 8), as each of them buffers a
+	// row group in memory. When it is exceeded, the file of the least
+	// recently written partition is finished, and a new file is started if
+	// the partition is written again. Negative means no limit.
+	MaxOpenPartitions int
+}
+
+// Writer writes records into a Parquet file.
+type Writer struct {
+	pw   *writer.ParquetWriter
+	rtmp bool
+}
+
+// NewWriter returns a Writer writing Parquet file to w. If rtmp is true,
+// records must be *RTMPLog, otherwise *WebLog.
+func NewWriter(w io.Writer, rtmp bool, opts Options) (*Writer, error) {
+	codec := pq.CompressionCodec_SNAPPY
+	switch strings.ToLower(opts.Compression) {
+	case "", "snappy":
+	case "gzip":
+		codec = pq.CompressionCodec_GZIP
+	case "zstd":
+		codec = pq.CompressionCodec_ZSTD
+	case "none":
+		codec = pq.CompressionCodec_UNCOMPRESSED
+	default:
+		return nil, fmt.Errorf("Unsupported compression: %s", opts.Compression)
+	}
+	if opts.Parallel <= 0 {
+		opts.Parallel = 4
+	}
+	var schema interface{} = new(WebRow)
+	if rtmp {
+		schema = new(RTMPRow)
+	}
+	pw, err := writer.NewParquetWriterFromWriter(w, schema, opts.Parallel)
+	if err != nil {
+		return nil, err
+	}
+	pw.CompressionType = codec
+	if opts.RowGroupSize > 0 {
+		pw.RowGroupSize = opts.RowGroupSize
+	}
+	return &Writer{pw: pw, rtmp: rtmp}, nil
+}
+
+// Write writes rec, which must be *WebLog or *RTMPLog as given to NewWriter.
+func (w *Writer) Write(rec interface{}) error {
+	switch l := rec.(type) {
+	case *cflogparser.WebLog:
+		if !w.rtmp {
+			return w.pw.Write(NewWebRow(l))
+		}
+	case *cflogparser.RTMPLog:
+		if w.rtmp {
+			return w.pw.Write(NewRTMPRow(l))
+		}
+	}
+	return fmt.Errorf("Unexpected record type: %T", rec)
+}
+
+// Close flushes buffered rows and writes the footer. It doesn't close the
+// underlying writer.
+func (w *Writer) Close() error {
+	return w.pw.WriteStop()
+}
@@ -0,0 +1,174 @@
+package parquet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// Rows must have the same fields as records, so that the schema follows
+// changes of WebLog and RTMPLog.
+func TestSchema(t *testing.T) {
+	check := func(row reflect.Type, fields []cflogparser.Field) {
+		if row.NumField() != len(fields) {
+			t.Fatalf("%s has %d fields, want %d", row, row.NumField(), len(fields))
+		}
+		for i, f := range fields {
+			tag := row.Field(i).Tag.Get("parquet")
+			if !strings.HasPrefix(tag, "name="+f.Name+",") {
+				t.Errorf("%s.%s: got tag %q, want name %s", row, row.Field(i).Name, tag, f.Name)
+			}
+		}
+	}
+	check(reflect.TypeOf(WebRow{}), cflogparser.WebLogFields)
+	check(reflect.TypeOf(RTMPRow{}), cflogparser.RTMPLogFields)
+}
+
+func readRTMPLogs(t *testing.T) []*cflogparser.RTMPLog {
+	t.Helper()
+	r, err := cflogparser.OpenReader("../testdata/sample-rtmp.log", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var ls []*cflogparser.RTMPLog
+	for {
+		rec, err := r.Read()
+		if err != nil {
+			return ls
+		}
+		ls = append(ls, rec.(*cflogparser.RTMPLog))
+	}
+}
+
+func TestWriter(t *testing.T) {
+	logs := readRTMPLogs(t)
+	for _, c := range []string{"snappy", "gzip", "none"} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, true, Options{Compression: c})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range logs {
+			if err := w.Write(l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Write(&cflogparser.WebLog{}); err == nil {
+			t.Error("expected error for WebLog")
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := buffer.NewBufferFile(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		pr, err := reader.NewParquetReader(f, new(RTMPRow), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := pr.GetNumRows(); n != int64(len(logs)) {
+			t.Fatalf("%s: got %d rows, want %d", c, n, len(logs))
+		}
+		rows := make([]RTMPRow, len(logs))
+		if err := pr.Read(&rows); err != nil {
+			t.Fatal(err)
+		}
+		pr.ReadStop()
+		for i, l := range logs {
+			if want := *NewRTMPRow(l); rows[i] != want {
+				t.Errorf("%s: got %+v, want %+v", c, rows[i], want)
+			}
+		}
+		if got := time.Unix(0, rows[0].Time*1e6).UTC(); !got.Equal(logs[0].Time) {
+			t.Errorf("got time %s, want %s", got, logs[0].Time)
+		}
+	}
+	if _, err := NewWriter(ioutil.Discard, false, Options{Compression: "lzo"}); err == nil {
+		t.Error("expected error for unsupported compression")
+	}
+}
+
+func TestDistributionID(t *testing.T) {
+	if id := DistributionID("logs/E2EXAMPLE.2019-10-01-00.a1b2c3d4.gz"); id != "E2EXAMPLE" {
+		t.Errorf("got %q, want E2EXAMPLE", id)
+	}
+	if id := DistributionID("access.log"); id != "" {
+		t.Errorf("got %q, want empty", id)
+	}
+}
+
+func TestPartitionedWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cflogparquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	day1 := time.Date(2019, 10, 1, 23, 59, 59, 0, time.UTC)
+	day2 := day1.Add(time.Second)
+	for run := 0; run < 2; run++ {
+		pw := NewPartitionedWriter(dir, false, Options{})
+		for _, r := range []struct {
+			dist string
+			t    time.Time
+		}{{"E1", day1}, {"E1", day2}, {"E2", day1}, {"", day2}} {
+			if err := pw.Write(r.dist, &cflogparser.WebLog{Time: r.t}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := []string{
+			"date=2019-10-01/distribution=E1",
+			"date=2019-10-01/distribution=E2",
+			"date=2019-10-02",
+			"date=2019-10-02/distribution=E1",
+		}
+		if got := pw.Partitions(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "date=2019-10-01", "distribution=E1", "*.parquet"))
+	if len(files) != 2 || filepath.Base(files[1]) != "part-00001.parquet" {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	// With one open partition, a partition written again gets a new file.
+	pw := NewPartitionedWriter(dir, false, Options{MaxOpenPartitions: 1})
+	for _, dist := range []string{"E3", "E4", "E3"} {
+		if err := pw.Write(dist, &cflogparser.WebLog{Time: day1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pw.parts) != 1 {
+		t.Errorf("got %d open partitions, want 1", len(pw.parts))
+	}
+	if got := pw.Partitions(); len(got) != 2 {
+		t.Errorf("got partitions %v", got)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ = filepath.Glob(filepath.Join(dir, "date=2019-10-01", "distribution=E3", "*.parquet"))
+	if len(files) != 2 {
+		t.Errorf("unexpected files: %v", files)
+	}
+	for _, file := range files {
+		if fi, err := os.Stat(file); err != nil || fi.Size() < 8 {
+			t.Errorf("%s is not finished: %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package parquet
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+var distributionRe = regexp.MustCompile(`^([A-Z0-9]+)\.\d{4}-\d{2}-\d{2}-\d{2}\.`)
+
+// DistributionID returns the distribution ID in the name of log file that
+// CloudFront delivers, e.g. "E2EXAMPLE" for
+// "E2EXAMPLE.2019-10-01-00.a1b2c3d4.gz". It returns "" if name doesn't
+// follow the convention.
+func DistributionID(name string) string {
+	m := distributionRe.FindStringSubmatch(filepath.Base(name))
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// PartitionPath returns Hive-style path of the partition for rec, such as
+// "date=2019-10-01/distribution=E2EXAMPLE". If distribution is empty, it is
+// omitted.
+func PartitionPath(rec interface{}, distribution string) string {
+	p := "date=" + cflogparser.RecordTime(rec).UTC().Format("2006-01-02")
+	if distribution != "" {
+		p += "/distribution=" + distribution
+	}
+	return p
+}
+
+// PartitionedWriter writes records into Parquet files under a directory,
+// partitioned by date and distribution, so that query engines can skip
+// partitions by the conditions. At most Options.MaxOpenPartitions files
+// are open at once.
+type PartitionedWriter struct {
+	dir     string
+	rtmp    bool
+	opts    Options
+	parts   map[string]*partition
+	written map[string]bool
+	tick    uint64
+}
+
+type partition struct {
+	f    *os.File
+	b    *bufio.Writer
+	w    *Writer
+	used uint64
+}
+
+// NewPartitionedWriter returns a PartitionedWriter writing into dir.
+func NewPartitionedWriter(dir string, rtmp bool, opts Options) *PartitionedWriter {
+	return &PartitionedWriter{dir: dir, rtmp: rtmp, opts: opts, parts: map[string]*partition{}, written: map[string]bool{}}
+}
+
+// Write writes rec of distribution into its partition. A new file is
+// created in each partition, not to overwrite files written before.
+func (pw *PartitionedWriter) Write(distribution string, rec interface{}) error {
+	path := PartitionPath(rec, distribution)
+	p, ok := pw.parts[path]
+	if !ok {
+		max := pw.opts.MaxOpenPartitions
+		if max == 0 {
+			max = 8
+		}
+		if max > 0 && len(pw.parts) >= max {
+			if err := pw.closeLeastRecent(); err != nil {
+				return err
+			}
+		}
+		var err error
+		p, err = pw.create(path)
+		if err != nil {
+			return err
+		}
+		pw.parts[path] = p
+		pw.written[path] = true
+	}
+	pw.tick++
+	p.used = pw.tick
+	return p.w.Write(rec)
+}
+
+// closeLeastRecent finishes the file of the least recently written
+// partition.
+func (pw *PartitionedWriter) closeLeastRecent() error {
+	var lru string
+	for path, p := range pw.parts {
+		if lru == "" || p.used < pw.parts[lru].used {
+			lru = path
+		}
+	}
+	p := pw.parts[lru]
+	delete(pw.parts, lru)
+	if err := p.close(); err != nil {
+		return fmt.Errorf("%s: %w", lru, err)
+	}
+	return nil
+}
+
+func (pw *PartitionedWriter) create(path string) (*partition, error) {
+	dir := filepath.Join(pw.dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	var f *os.File
+	for i := 0; ; i++ {
+		var err error
+		f, err = os.OpenFile(filepath.Join(dir, fmt.Sprintf("part-%05d.parquet", i)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+	}
+	b := bufio.NewWriter(f)
+	w, err := NewWriter(b, pw.rtmp, pw.opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &partition{f: f, b: b, w: w}, nil
+}
+
+func (p *partition) close() error {
+	err := p.w.Close()
+	if err == nil {
+		err = p.b.Flush()
+	}
+	if e := p.f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Partitions returns paths of partitions written so far, sorted.
+func (pw *PartitionedWriter) Partitions() []string {
+	ps := make([]string, 0, len(pw.written))
+	for p := range pw.written {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+// Close finishes and closes all files.
+func (pw *PartitionedWriter) Close() error {
+	paths := make([]string, 0, len(pw.parts))
+	for path := range pw.parts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var err error
+	for _, path := range paths {
+		if e := pw.parts[path].close(); e != nil && err == nil {
+			err = fmt.Errorf("%s: %w", path, e)
+		}
+	}
+	pw.parts = map[string]*partition{}
+	return err
+}
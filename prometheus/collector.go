@@ -0,0 +1,280 @@
+// Package prometheus aggregates WebLogs into counters and histograms, and
+// exposes them in the text format of Prometheus.
+//
+// Metrics are labeled by fields of WebLog, such as status and location.
+// Since some fields, notably uri, can take unbounded number of values, the
+// number of distinct values of each label is limited: once the limit is
+// reached, new values are replaced with OtherValue. Since combinations of
+// values can still be as many as the product of the limits, the number of
+// series is also limited: once the limit is reached, records of a new
+// combination are counted in the series whose labels are all OtherValue.
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// ContentType is the media type of the text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// OtherValue replaces values of a label exceeding its limit.
+const OtherValue = "other"
+
+// DefaultLabels is the default of Options.Labels.
+var DefaultLabels = []string{"status", "location", "result_type"}
+
+// DefaultBuckets is the default of Options.Buckets, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Options configures Collector.
+type Options struct {
+	// Labels are names of fields of WebLog to label metrics with, e.g.
+	// "status" or "uri", and "status_class" for the class of status, e.g.
+	// "2xx". It is DefaultLabels if nil.
+	Labels []string
+	// Limit is the maximum number of distinct values of each label. It is
+	// 100 if zero, and unlimited if negative.
+	Limit int
+	// Limits overrides Limit for each label, e.g. {"uri": 1000}.
+	Limits map[string]int
+	// MaxSeries is the maximum number of combinations of label values,
+	// besides the one of all OtherValue. It is 10000 if zero, and unlimited
+	// if negative.
+	MaxSeries int
+	// Buckets are upper bounds of buckets of the histogram of TimeTaken, in
+	// increasing order. It is DefaultBuckets if nil.
+	Buckets []float64
+}
+
+type label struct {
+	name   string
+	value  func(*cflogparser.WebLog) string
+	limit  int
+	values map[string]bool
+}
+
+// series holds metrics of a combination of label values.
+type series struct {
+	labels   []string
+	requests uint64
+	bytes    uint64
+	counts   []uint64 // Counts of buckets, not cumulative
+	sum      float64
+}
+
+// Collector aggregates WebLogs. It is safe for concurrent use, and serves
+// metrics as http.Handler.
+type Collector struct {
+	mu          sync.Mutex
+	labels      []*label
+	maxSeries   int
+	buckets     []float64
+	series      map[string]*series
+	parseErrors uint64
+}
+
+// NewCollector returns an empty Collector.
+func NewCollector(opts Options) (*Collector, error) {
+	names := opts.Labels
+	if names == nil {
+		names = DefaultLabels
+	}
+	c := &Collector{buckets: opts.Buckets, maxSeries: opts.MaxSeries, series: map[string]*series{}}
+	if c.maxSeries == 0 {
+		c.maxSeries = 10000
+	}
+	if c.buckets == nil {
+		c.buckets = DefaultBuckets
+	}
+	for i := 1; i < len(c.buckets); i++ {
+		if c.buckets[i] <= c.buckets[i-1] {
+			return nil, fmt.Errorf("Buckets are not in increasing order: %v", c.buckets)
+		}
+	}
+	for _, name := range names {
+		l := &label{name: name, limit: opts.Limit, values: map[string]bool{}}
+		if n, ok := opts.Limits[name]; ok {
+			l.limit = n
+		}
+		if l.limit == 0 {
+			l.limit = 100
+		}
+		if name == "status_class" {
+			l.value = func(w *cflogparser.WebLog) string {
+				return strconv.Itoa(int(w.Status/100)) + "xx"
+			}
+		} else {
+			f, err := cflogparser.LookupField(&cflogparser.WebLog{}, name)
+			if err != nil {
+				return nil, err
+			}
+			l.value = func(w *cflogparser.WebLog) string { return f.String(w) }
+		}
+		c.labels = append(c.labels, l)
+	}
+	return c, nil
+}
+
+// Add counts l.
+func (c *Collector) Add(l *cflogparser.WebLog) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values := make([]string, len(c.labels))
+	for i, lb := range c.labels {
+		v := lb.value(l)
+		if !lb.values[v] {
+			if lb.limit >= 0 && len(lb.values) >= lb.limit {
+				v = OtherValue
+			} else {
+				lb.values[v] = true
+			}
+		}
+		values[i] = v
+	}
+	key := strings.Join(values, "\x00")
+	s := c.series[key]
+	if s == nil && c.maxSeries >= 0 && len(c.series) >= c.maxSeries {
+		for i := range values {
+			values[i] = OtherValue
+		}
+		key = strings.Join(values, "\x00")
+		s = c.series[key]
+	}
+	if s == nil {
+		s = &series{labels: values, counts: make([]uint64, len(c.buckets)+1)}
+		c.series[key] = s
+	}
+	s.requests++
+	s.bytes += l.Bytes
+	// Shortest decimal of float32, so that e.g. 0.1 falls in the bucket of 0.1
+	t, _ := strconv.ParseFloat(strconv.FormatFloat(float64(l.TimeTaken), 'g', -1, 32), 64)
+	s.counts[sort.SearchFloat64s(c.buckets, t)]++
+	s.sum += t
+}
+
+// AddParseError counts a line that can't be parsed.
+func (c *Collector) AddParseError() {
+	c.mu.Lock()
+	c.parseErrors++
+	c.mu.Unlock()
+}
+
+// WriteTo writes metrics in the text format to w.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ss := make([]*series, 0, len(c.series))
+	for _, s := range c.series {
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		a, b := ss[i].labels, ss[j].labels
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	header := func(name, typ, help string) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	header("cloudfront_requests_total", "counter", "Number of requests.")
+	for _, s := range ss {
+		fmt.Fprintf(cw, "cloudfront_requests_total%s %d\n", c.labelSet(s, ""), s.requests)
+	}
+	header("cloudfront_bytes_total", "counter", "Bytes served to viewers.")
+	for _, s := range ss {
+		fmt.Fprintf(cw, "cloudfront_bytes_total%s %d\n", c.labelSet(s, ""), s.bytes)
+	}
+	header("cloudfront_time_taken_seconds", "histogram", "Time taken to serve requests.")
+	for _, s := range ss {
+		var n uint64
+		for i, b := range c.buckets {
+			n += s.counts[i]
+			fmt.Fprintf(cw, "cloudfront_time_taken_seconds_bucket%s %d\n", c.labelSet(s, formatFloat(b)), n)
+		}
+		n += s.counts[len(c.buckets)]
+		fmt.Fprintf(cw, "cloudfront_time_taken_seconds_bucket%s %d\n", c.labelSet(s, "+Inf"), n)
+		fmt.Fprintf(cw, "cloudfront_time_taken_seconds_sum%s %s\n", c.labelSet(s, ""), formatFloat(s.sum))
+		fmt.Fprintf(cw, "cloudfront_time_taken_seconds_count%s %d\n", c.labelSet(s, ""), n)
+	}
+	header("cloudfront_parse_errors_total", "counter", "Number of lines that can't be parsed.")
+	fmt.Fprintf(cw, "cloudfront_parse_errors_total %d\n", c.parseErrors)
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// labelSet formats labels of s, followed by le if not empty.
+func (c *Collector) labelSet(s *series, le string) string {
+	if len(c.labels) == 0 && le == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, lb := range c.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(lb.name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(s.labels[i]))
+		b.WriteByte('"')
+	}
+	if le != "" {
+		if len(c.labels) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`le="`)
+		b.WriteString(le)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countWriter counts bytes written, and keeps the first error.
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+// ServeHTTP serves metrics, typically at /metrics.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	c.WriteTo(w)
+}
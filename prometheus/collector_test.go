@@ -0,0 +1,142 @@
+package prometheus
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+const line = "2019-10-01\t12:34:56\tFRA2\t182\t192.0.2.10\tGET\td111111abcdef8.cloudfront.net\t/index.html\t200\t-\tMozilla/5.0\t-\t-\tHit\tid-1\texample.com\thttps\t100\t0.001\t-\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\tHit\tHTTP/1.1\t-\t-"
+
+// logLine returns line with uri, status and time taken replaced.
+func logLine(uri, status, timeTaken string) string {
+	fs := strings.Split(line, "\t")
+	fs[7], fs[8], fs[18] = uri, status, timeTaken
+	return strings.Join(fs, "\t")
+}
+
+func webLog(t *testing.T, uri, status, timeTaken string) *cflogparser.WebLog {
+	t.Helper()
+	l, err := cflogparser.ParseLineWeb(logLine(uri, status, timeTaken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestCollector(t *testing.T) {
+	c, err := NewCollector(Options{
+		Labels:  []string{"status_class", "uri"},
+		Limits:  map[string]int{"uri": 2},
+		Buckets: []float64{0.1, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(webLog(t, "/a", "200", "0.1"))
+	c.Add(webLog(t, "/a", "200", "0.5"))
+	c.Add(webLog(t, "/b\"", "404", "2"))
+	c.Add(webLog(t, "/c", "200", "0.01"))
+	c.Add(webLog(t, "/d", "200", "0.01"))
+	c.Add(webLog(t, "/a", "503", "0.01"))
+	c.AddParseError()
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("got content type %q", ct)
+	}
+	want := `# HELP cloudfront_requests_total Number of requests.
+# TYPE cloudfront_requests_total counter
+cloudfront_requests_total{status_class="2xx",uri="/a"} 2
+cloudfront_requests_total{status_class="2xx",uri="other"} 2
+cloudfront_requests_total{status_class="4xx",uri="/b\""} 1
+cloudfront_requests_total{status_class="5xx",uri="/a"} 1
+# HELP cloudfront_bytes_total Bytes served to viewers.
+# TYPE cloudfront_bytes_total counter
+cloudfront_bytes_total{status_class="2xx",uri="/a"} 364
+cloudfront_bytes_total{status_class="2xx",uri="other"} 364
+cloudfront_bytes_total{status_class="4xx",uri="/b\""} 182
+cloudfront_bytes_total{status_class="5xx",uri="/a"} 182
+# HELP cloudfront_time_taken_seconds Time taken to serve requests.
+# TYPE cloudfront_time_taken_seconds histogram
+cloudfront_time_taken_seconds_bucket{status_class="2xx",uri="/a",le="0.1"} 1
+cloudfront_time_taken_seconds_bucket{status_class="2xx",uri="/a",le="1"} 2
+cloudfront_time_taken_seconds_bucket{status_class="2xx",uri="/a",le="+Inf"} 2
+cloudfront_time_taken_seconds_sum{status_class="2xx",uri="/a"} 0.6
+cloudfront_time_taken_seconds_count{status_class="2xx",uri="/a"} 2
+cloudfront_time_taken_seconds_bucket{status_class="2xx",uri="other",le="0.1"} 2
+cloudfront_time_taken_seconds_bucket{status_class="2xx",uri="other",le="1"} 2
+cloudfront_time_taken_seconds_bucket{status_class="2xx",uri="other",le="+Inf"} 2
+cloudfront_time_taken_seconds_sum{status_class="2xx",uri="other"} 0.02
+cloudfront_time_taken_seconds_count{status_class="2xx",uri="other"} 2
+cloudfront_time_taken_seconds_bucket{status_class="4xx",uri="/b\"",le="0.1"} 0
+cloudfront_time_taken_seconds_bucket{status_class="4xx",uri="/b\"",le="1"} 0
+cloudfront_time_taken_seconds_bucket{status_class="4xx",uri="/b\"",le="+Inf"} 1
+cloudfront_time_taken_seconds_sum{status_class="4xx",uri="/b\""} 2
+cloudfront_time_taken_seconds_count{status_class="4xx",uri="/b\""} 1
+cloudfront_time_taken_seconds_bucket{status_class="5xx",uri="/a",le="0.1"} 1
+cloudfront_time_taken_seconds_bucket{status_class="5xx",uri="/a",le="1"} 1
+cloudfront_time_taken_seconds_bucket{status_class="5xx",uri="/a",le="+Inf"} 1
+cloudfront_time_taken_seconds_sum{status_class="5xx",uri="/a"} 0.01
+cloudfront_time_taken_seconds_count{status_class="5xx",uri="/a"} 1
+# HELP cloudfront_parse_errors_total Number of lines that can't be parsed.
+# TYPE cloudfront_parse_errors_total counter
+cloudfront_parse_errors_total 1
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCollectorNoLabels(t *testing.T) {
+	c, err := NewCollector(Options{Labels: []string{}, Buckets: []float64{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(webLog(t, "/", "200", "0.5"))
+	var b strings.Builder
+	if _, err := c.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"\ncloudfront_requests_total 1\n", "\ncloudfront_time_taken_seconds_bucket{le=\"1\"} 1\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("%q is not found in:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestCollectorMaxSeries(t *testing.T) {
+	c, err := NewCollector(Options{Labels: []string{"uri", "status"}, Limit: -1, MaxSeries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uri := range []string{"/a", "/b", "/c", "/d", "/a"} {
+		c.Add(webLog(t, uri, "200", "0.1"))
+	}
+	var b strings.Builder
+	c.WriteTo(&b)
+	for _, want := range []string{
+		`cloudfront_requests_total{uri="/a",status="200"} 2`,
+		`cloudfront_requests_total{uri="/b",status="200"} 1`,
+		`cloudfront_requests_total{uri="other",status="other"} 2`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("%q is not found in:\n%s", want, b.String())
+		}
+	}
+	if len(c.series) != 3 {
+		t.Errorf("got %d series, want 3", len(c.series))
+	}
+}
+
+func TestNewCollectorError(t *testing.T) {
+	if _, err := NewCollector(Options{Labels: []string{"no_such_field"}}); err == nil {
+		t.Error("expected error for unknown label")
+	}
+	if _, err := NewCollector(Options{Buckets: []float64{1, 0.5}}); err == nil {
+		t.Error("expected error for buckets out of order")
+	}
+}
@@ -0,0 +1,179 @@
+package prometheus
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// Tailer reads log files matching glob patterns into Collector. Each Poll
+// reads files which appeared and lines appended since the last Poll.
+//
+// Gzipped files, as CloudFront delivers, are read once as a whole after
+// they are completely written. Plain files are followed as they grow, and
+// read from the beginning again if truncated, or replaced by another file,
+// e.g. rotated by rename.
+type Tailer struct {
+	// FromEnd makes Tailer skip the content of files found at the first
+	// Poll, so that only logs arriving later are counted.
+	FromEnd bool
+
+	c        *Collector
+	patterns []string
+	files    map[string]*tailFile
+	polled   bool
+}
+
+type tailFile struct {
+	info   os.FileInfo // To tell if the file is replaced
+	offset int64       // Offset of the next line of a plain file
+	done   bool        // A gzipped file is read
+}
+
+// NewTailer returns a Tailer adding logs in files matching patterns, in the
+// syntax of filepath.Match, to c.
+func NewTailer(c *Collector, patterns ...string) *Tailer {
+	return &Tailer{c: c, patterns: patterns, files: map[string]*tailFile{}}
+}
+
+// Poll reads new logs. Failures of files are reported as an error after
+// reading the other files, and they are tried again at the next Poll.
+func (t *Tailer) Poll() error {
+	first := !t.polled
+	t.polled = true
+	seen := map[string]bool{}
+	var errs []string
+	for _, p := range t.patterns {
+		names, err := filepath.Glob(p)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			f := t.files[name]
+			if f == nil {
+				f = &tailFile{}
+				t.files[name] = f
+			}
+			if err := t.read(name, f, first && t.FromEnd); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	// Forget files which have gone, e.g. archived
+	for name := range t.files {
+		if !seen[name] {
+			delete(t.files, name)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+func (t *Tailer) read(name string, f *tailFile, skip bool) error {
+	if f.done {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if os.SameFile(f.info, fi) {
+			return nil
+		}
+	}
+	fp, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	fi, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+	if f.info != nil && !os.SameFile(f.info, fi) {
+		// Replaced by another file
+		*f = tailFile{}
+	}
+	f.info = fi
+	br := bufio.NewReader(fp)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		if skip {
+			f.done = true
+			return nil
+		}
+		if err := t.readGzip(br); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		f.done = true
+		return nil
+	}
+
+	switch {
+	case skip:
+		f.offset = fi.Size()
+	case fi.Size() < f.offset:
+		// Truncated, e.g. rotated in place
+		f.offset = 0
+	}
+	if f.offset == fi.Size() {
+		return nil
+	}
+	if _, err := fp.Seek(f.offset, io.SeekStart); err != nil {
+		return err
+	}
+	br.Reset(fp)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			// The last line is incomplete, which is read at the next Poll.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f.offset += int64(len(line))
+		t.add(line)
+	}
+}
+
+// readGzip adds logs in a gzipped file, only if it is complete, so that
+// logs are not counted twice when the file is retried.
+func (t *Tailer) readGzip(r io.Reader) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		t.add(line)
+	}
+	return nil
+}
+
+func (t *Tailer) add(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	l, err := cflogparser.ParseLineWeb(line)
+	if err != nil {
+		t.c.AddParseError()
+		return
+	}
+	t.c.Add(l)
+}
@@ -0,0 +1,157 @@
+package prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// requests returns the number of requests counted by c.
+func requests(t *testing.T, c *Collector) int {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, s := range c.series {
+		n += int(s.requests)
+	}
+	return n
+}
+
+func appendFile(t *testing.T, name, s string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write([]byte(s))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestTailerPlain(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "access.log")
+	c, _ := NewCollector(Options{})
+	tl := NewTailer(c, filepath.Join(dir, "*.log"))
+
+	l := logLine("/", "200", "0.1")
+	appendFile(t, name, "#Version: 1.0\n"+l+"\n"+l+"\n"+l[:10])
+	if err := tl.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests(t, c); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+
+	// The incomplete line is read when completed.
+	appendFile(t, name, l[10:]+"\nbroken\n")
+	if err := tl.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests(t, c); n != 3 || c.parseErrors != 1 {
+		t.Errorf("got %d requests and %d errors, want 3 and 1", n, c.parseErrors)
+	}
+	tl.Poll()
+	if n := requests(t, c); n != 3 {
+		t.Errorf("got %d requests after no change, want 3", n)
+	}
+
+	// Truncated and written again
+	if err := ioutil.WriteFile(name, []byte(l+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tl.Poll()
+	if n := requests(t, c); n != 4 {
+		t.Errorf("got %d requests after truncation, want 4", n)
+	}
+	// Rotated by rename, and the new file has grown beyond the old offset
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, name, strings.Repeat(l+"\n", 3))
+	tl.Poll()
+	if n := requests(t, c); n != 7 {
+		t.Errorf("got %d requests after rotation, want 7", n)
+	}
+}
+
+func TestTailerGzip(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := NewCollector(Options{})
+	tl := NewTailer(c, filepath.Join(dir, "*.gz"), filepath.Join(dir, "E2*"))
+
+	l := logLine("/", "200", "0.1")
+	gz := gzipped(t, "#Version: 1.0\n"+strings.Repeat(l+"\n", 3))
+	name := filepath.Join(dir, "E2EXAMPLE.2019-10-01-12.abcd.gz")
+	// Being uploaded
+	if err := ioutil.WriteFile(name, gz[:len(gz)-10], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := tl.Poll(); err == nil {
+		t.Error("expected error for incomplete file")
+	}
+	if n := requests(t, c); n != 0 {
+		t.Errorf("got %d requests from incomplete file", n)
+	}
+	ioutil.WriteFile(name, gz, 0644)
+	for i := 0; i < 2; i++ {
+		if err := tl.Poll(); err != nil {
+			t.Fatal(err)
+		}
+		if n := requests(t, c); n != 3 {
+			t.Errorf("got %d requests, want 3", n)
+		}
+	}
+	// Replaced by another file of the same name
+	ioutil.WriteFile(name+".tmp", gzipped(t, l+"\n"), 0644)
+	if err := os.Rename(name+".tmp", name); err != nil {
+		t.Fatal(err)
+	}
+	if err := tl.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests(t, c); n != 4 {
+		t.Errorf("got %d requests after replacement, want 4", n)
+	}
+}
+
+func TestTailerFromEnd(t *testing.T) {
+	dir := t.TempDir()
+	l := logLine("/", "200", "0.1")
+	ioutil.WriteFile(filepath.Join(dir, "old.gz"), gzipped(t, l+"\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "access.log"), []byte(l+"\n"), 0644)
+	c, _ := NewCollector(Options{})
+	tl := NewTailer(c, filepath.Join(dir, "*"))
+	tl.FromEnd = true
+	if err := tl.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests(t, c); n != 0 {
+		t.Errorf("got %d requests from existing files", n)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "new.gz"), gzipped(t, l+"\n"), 0644)
+	appendFile(t, filepath.Join(dir, "access.log"), l+"\n")
+	if err := tl.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests(t, c); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
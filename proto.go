@@ -0,0 +1,419 @@
+package cflogparser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// ProtoSchema is the Protocol Buffers definition of WebLog and RTMPLog
+// messages, which is published as schema/cflog.proto.
+const ProtoSchema = `// Protocol Buffers messages of WebLog and RTMPLog. Field names are the JSON
+// names of struct fields, numbered in the order of the log format. Unsigned
+// integers are kept unsigned, and IP addresses are strings.
+syntax = "proto3";
+
+package cflogparser;
+
+import "google/protobuf/timestamp.proto";
+
+option go_package = "github.com/Maki-Daisuke/cflogparser";
+
+message WebLog {
+  google.protobuf.Timestamp time = 1;
+  string location = 2;
+  uint64 bytes = 3;
+  string request_ip = 4;
+  string method = 5;
+  string host = 6;
+  string uri = 7;
+  uint32 status = 8;
+  string referrer = 9;
+  string user_agent = 10;
+  string query_string = 11;
+  string cookie = 12;
+  string result_type = 13;
+  string request_id = 14;
+  string host_header = 15;
+  string request_protocol = 16;
+  uint64 request_bytes = 17;
+  float time_taken = 18;
+  string xforwarded_for = 19;
+  string ssl_protocol = 20;
+  string ssl_cipher = 21;
+  string response_result_type = 22;
+  string http_version = 23;
+  string fle_status = 24;
+  uint32 fle_encrypted_fields = 25;
+}
+
+message RTMPLog {
+  google.protobuf.Timestamp time = 1;
+  string location = 2;
+  string request_ip = 3;
+  string event_type = 4;
+  uint64 bytes = 5;
+  string status = 6;
+  string client_id = 7;
+  string uri = 8;
+  string query_string = 9;
+  string referrer = 10;
+  string page_url = 11;
+  string user_agent = 12;
+  string stream_name = 13;
+  string stream_query = 14;
+  string stream_file_ext = 15;
+  uint32 stream_id = 16;
+}`
+
+// Wire types of Protocol Buffers
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+var errProtoShort = errors.New("Unexpected end of Protocol Buffers message")
+
+// AppendProto appends l encoded as WebLog message of ProtoSchema to b, and
+// returns the extended buffer. Fields of zero value are omitted as proto3
+// does.
+func (l *WebLog) AppendProto(b []byte) []byte {
+	b = appendProtoTime(b, 1, l.Time)
+	b = appendProtoString(b, 2, l.Location)
+	b = appendProtoVarint(b, 3, l.Bytes)
+	b = appendProtoString(b, 4, ipString(l.RequestIP))
+	b = appendProtoString(b, 5, l.Method)
+	b = appendProtoString(b, 6, l.Host)
+	b = appendProtoString(b, 7, l.URI)
+	b = appendProtoVarint(b, 8, uint64(l.Status))
+	b = appendProtoString(b, 9, l.Referrer)
+	b = appendProtoString(b, 10, l.UserAgent)
+	b = appendProtoString(b, 11, l.QueryString)
+	b = appendProtoString(b, 12, l.Cookie)
+	b = appendProtoString(b, 13, l.ResultType)
+	b = appendProtoString(b, 14, l.RequestID)
+	b = appendProtoString(b, 15, l.HostHeader)
+	b = appendProtoString(b, 16, l.RequestProtocol)
+	b = appendProtoVarint(b, 17, l.RequestBytes)
+	b = appendProtoFloat(b, 18, l.TimeTaken)
+	b = appendProtoString(b, 19, l.XforwardedFor)
+	b = appendProtoString(b, 20, l.SslProtocol)
+	b = appendProtoString(b, 21, l.SslCipher)
+	b = appendProtoString(b, 22, l.ResponseResultType)
+	b = appendProtoString(b, 23, l.HTTPVersion)
+	b = appendProtoString(b, 24, l.FleStatus)
+	b = appendProtoVarint(b, 25, uint64(l.FleEncryptedFields))
+	return b
+}
+
+// UnmarshalProto decodes b as WebLog message of ProtoSchema into l. Unknown
+// fields are ignored.
+func (l *WebLog) UnmarshalProto(b []byte) error {
+	*l = WebLog{}
+	d := protoDecoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			l.Time = d.time()
+		case 2:
+			l.Location = d.string()
+		case 3:
+			l.Bytes = d.varint()
+		case 4:
+			l.RequestIP = d.ip()
+		case 5:
+			l.Method = d.string()
+		case 6:
+			l.Host = d.string()
+		case 7:
+			l.URI = d.string()
+		case 8:
+			l.Status = uint16(d.varint())
+		case 9:
+			l.Referrer = d.string()
+		case 10:
+			l.UserAgent = d.string()
+		case 11:
+			l.QueryString = d.string()
+		case 12:
+			l.Cookie = d.string()
+		case 13:
+			l.ResultType = d.string()
+		case 14:
+			l.RequestID = d.string()
+		case 15:
+			l.HostHeader = d.string()
+		case 16:
+			l.RequestProtocol = d.string()
+		case 17:
+			l.RequestBytes = d.varint()
+		case 18:
+			l.TimeTaken = d.float()
+		case 19:
+			l.XforwardedFor = d.string()
+		case 20:
+			l.SslProtocol = d.string()
+		case 21:
+			l.SslCipher = d.string()
+		case 22:
+			l.ResponseResultType = d.string()
+		case 23:
+			l.HTTPVersion = d.string()
+		case 24:
+			l.FleStatus = d.string()
+		case 25:
+			l.FleEncryptedFields = uint32(d.varint())
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+// AppendProto appends l encoded as RTMPLog message of ProtoSchema to b, and
+// returns the extended buffer. Fields of zero value are omitted as proto3
+// does.
+func (l *RTMPLog) AppendProto(b []byte) []byte {
+	b = appendProtoTime(b, 1, l.Time)
+	b = appendProtoString(b, 2, l.Location)
+	b = appendProtoString(b, 3, ipString(l.RequestIP))
+	b = appendProtoString(b, 4, l.EventType)
+	b = appendProtoVarint(b, 5, l.Bytes)
+	b = appendProtoString(b, 6, l.Status)
+	b = appendProtoString(b, 7, l.ClientID)
+	b = appendProtoString(b, 8, l.URI)
+	b = appendProtoString(b, 9, l.QueryString)
+	b = appendProtoString(b, 10, l.Referrer)
+	b = appendProtoString(b, 11, l.PageURL)
+	b = appendProtoString(b, 12, l.UserAgent)
+	b = appendProtoString(b, 13, l.StreamName)
+	b = appendProtoString(b, 14, l.StreamQuery)
+	b = appendProtoString(b, 15, l.StreamFileExt)
+	b = appendProtoVarint(b, 16, uint64(l.StreamID))
+	return b
+}
+
+// UnmarshalProto decodes b as RTMPLog message of ProtoSchema into l.
+// Unknown fields are ignored.
+func (l *RTMPLog) UnmarshalProto(b []byte) error {
+	*l = RTMPLog{}
+	d := protoDecoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			l.Time = d.time()
+		case 2:
+			l.Location = d.string()
+		case 3:
+			l.RequestIP = d.ip()
+		case 4:
+			l.EventType = d.string()
+		case 5:
+			l.Bytes = d.varint()
+		case 6:
+			l.Status = d.string()
+		case 7:
+			l.ClientID = d.string()
+		case 8:
+			l.URI = d.string()
+		case 9:
+			l.QueryString = d.string()
+		case 10:
+			l.Referrer = d.string()
+		case 11:
+			l.PageURL = d.string()
+		case 12:
+			l.UserAgent = d.string()
+		case 13:
+			l.StreamName = d.string()
+		case 14:
+			l.StreamQuery = d.string()
+		case 15:
+			l.StreamFileExt = d.string()
+		case 16:
+			l.StreamID = uint32(d.varint())
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+func appendProtoTag(b []byte, num int, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(num<<3|wire))
+}
+
+func appendProtoVarint(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendProtoTag(b, num, protoVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendProtoString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendProtoTag(b, num, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendProtoFloat(b []byte, num int, f float32) []byte {
+	if math.Float32bits(f) == 0 {
+		return b
+	}
+	b = appendProtoTag(b, num, protoFixed32)
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(f))
+}
+
+// appendProtoTime appends t as google.protobuf.Timestamp message, unless t is
+// zero.
+func appendProtoTime(b []byte, num int, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	var m []byte
+	m = appendProtoVarint(m, 1, uint64(t.Unix()))
+	m = appendProtoVarint(m, 2, uint64(t.Nanosecond()))
+	b = appendProtoTag(b, num, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(m)))
+	return append(b, m...)
+}
+
+// protoDecoder reads fields of a message one by one. next reads the tag of
+// the next field into num and wire, and the value must be read by one of
+// the other methods. Once it fails, it keeps the first error.
+type protoDecoder struct {
+	b    []byte
+	num  int
+	wire int
+	err  error
+}
+
+func (d *protoDecoder) next() bool {
+	if d.err != nil || len(d.b) == 0 {
+		return false
+	}
+	tag := d.uvarint()
+	d.num, d.wire = int(tag>>3), int(tag&7)
+	return d.err == nil
+}
+
+func (d *protoDecoder) uvarint() uint64 {
+	u, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errProtoShort
+		return 0
+	}
+	d.b = d.b[n:]
+	return u
+}
+
+func (d *protoDecoder) expect(wire int) bool {
+	if d.err == nil && d.wire != wire {
+		d.err = fmt.Errorf("Unexpected wire type %d of field %d", d.wire, d.num)
+	}
+	return d.err == nil
+}
+
+func (d *protoDecoder) varint() uint64 {
+	if !d.expect(protoVarint) {
+		return 0
+	}
+	return d.uvarint()
+}
+
+func (d *protoDecoder) bytes() []byte {
+	if !d.expect(protoBytes) {
+		return nil
+	}
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.b)) {
+		d.err = errProtoShort
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *protoDecoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *protoDecoder) float() float32 {
+	if !d.expect(protoFixed32) {
+		return 0
+	}
+	if len(d.b) < 4 {
+		d.err = errProtoShort
+		return 0
+	}
+	f := math.Float32frombits(binary.LittleEndian.Uint32(d.b))
+	d.b = d.b[4:]
+	return f
+}
+
+func (d *protoDecoder) time() time.Time {
+	m := protoDecoder{b: d.bytes()}
+	if d.err != nil {
+		return time.Time{}
+	}
+	var sec, nsec int64
+	for m.next() {
+		switch m.num {
+		case 1:
+			sec = int64(m.varint())
+		case 2:
+			nsec = int64(int32(m.varint()))
+		default:
+			m.skip()
+		}
+	}
+	d.err = m.err
+	return time.Unix(sec, nsec).UTC()
+}
+
+func (d *protoDecoder) ip() net.IP {
+	s := d.string()
+	if s == "" {
+		return nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil && d.err == nil {
+		d.err = fmt.Errorf("Invalid IP address: %s", s)
+	}
+	return ip
+}
+
+// skip skips the value of an unknown field.
+func (d *protoDecoder) skip() {
+	switch d.wire {
+	case protoVarint:
+		d.uvarint()
+	case protoBytes:
+		d.bytes()
+	case protoFixed64, protoFixed32:
+		n := 8
+		if d.wire == protoFixed32 {
+			n = 4
+		}
+		if len(d.b) < n {
+			d.err = errProtoShort
+			return
+		}
+		d.b = d.b[n:]
+	default:
+		d.err = fmt.Errorf("Unsupported wire type %d of field %d", d.wire, d.num)
+	}
+}
@@ -0,0 +1,102 @@
+package cflogparser
+
+import (
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProtoSchemaFile(t *testing.T) {
+	b, err := ioutil.ReadFile("schema/cflog.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(b)) != ProtoSchema {
+		t.Error("schema/cflog.proto differs from the schema in code")
+	}
+
+	reMessage := regexp.MustCompile(`(?s)message (\w+) \{(.*?)\}`)
+	reField := regexp.MustCompile(`(?m)^\s+\S+ (\w+) = (\d+);`)
+	messages := map[string][]Field{"WebLog": WebLogFields, "RTMPLog": RTMPLogFields}
+	for _, m := range reMessage.FindAllStringSubmatch(ProtoSchema, -1) {
+		fields, ok := messages[m[1]]
+		if !ok {
+			t.Errorf("unexpected message: %s", m[1])
+			continue
+		}
+		delete(messages, m[1])
+		fs := reField.FindAllStringSubmatch(m[2], -1)
+		if len(fs) != len(fields) {
+			t.Fatalf("%s: got %d fields, want %d", m[1], len(fs), len(fields))
+		}
+		for i, f := range fields {
+			if fs[i][1] != f.Name || fs[i][2] != strconv.Itoa(i+1) {
+				t.Errorf("%s: got field %s = %s, want %s = %d", m[1], fs[i][1], fs[i][2], f.Name, i+1)
+			}
+		}
+	}
+	if len(messages) != 0 {
+		t.Errorf("missing messages: %v", messages)
+	}
+}
+
+func TestProtoEncoding(t *testing.T) {
+	if b := (&WebLog{}).AppendProto(nil); len(b) != 0 {
+		t.Errorf("zero values are not omitted: % x", b)
+	}
+	want := []byte{0x3a, 0x01, '/', 0x40, 0xc8, 0x01}
+	if b := (&WebLog{Status: 200, URI: "/"}).AppendProto(nil); !bytes.Equal(b, want) {
+		t.Errorf("got % x, want % x", b, want)
+	}
+
+	// Unknown fields of each wire type are skipped.
+	b := (&WebLog{Status: 200}).AppendProto(nil)
+	b = appendProtoVarint(b, 99, 1)
+	b = appendProtoString(b, 100, "x")
+	b = appendProtoFloat(b, 101, 1)
+	b = append(appendProtoTag(b, 102, protoFixed64), 1, 2, 3, 4, 5, 6, 7, 8)
+	var l WebLog
+	if err := l.UnmarshalProto(b); err != nil {
+		t.Fatal(err)
+	}
+	if l.Status != 200 {
+		t.Errorf("got status %d, want 200", l.Status)
+	}
+
+	// Field of wrong wire type
+	if err := l.UnmarshalProto(appendProtoString(nil, 8, "200")); err == nil {
+		t.Error("expected error for wrong wire type")
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	for i, want := range codecRecords(t) {
+		var got interface{}
+		var err error
+		switch l := want.(type) {
+		case *WebLog:
+			b := l.AppendProto(nil)
+			g := &WebLog{}
+			err, got = g.UnmarshalProto(b), g
+			if (&WebLog{}).UnmarshalProto(b[:len(b)-1]) == nil {
+				t.Errorf("record %d: expected error for truncated message", i)
+			}
+		case *RTMPLog:
+			b := l.AppendProto(nil)
+			g := &RTMPLog{}
+			err, got = g.UnmarshalProto(b), g
+			if (&RTMPLog{}).UnmarshalProto(b[:len(b)-1]) == nil {
+				t.Errorf("record %d: expected error for truncated message", i)
+			}
+		}
+		if err != nil {
+			t.Fatalf("record %d: %s", i, err)
+		}
+		if g, w := jsonOf(t, got), jsonOf(t, want); g != w {
+			t.Errorf("record %d:\ngot  %s\nwant %s", i, g, w)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package cflogparser
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamQoE summarizes quality of experience of an RTMP stream from its play
+// intervals. As RTMP logs have no buffering information, pauses and seeks
+// per play are provided as proxies of rebuffering and dissatisfaction.
+type StreamQoE struct {
+	StreamName string        `json:"stream_name"`
+	Plays      int           `json:"plays"`
+	Abnormal   int           `json:"abnormal"`
+	WatchTime  time.Duration `json:"watch_time"`
+	Paused     time.Duration `json:"paused"`
+	Pauses     int           `json:"pauses"`
+	Seeks      int           `json:"seeks"`
+	Bytes      uint64        `json:"bytes"`
+}
+
+// AverageWatchTime returns average watch duration per play.
+func (s *StreamQoE) AverageWatchTime() time.Duration {
+	if s.Plays == 0 {
+		return 0
+	}
+	return s.WatchTime / time.Duration(s.Plays)
+}
+
+// PausesPerPlay returns the average number of pauses per play.
+func (s *StreamQoE) PausesPerPlay() float64 {
+	return ratio(uint64(s.Pauses), uint64(s.Plays))
+}
+
+// SeeksPerPlay returns the average number of seeks per play.
+func (s *StreamQoE) SeeksPerPlay() float64 {
+	return ratio(uint64(s.Seeks), uint64(s.Plays))
+}
+
+// Bitrate returns average delivered bitrate in bits per second.
+func (s *StreamQoE) Bitrate() float64 {
+	if s.WatchTime <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) * 8 / s.WatchTime.Seconds()
+}
+
+// CMCD is Common Media Client Data (CTA-5004) sent by a media player as
+// "CMCD" query parameter. Numeric fields are zero if missing.
+type CMCD struct {
+	Bitrate            int     `json:"br"`  // Encoded bitrate in kbps
+	BufferLength       int     `json:"bl"`  // Buffer length in milliseconds
+	BufferStarvation   bool    `json:"bs"`  // Buffer was starved since the last request
+	Duration           int     `json:"d"`   // Object duration in milliseconds
+	MeasuredThroughput int     `json:"mtp"` // Measured throughput in kbps
+	ObjectType         string  `json:"ot"`  // Object type, e.g. "v" for video
+	PlaybackRate       float64 `json:"pr"`  // Playback rate, 1 if real-time
+	StreamType         string  `json:"st"`  // "v" for VOD, "l" for live
+	Startup            bool    `json:"su"`  // Object is needed urgently for startup
+	TopBitrate         int     `json:"tb"`  // Highest bitrate rendition in kbps
+	ContentID          string  `json:"cid"` // Content ID
+	SessionID          string  `json:"sid"` // Playback session ID
+}
+
+// ParseCMCD extracts CMCD from query string of WebLog. It returns false if
+// query has no CMCD parameter or it is not properly URL-encoded. Unknown
+// keys and malformed values are ignored.
+func ParseCMCD(query string) (*CMCD, bool) {
+	var raw string
+	found := false
+	for _, kv := range strings.Split(query, "&") {
+		if strings.HasPrefix(kv, "CMCD=") {
+			raw, found = kv[len("CMCD="):], true
+			break
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	// CMCD is URL-encoded by clients, which is left in QueryString after
+	// unescaping of the log.
+	raw, err := url.QueryUnescape(raw)
+	if err != nil {
+		return nil, false
+	}
+
+	c := &CMCD{}
+	for _, kv := range splitCMCD(raw) {
+		k, v := kv, ""
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			k, v = kv[:i], kv[i+1:]
+		}
+		switch k {
+		case "br":
+			c.Bitrate, _ = strconv.Atoi(v)
+		case "bl":
+			c.BufferLength, _ = strconv.Atoi(v)
+		case "bs":
+			c.BufferStarvation = v == "" || v == "true"
+		case "d":
+			c.Duration, _ = strconv.Atoi(v)
+		case "mtp":
+			c.MeasuredThroughput, _ = strconv.Atoi(v)
+		case "ot":
+			c.ObjectType = v
+		case "pr":
+			c.PlaybackRate, _ = strconv.ParseFloat(v, 64)
+		case "st":
+			c.StreamType = v
+		case "su":
+			c.Startup = v == "" || v == "true"
+		case "tb":
+			c.TopBitrate, _ = strconv.Atoi(v)
+		case "cid":
+			c.ContentID = strings.Trim(v, `"`)
+		case "sid":
+			c.SessionID = strings.Trim(v, `"`)
+		}
+	}
+	return c, true
+}
+
+// splitCMCD splits CMCD payload by commas outside of quoted strings.
+func splitCMCD(s string) []string {
+	var kvs []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				kvs = append(kvs, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(kvs, s[start:])
+}
+
+// ContentQoE summarizes CMCD of requests for a content.
+type ContentQoE struct {
+	ContentID   string `json:"content_id"`
+	Requests    uint64 `json:"requests"`
+	Starvations uint64 `json:"starvations"`
+	bitrate     *QuantileSketch
+	buffer      *QuantileSketch
+	throughput  *QuantileSketch
+	sessions    *HyperLogLog
+}
+
+// StarvationRate returns the ratio of requests reporting buffer starvation.
+func (c *ContentQoE) StarvationRate() float64 {
+	return ratio(c.Starvations, c.Requests)
+}
+
+// Bitrate returns the q-quantile of encoded bitrate in kbps.
+func (c *ContentQoE) Bitrate(q float64) float64 {
+	return c.bitrate.Quantile(q)
+}
+
+// BufferLength returns the q-quantile of buffer length in milliseconds.
+func (c *ContentQoE) BufferLength(q float64) float64 {
+	return c.buffer.Quantile(q)
+}
+
+// Throughput returns the q-quantile of measured throughput in kbps.
+func (c *ContentQoE) Throughput(q float64) float64 {
+	return c.throughput.Quantile(q)
+}
+
+// Sessions returns the estimated number of distinct playback sessions.
+func (c *ContentQoE) Sessions() uint64 {
+	return c.sessions.Count()
+}
+
+// QoEReport aggregates RTMP plays per stream name and CMCD of Web
+// distribution logs per content ID.
+type QoEReport struct {
+	streams  map[string]*StreamQoE
+	contents map[string]*ContentQoE
+}
+
+// NewQoEReport returns an empty QoEReport.
+func NewQoEReport() *QoEReport {
+	return &QoEReport{streams: map[string]*StreamQoE{}, contents: map[string]*ContentQoE{}}
+}
+
+// AddPlay counts an RTMP play interval, usually emitted by RTMPSessionizer.
+func (r *QoEReport) AddPlay(p *RTMPPlay) {
+	s := r.streams[p.StreamName]
+	if s == nil {
+		s = &StreamQoE{StreamName: p.StreamName}
+		r.streams[p.StreamName] = s
+	}
+	s.Plays++
+	if p.Abnormal {
+		s.Abnormal++
+	}
+	s.WatchTime += p.WatchDuration()
+	s.Paused += p.Paused
+	s.Pauses += p.Pauses
+	s.Seeks += p.Seeks
+	s.Bytes += p.Bytes
+}
+
+// AddWeb counts CMCD in query string of l. It reports whether l has CMCD.
+// Requests without content ID are counted under the empty content ID.
+func (r *QoEReport) AddWeb(l *WebLog) bool {
+	cmcd, ok := ParseCMCD(l.QueryString)
+	if !ok {
+		return false
+	}
+	c := r.contents[cmcd.ContentID]
+	if c == nil {
+		h, _ := NewHyperLogLog(10)
+		c = &ContentQoE{
+			ContentID:  cmcd.ContentID,
+			bitrate:    NewQuantileSketch(0.01),
+			buffer:     NewQuantileSketch(0.01),
+			throughput: NewQuantileSketch(0.01),
+			sessions:   h,
+		}
+		r.contents[cmcd.ContentID] = c
+	}
+	c.Requests++
+	if cmcd.BufferStarvation {
+		c.Starvations++
+	}
+	if cmcd.Bitrate > 0 {
+		c.bitrate.Add(float64(cmcd.Bitrate))
+	}
+	if cmcd.BufferLength > 0 {
+		c.buffer.Add(float64(cmcd.BufferLength))
+	}
+	if cmcd.MeasuredThroughput > 0 {
+		c.throughput.Add(float64(cmcd.MeasuredThroughput))
+	}
+	if cmcd.SessionID != "" {
+		c.sessions.AddString(cmcd.SessionID)
+	}
+	return true
+}
+
+// Streams returns QoE per RTMP stream in descending order of plays.
+func (r *QoEReport) Streams() []*StreamQoE {
+	ss := make([]*StreamQoE, 0, len(r.streams))
+	for _, s := range r.streams {
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		if ss[i].Plays != ss[j].Plays {
+			return ss[i].Plays > ss[j].Plays
+		}
+		return ss[i].StreamName < ss[j].StreamName
+	})
+	return ss
+}
+
+// Contents returns QoE per content ID in descending order of requests.
+func (r *QoEReport) Contents() []*ContentQoE {
+	cs := make([]*ContentQoE, 0, len(r.contents))
+	for _, c := range r.contents {
+		cs = append(cs, c)
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].Requests != cs[j].Requests {
+			return cs[i].Requests > cs[j].Requests
+		}
+		return cs[i].ContentID < cs[j].ContentID
+	})
+	return cs
+}
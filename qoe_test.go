@@ -0,0 +1,105 @@
+package cflogparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCMCD(t *testing.T) {
+	want := &CMCD{
+		Bitrate:            3200,
+		BufferLength:       21300,
+		BufferStarvation:   true,
+		Duration:           4004,
+		MeasuredThroughput: 25400,
+		ObjectType:         "v",
+		TopBitrate:         6000,
+		ContentID:          "faec5fc2,ac30",
+		SessionID:          "6e2fb550-c457-11e9-bb97-0800200c9a66",
+	}
+	tests := []string{
+		`a=b&CMCD=bl%3D21300%2Cbr%3D3200%2Cbs%2Ccid%3D%22faec5fc2%2Cac30%22%2Cd%3D4004%2Cmtp%3D25400%2Cot%3Dv%2Csid%3D%226e2fb550-c457-11e9-bb97-0800200c9a66%22%2Ctb%3D6000`,
+		`CMCD=bl=21300,br=3200,bs,cid="faec5fc2,ac30",d=4004,mtp=25400,ot=v,sid="6e2fb550-c457-11e9-bb97-0800200c9a66",tb=6000&a=b`,
+	}
+	for _, in := range tests {
+		c, ok := ParseCMCD(in)
+		if !ok {
+			t.Errorf("CMCD is not found in %s", in)
+		} else if !reflect.DeepEqual(c, want) {
+			t.Errorf("got %+v, want %+v", c, want)
+		}
+	}
+	if _, ok := ParseCMCD("a=b&c=d"); ok {
+		t.Error("query without CMCD should not be parsed")
+	}
+	if _, ok := ParseCMCD("CMCD=br%3"); ok {
+		t.Error("malformed CMCD should not be parsed")
+	}
+
+	// CloudFront escapes '%' of the query string again in its log.
+	line := strings.Replace(webLine("00:00:00", "/v.mp4"), "curl/7.64.1\t-\t", "curl/7.64.1\tCMCD=bl%253D21300%252Cbr%253D3200%252Csid%253D%2522a%252Bb%2522\t", 1)
+	l, err := ParseLineWeb(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := ParseCMCD(l.QueryString)
+	if !ok || c.BufferLength != 21300 || c.Bitrate != 3200 || c.SessionID != "a+b" {
+		t.Errorf("got %+v from %s", c, l.QueryString)
+	}
+}
+
+func TestQoEReport(t *testing.T) {
+	r := NewQoEReport()
+	z := NewRTMPSessionizer(nil, r.AddPlay)
+	for _, l := range readRTMPLogs(t, "testdata/sample-rtmp.log") {
+		z.Add(l)
+	}
+	z.Flush()
+	r.AddPlay(&RTMPPlay{StreamName: "myvideo", Start: time.Unix(0, 0), End: time.Unix(60, 0), Paused: 10 * time.Second, Pauses: 2, Seeks: 1, Bytes: 1000000, Abnormal: true})
+
+	ss := r.Streams()
+	if len(ss) != 2 {
+		t.Fatalf("got %d streams, want 2", len(ss))
+	}
+	s := ss[0]
+	if s.StreamName != "myvideo" || s.Plays != 2 || s.Abnormal != 1 || s.WatchTime != 193*time.Second ||
+		s.AverageWatchTime() != 96500*time.Millisecond || s.PausesPerPlay() != 1 || s.SeeksPerPlay() != 0.5 {
+		t.Errorf("unexpected stream: %+v", s)
+	}
+	if b := ss[1].Bitrate(); b != 421038290*8/157.0 {
+		t.Errorf("got bitrate %f", b)
+	}
+
+	queries := []string{
+		"CMCD=br%3D3200%2Cbl%3D20000%2Ccid%3D%22movie%22%2Csid%3D%22s1%22",
+		"CMCD=br%3D3200%2Cbl%3D10000%2Cbs%2Ccid%3D%22movie%22%2Csid%3D%22s1%22",
+		"CMCD=br%3D800%2Cbl%3D500%2Ccid%3D%22movie%22%2Csid%3D%22s2%22",
+		"CMCD=br%3D800%2Ccid%3D%22trailer%22%2Csid%3D%22s3%22",
+		"foo=bar",
+	}
+	n := 0
+	for _, q := range queries {
+		if r.AddWeb(&WebLog{QueryString: q}) {
+			n++
+		}
+	}
+	if n != 4 {
+		t.Errorf("got %d requests with CMCD, want 4", n)
+	}
+	cs := r.Contents()
+	if len(cs) != 2 {
+		t.Fatalf("got %d contents, want 2", len(cs))
+	}
+	c := cs[0]
+	if c.ContentID != "movie" || c.Requests != 3 || c.Starvations != 1 || c.Sessions() != 2 {
+		t.Errorf("unexpected content: %+v", c)
+	}
+	if b := c.Bitrate(1); b < 3168 || b > 3232 {
+		t.Errorf("got max bitrate %f, want 3200", b)
+	}
+	if b := c.BufferLength(0); b < 495 || b > 505 {
+		t.Errorf("got min buffer length %f, want 500", b)
+	}
+}
@@ -0,0 +1,128 @@
+package cflogparser
+
+import (
+	"math"
+	"sort"
+)
+
+// QuantileSketch estimates quantiles of non-negative values, such as
+// TimeTaken, in bounded memory. Values are counted in logarithmic buckets,
+// so that any quantile is estimated within relative error of accuracy.
+// Sketches with the same accuracy can be merged.
+type QuantileSketch struct {
+	gamma   float64
+	logG    float64
+	zero    uint64
+	count   uint64
+	buckets map[int]uint64
+}
+
+// Values smaller than this are counted as zero.
+const sketchMinValue = 1e-9
+
+// NewQuantileSketch returns an empty QuantileSketch with the given relative
+// accuracy, e.g. 0.01 for 1%.
+func NewQuantileSketch(accuracy float64) *QuantileSketch {
+	g := (1 + accuracy) / (1 - accuracy)
+	return &QuantileSketch{gamma: g, logG: math.Log(g), buckets: map[int]uint64{}}
+}
+
+// Add adds v to s.
+func (s *QuantileSketch) Add(v float64) {
+	s.count++
+	if v < sketchMinValue {
+		s.zero++
+		return
+	}
+	s.buckets[int(math.Ceil(math.Log(v)/s.logG))]++
+}
+
+// Count returns the number of values added to s.
+func (s *QuantileSketch) Count() uint64 {
+	return s.count
+}
+
+// Quantile returns the estimated q-quantile (0 <= q <= 1) of values.
+// It returns 0 if s is empty.
+func (s *QuantileSketch) Quantile(q float64) float64 {
+	k, zero := s.quantileBucket(q)
+	if zero {
+		return 0
+	}
+	return s.value(k)
+}
+
+// quantileBucket returns the bucket of the q-quantile, or zero is true if
+// it is counted as zero.
+func (s *QuantileSketch) quantileBucket(q float64) (k int, zero bool) {
+	if s.count == 0 {
+		return 0, true
+	}
+	rank := uint64(q * float64(s.count-1))
+	if rank < s.zero {
+		return 0, true
+	}
+	n := s.zero
+	ks := s.keys()
+	for _, k := range ks {
+		n += s.buckets[k]
+		if n > rank {
+			return k, false
+		}
+	}
+	return ks[len(ks)-1], false
+}
+
+// countUpTo returns the number of values in bucket k or below, or those
+// counted as zero if zero is true.
+func (s *QuantileSketch) countUpTo(k int, zero bool) uint64 {
+	n := s.zero
+	if zero {
+		return n
+	}
+	for bk, c := range s.buckets {
+		if bk <= k {
+			n += c
+		}
+	}
+	return n
+}
+
+// value returns the representative value of bucket k.
+func (s *QuantileSketch) value(k int) float64 {
+	return 2 * math.Pow(s.gamma, float64(k)) / (s.gamma + 1)
+}
+
+func (s *QuantileSketch) keys() []int {
+	ks := make([]int, 0, len(s.buckets))
+	for k := range s.buckets {
+		ks = append(ks, k)
+	}
+	sort.Ints(ks)
+	return ks
+}
+
+// Merge merges o into s. Both must have the same accuracy.
+func (s *QuantileSketch) Merge(o *QuantileSketch) {
+	s.zero += o.zero
+	s.count += o.count
+	for k, n := range o.buckets {
+		s.buckets[k] += n
+	}
+}
+
+// QuantileTest returns two-sided p-value of the hypothesis that a and b
+// have the same q-quantile, with Mood's median test generalized to q: the
+// shares of values at or below the q-quantile of a and b combined are
+// compared with two-proportion z-test, so that a change in the tail
+// doesn't make the median differ. Both must have the same accuracy.
+func QuantileTest(a, b *QuantileSketch, q float64) float64 {
+	if a.count == 0 || b.count == 0 {
+		return 1
+	}
+	pooled := &QuantileSketch{gamma: a.gamma, logG: a.logG, buckets: map[int]uint64{}}
+	pooled.Merge(a)
+	pooled.Merge(b)
+	k, zero := pooled.quantileBucket(q)
+	return proportionTest(a.countUpTo(k, zero), a.count, b.countUpTo(k, zero), b.count)
+}
@@ -0,0 +1,63 @@
+package cflogparser
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestQuantileSketch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	vs := make([]float64, 100000)
+	a, b := NewQuantileSketch(0.01), NewQuantileSketch(0.01)
+	for i := range vs {
+		vs[i] = r.ExpFloat64() * 0.2
+		if i%10 == 0 {
+			vs[i] = 0
+		}
+		if i%2 == 0 {
+			a.Add(vs[i])
+		} else {
+			b.Add(vs[i])
+		}
+	}
+	a.Merge(b)
+	if a.Count() != uint64(len(vs)) {
+		t.Errorf("got count %d, want %d", a.Count(), len(vs))
+	}
+	sort.Float64s(vs)
+	for _, q := range []float64{0.05, 0.5, 0.9, 0.99, 1} {
+		want := vs[int(q*float64(len(vs)-1))]
+		got := a.Quantile(q)
+		if want == 0 && got != 0 || math.Abs(got-want) > 0.011*want {
+			t.Errorf("q=%g: got %g, want %g", q, got, want)
+		}
+	}
+	if NewQuantileSketch(0.01).Quantile(0.5) != 0 {
+		t.Error("quantile of empty sketch should be 0")
+	}
+}
+
+func TestQuantileTest(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	a, b := NewQuantileSketch(0.01), NewQuantileSketch(0.01)
+	for i := 0; i < 5000; i++ {
+		v := r.Float64()
+		a.Add(v)
+		if v > 0.9 {
+			// Only the tail gets slower, spreading over many buckets.
+			v = 1 + (v-0.9)*1000
+		}
+		b.Add(v)
+	}
+	if p := QuantileTest(a, b, 0.5); p < 0.5 {
+		t.Errorf("unchanged median should not differ significantly: p=%g", p)
+	}
+	if p := QuantileTest(a, b, 0.99); p > 1e-6 {
+		t.Errorf("changed tail should differ significantly: p=%g", p)
+	}
+	if p := QuantileTest(a, NewQuantileSketch(0.01), 0.5); p != 1 {
+		t.Errorf("got p=%g for empty sketch, want 1", p)
+	}
+}
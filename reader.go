@@ -0,0 +1,194 @@
+package cflogparser
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ParseError is returned by Reader for a line that can't be parsed.
+// Reading can be continued after it.
+type ParseError struct {
+	Name string // Name of input, if known
+	Line int    // Line number, starting from 1
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("%s:%d: %s", e.Name, e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Open opens a log file. If it is gzipped, as CloudFront delivers log files,
+// it is decompressed transparently.
+func Open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return &readCloser{zr, f}, nil
+	}
+	return &readCloser{br, f}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	f *os.File
+}
+
+func (r *readCloser) Close() error {
+	if zr, ok := r.Reader.(*gzip.Reader); ok {
+		zr.Close()
+	}
+	return r.f.Close()
+}
+
+// Reader reads records of Web or RTMP distribution log line by line.
+// Records are returned as *WebLog or *RTMPLog. Comment lines are skipped,
+// and those before the first record are kept as header.
+type Reader struct {
+	Name   string
+	rtmp   bool
+	s      *bufio.Scanner
+	c      io.Closer
+	lineNo int
+	line   string
+	header []string
+}
+
+// NewReader returns a Reader reading r. If rtmp is true, lines are parsed
+// as RTMP distribution log, otherwise as Web distribution log.
+func NewReader(r io.Reader, rtmp bool) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	rd := &Reader{rtmp: rtmp, s: s}
+	if c, ok := r.(io.Closer); ok {
+		rd.c = c
+	}
+	return rd
+}
+
+// OpenReader opens a log file by Open and returns a Reader of it.
+func OpenReader(name string, rtmp bool) (*Reader, error) {
+	f, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r := NewReader(f, rtmp)
+	r.Name = name
+	return r, nil
+}
+
+// Read returns the next record. It returns io.EOF at the end of input, and
+// *ParseError for a malformed line.
+func (r *Reader) Read() (interface{}, error) {
+	for r.s.Scan() {
+		r.lineNo++
+		r.line = r.s.Text()
+		if strings.HasPrefix(r.line, "#") {
+			if r.lineNo == len(r.header)+1 {
+				r.header = append(r.header, r.line)
+			}
+			continue
+		}
+		var rec interface{}
+		var err error
+		if r.rtmp {
+			rec, err = ParseLineRTMP(r.line)
+		} else {
+			rec, err = ParseLineWeb(r.line)
+		}
+		if err != nil {
+			return nil, &ParseError{Name: r.Name, Line: r.lineNo, Err: err}
+		}
+		return rec, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Line returns the raw line of the record last read.
+func (r *Reader) Line() string {
+	return r.line
+}
+
+// Header returns comment lines at the beginning of input, such as
+// "#Version: 1.0" and "#Fields: ...".
+func (r *Reader) Header() []string {
+	return r.header
+}
+
+// Close closes the underlying reader if it is an io.Closer.
+func (r *Reader) Close() error {
+	if r.c != nil {
+		return r.c.Close()
+	}
+	return nil
+}
+
+// RecordTime returns Time of rec, which must be *WebLog or *RTMPLog.
+func RecordTime(rec interface{}) time.Time {
+	switch l := rec.(type) {
+	case *WebLog:
+		return l.Time
+	case *RTMPLog:
+		return l.Time
+	}
+	return time.Time{}
+}
+
+// lineTime parses only date and time fields of a line. It is much faster
+// than parsing the whole line.
+func lineTime(line string) (time.Time, error) {
+	i := strings.IndexByte(line, '\t')
+	if i < 0 {
+		return time.Time{}, errors.New("Insufficient number of fields: " + line)
+	}
+	j := strings.IndexByte(line[i+1:], '\t')
+	if j < 0 {
+		return time.Time{}, errors.New("Insufficient number of fields: " + line)
+	}
+	return time.Parse("2006-01-02 15:04:05", line[:i]+" "+line[i+1:i+1+j])
+}
+
+// IsSorted reports whether lines of log in r are in chronological order.
+// Comment lines and lines with invalid time are ignored.
+func IsSorted(r io.Reader) (bool, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	var last time.Time
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+		t, err := lineTime(s.Text())
+		if err != nil {
+			continue
+		}
+		if t.Before(last) {
+			return false, nil
+		}
+		last = t
+	}
+	return true, s.Err()
+}
@@ -0,0 +1,95 @@
+package cflogparser
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenReader(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/sample-rtmp.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	gz := filepath.Join(dir, "sample-rtmp.log.gz")
+	f, err := os.Create(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write(src)
+	zw.Close()
+	f.Close()
+
+	for _, name := range []string{"testdata/sample-rtmp.log", gz} {
+		r, err := OpenReader(name, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := rec.(*RTMPLog); !ok {
+				t.Errorf("got %T, want *RTMPLog", rec)
+			}
+			if !strings.HasPrefix(r.Line(), "2010-03-12\t") {
+				t.Errorf("unexpected line: %s", r.Line())
+			}
+			n++
+		}
+		if n != 6 {
+			t.Errorf("%s: got %d records, want 6", name, n)
+		}
+		if h := r.Header(); len(h) != 2 || h[0] != "#Version: 1.0" {
+			t.Errorf("%s: unexpected header: %q", name, h)
+		}
+		if err := r.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReaderParseError(t *testing.T) {
+	in := "#Version: 1.0\n" +
+		"2014-05-23\t01:13:11\tFRA2\tbroken\n" +
+		"2014-05-23	01:13:11	FRA2	182	192.0.2.10	GET	d111111abcdef8.cloudfront.net	/view/my/file.html	200	www.displaymyfiles.com	Mozilla/4.0%20(compatible;%20MSIE%205.0b1;%20Mac_PowerPC)	-	zip=98101	RefreshHit	MRVMF7KydIvxMWfJIglgwHQwZsbG2IhRJ07sn9AkKUFSHS9EXAMPLE==	d111111abcdef8.cloudfront.net	http	-	0.001	-	-	-	RefreshHit	HTTP/1.1	Processed	1\n"
+	r := NewReader(strings.NewReader(in), false)
+	_, err := r.Read()
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 2 {
+		t.Fatalf("got %v, want ParseError at line 2", err)
+	}
+	rec, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := rec.(*WebLog); l.URI != "/view/my/file.html" {
+		t.Errorf("unexpected record: %+v", l)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
+
+func TestIsSorted(t *testing.T) {
+	sorted := "#Version: 1.0\n2019-10-01\t00:00:00\tx\n2019-10-01\t00:00:00\ty\n2019-10-01\t00:00:01\tz\n"
+	unsorted := "2019-10-01\t00:00:01\tx\n2019-10-01\t00:00:00\ty\n"
+	if ok, err := IsSorted(strings.NewReader(sorted)); !ok || err != nil {
+		t.Errorf("got %v %v, want true", ok, err)
+	}
+	if ok, err := IsSorted(strings.NewReader(unsorted)); ok || err != nil {
+		t.Errorf("got %v %v, want false", ok, err)
+	}
+}
@@ -43,15 +43,15 @@ func ParseLineRTMP(line string) (l *RTMPLog, err error) {
 		}
 	}()
 
-	l.Time, err = time.Parse("2006-01-02 15:04:05", vals[0]+" "+vals[1])
+	l.Time, err = ParseTimeField(vals[0], vals[1])
 	if err != nil {
 		panic(err)
 	}
 
 	l.Location = parseString(vals[2])
 
-	l.RequestIP = net.ParseIP(vals[3])
-	if l.RequestIP == nil {
+	l.RequestIP, err = ParseIPField(vals[3])
+	if err != nil {
 		panic(err)
 	}
 
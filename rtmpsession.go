@@ -0,0 +1,255 @@
+package cflogparser
+
+import (
+	"sort"
+	"time"
+)
+
+// Reasons of abnormal termination of RTMPConnection and RTMPPlay.
+const (
+	ReasonNoConnect    = "no connect"
+	ReasonNoDisconnect = "no disconnect"
+	ReasonReconnect    = "reconnected without disconnect"
+	ReasonNoPlay       = "no play"
+	ReasonNoStop       = "no stop"
+	ReasonReplay       = "played again without stop"
+	ReasonStatus       = "error status"
+)
+
+// RTMPConnection is a connection of an RTMP client from connect to
+// disconnect. Bytes is the number of bytes transferred during the
+// connection, computed from the cumulative sc-bytes counter.
+type RTMPConnection struct {
+	ClientID  string    `json:"client_id"`
+	RequestIP string    `json:"request_ip"`
+	Location  string    `json:"location"`
+	URI       string    `json:"uri"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Bytes     uint64    `json:"bytes"`
+	Plays     int       `json:"plays"`
+	Abnormal  bool      `json:"abnormal"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Duration returns the time between connect and disconnect.
+func (c *RTMPConnection) Duration() time.Duration {
+	return c.End.Sub(c.Start)
+}
+
+// RTMPPlay is an interval of a stream played from play to stop.
+type RTMPPlay struct {
+	ClientID      string        `json:"client_id"`
+	StreamID      uint32        `json:"stream_id"`
+	StreamName    string        `json:"stream_name"`
+	StreamFileExt string        `json:"stream_file_ext"`
+	Start         time.Time     `json:"start"`
+	End           time.Time     `json:"end"`
+	Bytes         uint64        `json:"bytes"`
+	Paused        time.Duration `json:"paused"`
+	Pauses        int           `json:"pauses"`
+	Seeks         int           `json:"seeks"`
+	Abnormal      bool          `json:"abnormal"`
+	Reason        string        `json:"reason,omitempty"`
+}
+
+// Duration returns the time between play and stop.
+func (p *RTMPPlay) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
+
+// WatchDuration returns Duration excluding paused time.
+func (p *RTMPPlay) WatchDuration() time.Duration {
+	return p.Duration() - p.Paused
+}
+
+type rtmpState struct {
+	conn       *RTMPConnection
+	startBytes uint64
+	lastBytes  uint64
+	plays      map[uint32]*rtmpPlayState
+}
+
+type rtmpPlayState struct {
+	play       *RTMPPlay
+	startBytes uint64
+	pausedAt   time.Time
+}
+
+// RTMPSessionizer is a state machine that pairs RTMP events into
+// connections and play intervals per client ID. It consumes RTMPLogs in
+// chronological order, and passes connections and plays to the callback
+// functions when they end. Missing events, e.g. disconnect without connect,
+// are tolerated and reported as abnormal termination.
+type RTMPSessionizer struct {
+	onConn  func(*RTMPConnection)
+	onPlay  func(*RTMPPlay)
+	clients map[string]*rtmpState
+}
+
+// NewRTMPSessionizer returns an RTMPSessionizer. Either callback may be nil.
+func NewRTMPSessionizer(onConn func(*RTMPConnection), onPlay func(*RTMPPlay)) *RTMPSessionizer {
+	if onConn == nil {
+		onConn = func(*RTMPConnection) {}
+	}
+	if onPlay == nil {
+		onPlay = func(*RTMPPlay) {}
+	}
+	return &RTMPSessionizer{onConn: onConn, onPlay: onPlay, clients: map[string]*rtmpState{}}
+}
+
+// Add feeds l to the state machine.
+func (z *RTMPSessionizer) Add(l *RTMPLog) {
+	st := z.clients[l.ClientID]
+	if l.EventType == "connect" {
+		if st != nil {
+			z.closeConn(st, st.conn.End, ReasonReconnect)
+		}
+		z.clients[l.ClientID] = z.newState(l, true)
+		return
+	}
+	if st == nil {
+		st = z.newState(l, false)
+		z.clients[l.ClientID] = st
+	}
+	st.conn.End = l.Time
+	st.lastBytes = l.Bytes
+	if l.Status != "OK" && !st.conn.Abnormal {
+		st.conn.Abnormal = true
+		st.conn.Reason = ReasonStatus + ": " + l.Status
+	}
+
+	ps := st.plays[l.StreamID]
+	switch l.EventType {
+	case "play":
+		if ps != nil {
+			z.closePlay(st, ps, l, ReasonReplay)
+		}
+		st.conn.Plays++
+		st.plays[l.StreamID] = &rtmpPlayState{
+			play: &RTMPPlay{
+				ClientID:      l.ClientID,
+				StreamID:      l.StreamID,
+				StreamName:    l.StreamName,
+				StreamFileExt: l.StreamFileExt,
+				Start:         l.Time,
+			},
+			startBytes: l.Bytes,
+		}
+	case "stop":
+		if ps == nil {
+			// The play event is missing; the interval is unknown.
+			z.onPlay(&RTMPPlay{
+				ClientID:      l.ClientID,
+				StreamID:      l.StreamID,
+				StreamName:    l.StreamName,
+				StreamFileExt: l.StreamFileExt,
+				Start:         l.Time,
+				End:           l.Time,
+				Abnormal:      true,
+				Reason:        ReasonNoPlay,
+			})
+			return
+		}
+		z.closePlay(st, ps, l, "")
+	case "pause":
+		if ps != nil && ps.pausedAt.IsZero() {
+			ps.play.Pauses++
+			ps.pausedAt = l.Time
+		}
+	case "unpause":
+		if ps != nil && !ps.pausedAt.IsZero() {
+			ps.play.Paused += l.Time.Sub(ps.pausedAt)
+			ps.pausedAt = time.Time{}
+		}
+	case "seek":
+		if ps != nil {
+			ps.play.Seeks++
+		}
+	case "disconnect":
+		z.closeConn(st, l.Time, "")
+	}
+}
+
+func (z *RTMPSessionizer) newState(l *RTMPLog, connected bool) *rtmpState {
+	st := &rtmpState{
+		conn: &RTMPConnection{
+			ClientID:  l.ClientID,
+			RequestIP: l.RequestIP.String(),
+			Location:  l.Location,
+			URI:       l.URI,
+			Start:     l.Time,
+			End:       l.Time,
+		},
+		lastBytes: l.Bytes,
+		plays:     map[uint32]*rtmpPlayState{},
+	}
+	if !connected {
+		// Bytes before the first event seen are unknown.
+		st.startBytes = l.Bytes
+		st.conn.Abnormal = true
+		st.conn.Reason = ReasonNoConnect
+	}
+	return st
+}
+
+// closePlay ends play ps at the time of l. Non-empty reason means abnormal
+// termination.
+func (z *RTMPSessionizer) closePlay(st *rtmpState, ps *rtmpPlayState, l *RTMPLog, reason string) {
+	p := ps.play
+	p.End = l.Time
+	if l.Bytes >= ps.startBytes {
+		p.Bytes = l.Bytes - ps.startBytes
+	}
+	if !ps.pausedAt.IsZero() {
+		p.Paused += l.Time.Sub(ps.pausedAt)
+	}
+	if reason != "" {
+		p.Abnormal = true
+		p.Reason = reason
+	} else if l.Status != "OK" {
+		p.Abnormal = true
+		p.Reason = ReasonStatus + ": " + l.Status
+	}
+	delete(st.plays, p.StreamID)
+	z.onPlay(p)
+}
+
+// closeConn ends connection st at t, closing its open plays as abnormal.
+// Non-empty reason means abnormal termination.
+func (z *RTMPSessionizer) closeConn(st *rtmpState, t time.Time, reason string) {
+	c := st.conn
+	c.End = t
+	if st.lastBytes >= st.startBytes {
+		c.Bytes = st.lastBytes - st.startBytes
+	}
+	end := &RTMPLog{Time: t, Bytes: st.lastBytes, Status: "OK"}
+	ids := make([]int, 0, len(st.plays))
+	for id := range st.plays {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		z.closePlay(st, st.plays[uint32(id)], end, ReasonNoStop)
+	}
+	if reason != "" && !c.Abnormal {
+		c.Abnormal = true
+		c.Reason = reason
+	}
+	delete(z.clients, c.ClientID)
+	z.onConn(c)
+}
+
+// Flush closes all open connections and plays as abnormal. Call it at the
+// end of input.
+func (z *RTMPSessionizer) Flush() {
+	ids := make([]string, 0, len(z.clients))
+	for id := range z.clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		st := z.clients[id]
+		z.closeConn(st, st.conn.End, ReasonNoDisconnect)
+	}
+}
@@ -0,0 +1,120 @@
+package cflogparser
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readRTMPLogs(t *testing.T, file string) []*RTMPLog {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var ls []*RTMPLog
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+		l, err := ParseLineRTMP(s.Text())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ls = append(ls, l)
+	}
+	return ls
+}
+
+func TestRTMPSessionizerSample(t *testing.T) {
+	var conns []*RTMPConnection
+	var plays []*RTMPPlay
+	z := NewRTMPSessionizer(
+		func(c *RTMPConnection) { conns = append(conns, c) },
+		func(p *RTMPPlay) { plays = append(plays, p) },
+	)
+	for _, l := range readRTMPLogs(t, "testdata/sample-rtmp.log") {
+		z.Add(l)
+	}
+	z.Flush()
+
+	if len(conns) != 1 {
+		t.Fatalf("got %d connections, want 1", len(conns))
+	}
+	c := conns[0]
+	if c.ClientID != "bfd8a98bee0840d9b871b7f6ade9908f" || c.Bytes != 429824092 || c.Plays != 2 ||
+		c.Duration() != 8*time.Minute+24*time.Second || c.Abnormal {
+		t.Errorf("unexpected connection: %+v", c)
+	}
+
+	if len(plays) != 2 {
+		t.Fatalf("got %d plays, want 2", len(plays))
+	}
+	if p := plays[0]; p.StreamID != 1 || p.StreamName != "myvideo" || p.Bytes != 320000 ||
+		p.Duration() != 2*time.Minute+23*time.Second || p.Abnormal {
+		t.Errorf("unexpected play: %+v", p)
+	}
+	if p := plays[1]; p.StreamID != 2 || p.StreamName != "dir/favs/myothervideo" || p.Bytes != 421038290 ||
+		p.Duration() != 2*time.Minute+37*time.Second || p.Abnormal {
+		t.Errorf("unexpected play: %+v", p)
+	}
+}
+
+func TestRTMPSessionizerAbnormal(t *testing.T) {
+	base := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	var conns []*RTMPConnection
+	var plays []*RTMPPlay
+	z := NewRTMPSessionizer(
+		func(c *RTMPConnection) { conns = append(conns, c) },
+		func(p *RTMPPlay) { plays = append(plays, p) },
+	)
+	add := func(sec int, client, event string, bytes uint64, sid uint32) {
+		z.Add(&RTMPLog{Time: base.Add(time.Duration(sec) * time.Second), ClientID: client, EventType: event, Bytes: bytes, Status: "OK", StreamID: sid})
+	}
+	// Client a plays with pause and seek, but disconnect is missing.
+	add(0, "a", "connect", 100, 0)
+	add(1, "a", "play", 200, 1)
+	add(10, "a", "pause", 1000, 1)
+	add(20, "a", "unpause", 1000, 1)
+	add(25, "a", "seek", 1500, 1)
+	add(40, "a", "stop", 3000, 1)
+	add(41, "a", "play", 3100, 2)
+	// Client b is seen from the middle, and disconnects without stop.
+	add(5, "b", "play", 5000, 1)
+	add(15, "b", "disconnect", 6000, 0)
+	// Client a reconnects.
+	add(60, "a", "connect", 50, 0)
+	add(70, "a", "disconnect", 80, 0)
+	z.Flush()
+
+	if len(plays) != 3 {
+		t.Fatalf("got %d plays, want 3", len(plays))
+	}
+	if p := plays[0]; p.ClientID != "a" || p.Bytes != 2800 || p.Paused != 10*time.Second || p.WatchDuration() != 29*time.Second ||
+		p.Pauses != 1 || p.Seeks != 1 || p.Abnormal {
+		t.Errorf("unexpected play: %+v", p)
+	}
+	if p := plays[1]; p.ClientID != "b" || p.Bytes != 1000 || !p.Abnormal || p.Reason != ReasonNoStop {
+		t.Errorf("unexpected play: %+v", p)
+	}
+	if p := plays[2]; p.ClientID != "a" || p.StreamID != 2 || !p.Abnormal || p.Reason != ReasonNoStop || !p.End.Equal(base.Add(41*time.Second)) {
+		t.Errorf("unexpected play: %+v", p)
+	}
+
+	if len(conns) != 3 {
+		t.Fatalf("got %d connections, want 3", len(conns))
+	}
+	if c := conns[0]; c.ClientID != "b" || !c.Abnormal || c.Reason != ReasonNoConnect || c.Bytes != 1000 {
+		t.Errorf("unexpected connection: %+v", c)
+	}
+	if c := conns[1]; c.ClientID != "a" || !c.Abnormal || c.Reason != ReasonReconnect || c.Bytes != 3100 || c.Plays != 2 {
+		t.Errorf("unexpected connection: %+v", c)
+	}
+	if c := conns[2]; c.ClientID != "a" || c.Abnormal || c.Bytes != 80 {
+		t.Errorf("unexpected connection: %+v", c)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser/arrow"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/mattn/go-forlines"
+)
+
+// Convert log into Apache Arrow IPC format.
+//
+//	cflog2arrow access.log ... | consumer   # streaming format to STDOUT
+//	cflog2arrow -o access.arrow access.log  # file format
+func main() {
+	var optRTMP bool
+	var optOut string
+	var optBatch int
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.StringVar(&optOut, "o", "", "Write into this file in IPC file format (default STDOUT in streaming format)")
+	flag.IntVar(&optBatch, "batch", 65536, "Number of rows in a record batch")
+	flag.Parse()
+
+	mem := memory.NewGoAllocator()
+	var w arrow.RecordWriter
+	if optOut == "" {
+		w = arrow.NewStreamWriter(os.Stdout, mem, optRTMP)
+	} else {
+		f, err := os.Create(optOut)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		if w, err = arrow.NewFileWriter(f, mem, optRTMP); err != nil {
+			fail(err)
+		}
+	}
+	b := arrow.NewBatcher(mem, optRTMP, optBatch, func(rec array.Record) error {
+		return w.Write(rec)
+	})
+	defer b.Release()
+
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			if err := b.AppendLine(line); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if err := b.Flush(); err != nil {
+		fail(err)
+	}
+	if err := w.Close(); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Convert log into Avro or Protocol Buffers records framed by their length,
+// to feed a Kafka producer.
+//
+//	cflog2bin -encoding protobuf -schema-id 7 access.log ... > access.bin
+//	cflog2bin -encoding avro -print-schema > weblog.avsc
+func main() {
+	var optRTMP, optPrintSchema bool
+	var optEncoding string
+	var optSchemaID int
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.StringVar(&optEncoding, "encoding", "avro", "Encoding: "+strings.Join(cflogparser.Encodings, ", "))
+	flag.IntVar(&optSchemaID, "schema-id", 0, "Prefix each record with the header of Confluent Schema Registry with this schema ID")
+	flag.BoolVar(&optPrintSchema, "print-schema", false, "Print the schema of records and exit")
+	flag.Parse()
+
+	if optPrintSchema {
+		switch {
+		case optEncoding == "protobuf":
+			fmt.Println(cflogparser.ProtoSchema)
+		case optRTMP:
+			fmt.Println(cflogparser.RTMPLogAvroSchema)
+		default:
+			fmt.Println(cflogparser.WebLogAvroSchema)
+		}
+		return
+	}
+
+	w, err := cflogparser.NewBinaryWriter(os.Stdout, optEncoding)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	w.SchemaID = optSchemaID
+	defer w.Flush()
+
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			var l interface{}
+			var err error
+			if optRTMP {
+				l, err = cflogparser.ParseLineRTMP(line)
+			} else {
+				l, err = cflogparser.ParseLineWeb(line)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			if err := w.Write(l); err != nil {
+				// Output is broken, e.g. closed pipe, so no reason to continue.
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
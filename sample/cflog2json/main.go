@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"bufio"
 	"flag"
 	"fmt"
 	"os"
@@ -14,9 +14,40 @@ import (
 
 func main() {
 	var optRTMP bool
+	var opts cflogparser.JSONOptions
+	var optDedup string
+	var optBloomN int
+	var optBloomFP float64
 	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.BoolVar(&opts.EpochMillis, "epoch-ms", false, "Output time in milliseconds since the Unix epoch")
+	flag.BoolVar(&opts.OmitEmpty, "omit-empty", false, "Omit empty strings and zero numbers")
+	flag.BoolVar(&opts.CamelCase, "camel", false, "Output keys in camelCase")
+	flag.StringVar(&optDedup, "dedup", "", `Drop duplicate records: "exact" or "bloom"`)
+	flag.IntVar(&optBloomN, "bloom-n", 10000000, "Expected number of records for -dedup=bloom")
+	flag.Float64Var(&optBloomFP, "bloom-fp", 0.0001, "False positive rate for -dedup=bloom")
 	flag.Parse()
 
+	var dedup *cflogparser.Dedup
+	switch optDedup {
+	case "":
+	case "exact":
+		dedup = cflogparser.NewDedup(cflogparser.NewExactSet())
+	case "bloom":
+		f, err := cflogparser.NewBloomFilter(optBloomN, optBloomFP)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		dedup = cflogparser.NewDedup(f)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown dedup mode: %s\n", optDedup)
+		os.Exit(1)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	var buf []byte
+
 	rd := argvreader.NewReader(flag.Args())
 	for {
 		err := forlines.Do(rd, func(line string) error {
@@ -36,17 +67,19 @@ func main() {
 				fmt.Fprintln(os.Stderr, err)
 				return nil
 			}
-			b, err := json.Marshal(l)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
+			if dedup != nil && dedup.Duplicate(l) {
 				return nil
 			}
-			_, err = os.Stdout.Write(b)
-			if err != nil {
+			switch l := l.(type) {
+			case *cflogparser.WebLog:
+				buf = l.AppendJSON(buf[:0], opts)
+			case *cflogparser.RTMPLog:
+				buf = l.AppendJSON(buf[:0], opts)
+			}
+			buf = append(buf, '\n')
+			if _, err := out.Write(buf); err != nil {
 				fmt.Fprintln(os.Stderr, err)
-				return nil
 			}
-			os.Stdout.Write([]byte{'\n'})
 			return nil
 		})
 		if err == nil {
@@ -55,4 +88,7 @@ func main() {
 		// Here, err is not EOF. So, report error to STDERR, and then, continue reading.
 		fmt.Fprintln(os.Stderr, err)
 	}
+	if dedup != nil {
+		fmt.Fprintf(os.Stderr, "%d duplicate records dropped\n", dedup.Dropped())
+	}
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/cflogparser/otlp"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Export log of web distribution as OpenTelemetry logs.
+//
+//	cflog2otlp -endpoint http://localhost:4318/v1/logs access.log ...
+//	cflog2otlp -o logs.jsonl -resource deployment.environment=prod access.log ...
+//
+// Without -endpoint, requests are written to -o, or STDOUT.
+func main() {
+	var optEndpoint, optEncoding, optOut string
+	var optBatch int
+	var optHeader, optResource stringList
+	flag.StringVar(&optEndpoint, "endpoint", "", "URL of OTLP/HTTP endpoint for logs")
+	flag.StringVar(&optEncoding, "encoding", "json", "Encoding: "+strings.Join(otlp.Encodings, ", "))
+	flag.StringVar(&optOut, "o", "", "Output file without -endpoint (default STDOUT)")
+	flag.IntVar(&optBatch, "batch", 1000, "Number of log records in a request")
+	flag.Var(&optHeader, "H", `HTTP header as "Name: value" (can be repeated)`)
+	flag.Var(&optResource, "resource", "Resource attribute as key=value, added to the default ones (can be repeated)")
+	flag.Parse()
+
+	opts := otlp.Options{Encoding: optEncoding, BatchSize: optBatch, Header: http.Header{}}
+	opts.Resource = append(opts.Resource, otlp.DefaultResource...)
+	for _, kv := range optResource {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			fail(fmt.Errorf("Invalid resource attribute: %s", kv))
+		}
+		opts.Resource = append(opts.Resource, otlp.Attribute{Key: kv[:i], Value: kv[i+1:]})
+	}
+	for _, h := range optHeader {
+		i := strings.IndexByte(h, ':')
+		if i < 0 {
+			fail(fmt.Errorf("Invalid header: %s", h))
+		}
+		opts.Header.Add(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+	}
+
+	var e *otlp.Exporter
+	var err error
+	if optEndpoint != "" {
+		e, err = otlp.NewHTTPExporter(optEndpoint, opts)
+	} else {
+		f := os.Stdout
+		if optOut != "" {
+			if f, err = os.Create(optOut); err != nil {
+				fail(err)
+			}
+			defer f.Close()
+		}
+		out := bufio.NewWriter(f)
+		defer out.Flush()
+		e, err = otlp.NewFileExporter(out, opts)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			l, err := cflogparser.ParseLineWeb(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			if err := e.Write(l); err != nil {
+				if _, ok := err.(*otlp.PartialSuccessError); ok {
+					fmt.Fprintln(os.Stderr, err)
+					return nil
+				}
+				fail(err)
+			}
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if err := e.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if optEndpoint != "" {
+		fmt.Fprintf(os.Stderr, "%d log records exported, %d rejected\n", e.Exported, e.Rejected)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
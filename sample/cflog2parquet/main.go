@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/cflogparser/parquet"
+)
+
+// Convert log into Apache Parquet.
+//
+//	cflog2parquet -o access.parquet access.log ...
+//	cflog2parquet -dir s3sync/logs E2EXAMPLE.2019-10-01-*.gz
+//
+// With -dir, files are written under Hive-style partitions by date and
+// distribution, e.g. "date=2019-10-01/distribution=E2EXAMPLE/part-00000.parquet".
+// Distribution ID is taken from the name of each input file.
+func main() {
+	var optRTMP bool
+	var optOut, optDir, optCompression string
+	var optRowGroup int64
+	var optMaxOpen int
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.StringVar(&optOut, "o", "", "Write into this file (default STDOUT)")
+	flag.StringVar(&optDir, "dir", "", "Write into partitions under this directory, instead of -o")
+	flag.StringVar(&optCompression, "compression", "snappy", "Compression codec: snappy, gzip, zstd or none")
+	flag.Int64Var(&optRowGroup, "row-group", 128, "Size of row group in MiB")
+	flag.IntVar(&optMaxOpen, "max-open", 8, "Maximum number of partitions to keep open with -dir")
+	flag.Parse()
+
+	opts := parquet.Options{RowGroupSize: optRowGroup << 20, Compression: optCompression, MaxOpenPartitions: optMaxOpen}
+
+	var write func(dist string, rec interface{}) error
+	var closeAll func() error
+	if optDir != "" {
+		pw := parquet.NewPartitionedWriter(optDir, optRTMP, opts)
+		write, closeAll = pw.Write, pw.Close
+	} else {
+		out := os.Stdout
+		if optOut != "" {
+			f, err := os.Create(optOut)
+			if err != nil {
+				fail(err)
+			}
+			out = f
+		}
+		b := bufio.NewWriter(out)
+		w, err := parquet.NewWriter(b, optRTMP, opts)
+		if err != nil {
+			fail(err)
+		}
+		write = func(_ string, rec interface{}) error { return w.Write(rec) }
+		closeAll = func() error {
+			if err := w.Close(); err != nil {
+				return err
+			}
+			if err := b.Flush(); err != nil {
+				return err
+			}
+			return out.Close()
+		}
+	}
+
+	names := flag.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	for _, name := range names {
+		var r *cflogparser.Reader
+		if name == "-" {
+			r = cflogparser.NewReader(os.Stdin, optRTMP)
+		} else {
+			var err error
+			if r, err = cflogparser.OpenReader(name, optRTMP); err != nil {
+				fail(err)
+			}
+		}
+		dist := parquet.DistributionID(name)
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			var pe *cflogparser.ParseError
+			if errors.As(err, &pe) {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if err != nil {
+				fail(err)
+			}
+			if err := write(dist, rec); err != nil {
+				fail(err)
+			}
+		}
+		r.Close()
+	}
+	if err := closeAll(); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
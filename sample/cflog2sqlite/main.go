@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/cflogparser/sqlite"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Load log into a SQLite database.
+//
+//	cflog2sqlite -db logs.db E2EXAMPLE.2019-10-01-*.gz
+//	sqlite3 logs.db "SELECT status, count(*) FROM web_log GROUP BY status"
+//
+// Files already imported are skipped, so the same glob can be given again
+// to append only new files. Files are identified by their base names, as
+// CloudFront names log files uniquely.
+func main() {
+	var optRTMP bool
+	var optDB, optTable string
+	var optBatch int
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.StringVar(&optDB, "db", "cflog.db", "SQLite database file")
+	flag.StringVar(&optTable, "table", "", "Name of table (default web_log or rtmp_log)")
+	flag.IntVar(&optBatch, "batch", 10000, "Number of rows inserted in a transaction")
+	flag.Parse()
+
+	db, err := sql.Open("sqlite3", "file:"+optDB+"?_journal_mode=WAL&_synchronous=NORMAL")
+	if err != nil {
+		fail(err)
+	}
+	defer db.Close()
+	l, err := sqlite.NewLoader(db, optRTMP, sqlite.Options{Table: optTable, BatchSize: optBatch})
+	if err != nil {
+		fail(err)
+	}
+
+	names := flag.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	for _, name := range names {
+		var r *cflogparser.Reader
+		key := filepath.Base(name)
+		if name == "-" {
+			r = cflogparser.NewReader(os.Stdin, optRTMP)
+			key = ""
+		} else {
+			var err error
+			if r, err = cflogparser.OpenReader(name, optRTMP); err != nil {
+				fail(err)
+			}
+		}
+		ok, err := l.Begin(key)
+		if err != nil {
+			fail(err)
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s: already imported, skipped\n", name)
+			r.Close()
+			continue
+		}
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			var pe *cflogparser.ParseError
+			if errors.As(err, &pe) {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if err != nil {
+				fail(err)
+			}
+			if err := l.Insert(rec); err != nil {
+				fail(err)
+			}
+		}
+		r.Close()
+		if err := l.End(); err != nil {
+			fail(err)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d rows imported\n", name, l.Rows())
+	}
+	if err := l.Close(); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
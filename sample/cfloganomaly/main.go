@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Scan Web distribution logs for spikes in traffic, errors, bytes or latency.
+//
+//	cfloganomaly -by host -detector seasonal -period 24h -seasons 7 access.log ...
+//
+// Consecutive anomalous buckets are printed as a window, together with top
+// URIs and client IPs of the most anomalous bucket in the window.
+func main() {
+	var optBy, optMetrics, optDetector, optFrom, optTo string
+	var optInterval, optPeriod time.Duration
+	var optThreshold, optAlpha, optMinDev float64
+	var optSeasons, optWindow, optN int
+	flag.StringVar(&optBy, "by", "host", "How to split series: host, location or all")
+	flag.DurationVar(&optInterval, "interval", 5*time.Minute, "Width of time buckets")
+	flag.StringVar(&optMetrics, "metrics", strings.Join(cflogparser.MetricNames, ","), "Comma-separated metrics to scan")
+	flag.StringVar(&optDetector, "detector", "seasonal", "Detector: seasonal, ewma or zscore")
+	flag.Float64Var(&optThreshold, "threshold", 4, "Score to regard as anomaly")
+	flag.Float64Var(&optMinDev, "min-deviation", 0, "Lower bound of standard deviation")
+	flag.DurationVar(&optPeriod, "period", 24*time.Hour, "Length of a season for seasonal detector")
+	flag.IntVar(&optSeasons, "seasons", 7, "Number of past seasons for seasonal detector")
+	flag.IntVar(&optWindow, "window", 12, "Number of past buckets for zscore detector")
+	flag.Float64Var(&optAlpha, "alpha", 0.1, "Smoothing factor for ewma detector")
+	flag.StringVar(&optFrom, "from", "", "Ignore logs before this time (RFC3339)")
+	flag.StringVar(&optTo, "to", "", "Ignore logs at or after this time (RFC3339)")
+	flag.IntVar(&optN, "n", 5, "Number of top URIs and IPs to print")
+	flag.Parse()
+	if optInterval <= 0 {
+		fmt.Fprintln(os.Stderr, "-interval must be positive")
+		os.Exit(1)
+	}
+
+	var key func(*cflogparser.WebLog) string
+	switch optBy {
+	case "host":
+		key = func(l *cflogparser.WebLog) string { return l.Host }
+	case "location":
+		key = func(l *cflogparser.WebLog) string { return l.Location }
+	case "all":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown -by: %s\n", optBy)
+		os.Exit(1)
+	}
+
+	var det cflogparser.Detector
+	switch optDetector {
+	case "seasonal":
+		if optPeriod < optInterval || optSeasons < 1 {
+			fmt.Fprintln(os.Stderr, "-period must be at least -interval, and -seasons must be positive")
+			os.Exit(1)
+		}
+		det = &cflogparser.SeasonalDetector{Period: int(optPeriod / optInterval), Seasons: optSeasons, Threshold: optThreshold, MinDeviation: optMinDev}
+	case "ewma":
+		if optWindow < 0 {
+			fmt.Fprintln(os.Stderr, "-window must not be negative")
+			os.Exit(1)
+		}
+		det = &cflogparser.EWMADetector{Alpha: optAlpha, Threshold: optThreshold, Warmup: optWindow, MinDeviation: optMinDev}
+	case "zscore":
+		if optWindow < 1 {
+			fmt.Fprintln(os.Stderr, "-window must be positive")
+			os.Exit(1)
+		}
+		det = &cflogparser.ZScoreDetector{Window: optWindow, Threshold: optThreshold, MinDeviation: optMinDev}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown -detector: %s\n", optDetector)
+		os.Exit(1)
+	}
+
+	var from, to time.Time
+	var err error
+	if optFrom != "" {
+		if from, err = time.Parse(time.RFC3339, optFrom); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if optTo != "" {
+		if to, err = time.Parse(time.RFC3339, optTo); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	bucketer, err := cflogparser.NewBucketer(optInterval, key, 10*optN)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			l, err := cflogparser.ParseLineWeb(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			if (!from.IsZero() && l.Time.Before(from)) || (!to.IsZero() && !l.Time.Before(to)) {
+				return nil
+			}
+			bucketer.Add(l)
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	for _, k := range bucketer.Keys() {
+		series := bucketer.Series(k)
+		for _, metric := range strings.Split(optMetrics, ",") {
+			values := make([]float64, len(series))
+			for i, m := range series {
+				v, err := m.Value(metric)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				values[i] = v
+			}
+			for _, w := range windows(det.Detect(values)) {
+				peak := series[w.peak.Index]
+				fmt.Printf("%s\t%s\t%s - %s\tvalue=%g expected=%g score=%.2f\n", k, metric,
+					series[w.start].Time.Format(time.RFC3339), series[w.end].Time.Add(optInterval).Format(time.RFC3339),
+					w.peak.Value, w.peak.Expected, w.peak.Score)
+				for _, e := range peak.TopURIs(optN) {
+					fmt.Printf("\tURI: %d\t%s\n", e.Count, e.Key)
+				}
+				for _, e := range peak.TopIPs(optN) {
+					fmt.Printf("\tIP: %d\t%s\n", e.Count, e.Key)
+				}
+			}
+		}
+	}
+}
+
+type window struct {
+	start, end int
+	peak       cflogparser.Anomaly
+}
+
+// windows merges anomalies at consecutive indices into windows.
+func windows(as []cflogparser.Anomaly) []window {
+	var ws []window
+	for _, a := range as {
+		if n := len(ws); n > 0 && ws[n-1].end+1 == a.Index {
+			ws[n-1].end = a.Index
+			if a.Score > ws[n-1].peak.Score {
+				ws[n-1].peak = a
+			}
+			continue
+		}
+		ws = append(ws, window{start: a.Index, end: a.Index, peak: a})
+	}
+	return ws
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Remove or pseudonymize personal information in log, and write it in
+// CloudFront format or as JSON.
+//
+//	cfloganon -key-file secret -ip hash -cookies drop -cookie zip=keep \
+//	    -param token=drop -pattern '@' access.log ... > anonymized.log
+//
+// Actions are keep, hash, drop and truncate (IP addresses only). Hashes are
+// keyed, so the same key gives the same pseudonyms across runs.
+func main() {
+	var optRTMP bool
+	var optKey, optKeyFile, optIP, optCookies, optParams, optPatternAction, optFormat string
+	var optIPv4Prefix, optIPv6Prefix int
+	var optCookie, optParam, optPattern stringList
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.StringVar(&optKey, "key", "", "Key for hashing")
+	flag.StringVar(&optKeyFile, "key-file", "", "Read key for hashing from this file")
+	flag.StringVar(&optIP, "ip", "truncate", "Action for IP addresses")
+	flag.IntVar(&optIPv4Prefix, "ip4-prefix", 24, "Prefix length to keep for -ip=truncate of IPv4 addresses")
+	flag.IntVar(&optIPv6Prefix, "ip6-prefix", 48, "Prefix length to keep for -ip=truncate of IPv6 addresses")
+	flag.StringVar(&optCookies, "cookies", "keep", "Action for cookies not given by -cookie")
+	flag.Var(&optCookie, "cookie", "Action for a cookie as name=action (can be repeated)")
+	flag.StringVar(&optParams, "params", "keep", "Action for query parameters not given by -param")
+	flag.Var(&optParam, "param", "Action for a query parameter as name=action (can be repeated)")
+	flag.Var(&optPattern, "pattern", "Regexp of values to handle by -pattern-action (can be repeated)")
+	flag.StringVar(&optPatternAction, "pattern-action", "hash", "Action for values matching -pattern")
+	flag.StringVar(&optFormat, "format", "cloudfront", `Output format: "cloudfront" or "json"`)
+	flag.Parse()
+
+	key := []byte(optKey)
+	if optKeyFile != "" {
+		b, err := ioutil.ReadFile(optKeyFile)
+		if err != nil {
+			fail(err)
+		}
+		key = []byte(strings.TrimSpace(string(b)))
+	}
+
+	if optIPv4Prefix < 0 || optIPv4Prefix > 32 {
+		fail(fmt.Errorf("-ip4-prefix must be between 0 and 32: %d", optIPv4Prefix))
+	}
+	if optIPv6Prefix < 0 || optIPv6Prefix > 128 {
+		fail(fmt.Errorf("-ip6-prefix must be between 0 and 128: %d", optIPv6Prefix))
+	}
+
+	a := cflogparser.NewAnonymizer(key)
+	a.IPv4Prefix = optIPv4Prefix
+	a.IPv6Prefix = optIPv6Prefix
+	var err error
+	if a.IP, err = cflogparser.ParseAction(optIP); err != nil {
+		fail(err)
+	}
+	if a.DefaultCookie, err = cflogparser.ParseAction(optCookies); err != nil {
+		fail(err)
+	}
+	if a.DefaultParam, err = cflogparser.ParseAction(optParams); err != nil {
+		fail(err)
+	}
+	if a.PatternAction, err = cflogparser.ParseAction(optPatternAction); err != nil {
+		fail(err)
+	}
+	if a.Cookies, err = parseActions(optCookie); err != nil {
+		fail(err)
+	}
+	if a.Params, err = parseActions(optParam); err != nil {
+		fail(err)
+	}
+	for _, p := range optPattern {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			fail(err)
+		}
+		a.Patterns = append(a.Patterns, re)
+	}
+	if len(key) == 0 {
+		fmt.Fprintln(os.Stderr, "Warning: no key is given; hashed values can be reversed by brute force")
+	}
+
+	var write func(interface{})
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	switch optFormat {
+	case "cloudfront":
+		header := cflogparser.WebLogHeader
+		if optRTMP {
+			header = cflogparser.RTMPLogHeader
+		}
+		for _, h := range header {
+			fmt.Fprintln(w, h)
+		}
+		write = func(rec interface{}) {
+			fmt.Fprintln(w, cflogparser.FormatLine(rec))
+		}
+	case "json":
+		enc := json.NewEncoder(w)
+		write = func(rec interface{}) {
+			if err := enc.Encode(rec); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	default:
+		fail(fmt.Errorf("Unknown format: %s", optFormat))
+	}
+
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			var l interface{}
+			var err error
+			if optRTMP {
+				l, err = cflogparser.ParseLineRTMP(line)
+			} else {
+				l, err = cflogparser.ParseLineWeb(line)
+			}
+			if err != nil {
+				// Don't print the line, which may contain personal information.
+				fmt.Fprintln(os.Stderr, "Can't parse line")
+				return nil
+			}
+			if err := a.Anonymize(l); err != nil {
+				fail(err)
+			}
+			write(l)
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func parseActions(list []string) (map[string]cflogparser.Action, error) {
+	m := map[string]cflogparser.Action{}
+	for _, s := range list {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("Invalid name=action: %s", s)
+		}
+		act, err := cflogparser.ParseAction(s[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		m[s[:i]] = act
+	}
+	return m, nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
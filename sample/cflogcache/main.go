@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Report cache efficiency of Web distribution logs.
+//
+//	cflogcache -depth 2 -n 20 access.log ...
+func main() {
+	var optDepth, optN int
+	flag.IntVar(&optDepth, "depth", 1, "Number of path segments to group URIs by")
+	flag.IntVar(&optN, "n", 20, "Number of rows to print in each section")
+	flag.Parse()
+
+	report := cflogparser.NewCacheReport(optDepth)
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			l, err := cflogparser.ParseLineWeb(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			report.Add(l)
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	t := report.Total
+	fmt.Printf("Requests: %d, Bytes: %d\n", t.Requests, t.Bytes)
+	fmt.Printf("Hit: %d, RefreshHit: %d, Miss: %d\n", t.Hit, t.RefreshHit, t.Miss)
+	fmt.Printf("Hit ratio: %.2f%% (requests), %.2f%% (bytes)\n", 100*t.HitRatio(), 100*t.ByteHitRatio())
+
+	printGroups("URI prefix", report.ByPrefix(), optN)
+	printGroups("Content type", report.ByContentType(), optN)
+	printGroups("Edge location", report.ByLocation(), optN)
+
+	fmt.Println("\n== Top URIs causing Miss/RefreshHit")
+	fmt.Println("count\terror\turi")
+	for _, e := range report.MissURIs(optN) {
+		fmt.Printf("%d\t%d\t%s\n", e.Count, e.Error, e.Key)
+	}
+
+	fmt.Println("\n== URIs fragmented by query strings")
+	fmt.Println("misses\tqueries\turi")
+	for _, f := range report.FragmentedURIs(optN) {
+		fmt.Printf("%d\t%d\t%s\n", f.Misses, f.DistinctQueries, f.URI)
+	}
+}
+
+func printGroups(title string, gs []cflogparser.CacheGroup, n int) {
+	fmt.Printf("\n== By %s\n", strings.ToLower(title))
+	fmt.Printf("requests\tbytes\thit%%\tbyte-hit%%\tmiss\trefresh-hit\t%s\n", strings.ToLower(title))
+	for i, g := range gs {
+		if i >= n {
+			break
+		}
+		fmt.Printf("%d\t%d\t%.2f\t%.2f\t%d\t%d\t%s\n", g.Requests, g.Bytes, 100*g.HitRatio(), 100*g.ByteHitRatio(), g.Miss, g.RefreshHit, g.Name)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Count distinct clients or URIs approximately with HyperLogLog.
+//
+//	cflogcard -by ip+ua -group day access.log ...
+//
+// Intermediate state can be saved with -save and merged later with -merge,
+// so that huge number of files can be counted in parallel.
+func main() {
+	var optBy, optGroup, optSave string
+	var optPrecision uint
+	var optMerge stringList
+	flag.StringVar(&optBy, "by", "ip", "What to count: ip, ip+ua or uri")
+	flag.StringVar(&optGroup, "group", "day", "How to group: day, host or all")
+	flag.UintVar(&optPrecision, "p", 14, "Precision of HyperLogLog (4-18)")
+	flag.StringVar(&optSave, "save", "", "Save counter state to this file instead of printing counts")
+	flag.Var(&optMerge, "merge", "Merge counter state saved by -save (can be repeated)")
+	flag.Parse()
+
+	key, ok := keyFuncs[optBy]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown -by: %s\n", optBy)
+		os.Exit(1)
+	}
+	group, ok := groupFuncs[optGroup]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown -group: %s\n", optGroup)
+		os.Exit(1)
+	}
+	if optPrecision < cflogparser.MinHLLPrecision || optPrecision > cflogparser.MaxHLLPrecision {
+		fmt.Fprintf(os.Stderr, "-p must be between %d and %d: %d\n", cflogparser.MinHLLPrecision, cflogparser.MaxHLLPrecision, optPrecision)
+		os.Exit(1)
+	}
+	cnt, err := cflogparser.NewDistinctCounter(uint8(optPrecision))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, file := range optMerge {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		var c cflogparser.DistinctCounter
+		if err := c.UnmarshalBinary(b); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			os.Exit(1)
+		}
+		if err := cnt.Merge(&c); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			os.Exit(1)
+		}
+	}
+
+	// Read logs only if there are files to read, or nothing to merge.
+	if flag.NArg() > 0 || len(optMerge) == 0 {
+		rd := argvreader.NewReader(flag.Args())
+		for {
+			err := forlines.Do(rd, func(line string) error {
+				if strings.HasPrefix(line, "#") {
+					// Ignore leading comment lines for meta-information
+					return nil
+				}
+				l, err := cflogparser.ParseLineWeb(line)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return nil
+				}
+				cnt.Add(group(l), key(l))
+				return nil
+			})
+			if err == nil {
+				break
+			}
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	if optSave != "" {
+		b, _ := cnt.MarshalBinary()
+		if err := ioutil.WriteFile(optSave, b, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	for _, g := range cnt.Groups() {
+		fmt.Printf("%s\t%d\n", g, cnt.Count(g))
+	}
+}
+
+var keyFuncs = map[string]func(*cflogparser.WebLog) string{
+	"ip":    func(l *cflogparser.WebLog) string { return l.RequestIP.String() },
+	"ip+ua": func(l *cflogparser.WebLog) string { return l.RequestIP.String() + "\t" + l.UserAgent },
+	"uri":   func(l *cflogparser.WebLog) string { return l.URI },
+}
+
+var groupFuncs = map[string]func(*cflogparser.WebLog) string{
+	"day":  func(l *cflogparser.WebLog) string { return l.Time.Format("2006-01-02") },
+	"host": func(l *cflogparser.WebLog) string { return l.Host },
+	"all":  func(l *cflogparser.WebLog) string { return "all" },
+}
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
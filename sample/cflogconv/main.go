@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Convert log into another format, optionally selecting fields.
+//
+//	cflogconv -format csv -fields time,status,uri access.log ...
+//	cflogconv -format table -fields time,request_ip,uri -time-format 15:04:05 access.log
+//	cflogconv -format logfmt -time-format unix access.log
+func main() {
+	var optRTMP bool
+	var optFormat, optFields, optTimeFormat string
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.StringVar(&optFormat, "format", "ndjson", "Output format: "+strings.Join(cflogparser.OutputFormats, ", "))
+	flag.StringVar(&optFields, "fields", "", "Comma-separated fields to output (JSON names; default all)")
+	flag.StringVar(&optTimeFormat, "time-format", "", `Layout of time in Go's time.Format, "unix" or "unixms" (default RFC3339)`)
+	flag.Parse()
+
+	var proto interface{} = &cflogparser.WebLog{}
+	if optRTMP {
+		proto = &cflogparser.RTMPLog{}
+	}
+	var names []string
+	if optFields != "" {
+		names = strings.Split(optFields, ",")
+	}
+	fields, err := cflogparser.LookupFields(proto, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	w, err := cflogparser.NewRecordWriter(out, optFormat, fields)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	w.TimeFormat = optTimeFormat
+	defer w.Flush()
+
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			var l interface{}
+			var err error
+			if optRTMP {
+				l, err = cflogparser.ParseLineRTMP(line)
+			} else {
+				l, err = cflogparser.ParseLineWeb(line)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			if err := w.Write(l); err != nil {
+				// Output is broken, e.g. closed pipe, so no reason to continue.
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// Print a table definition of log for Athena, BigQuery or ClickHouse.
+//
+//	cflogddl -dialect athena-tsv -location s3://my-logs/cloudfront/
+//	cflogddl -dialect athena-parquet -table cf_parquet -location s3://my-lake/cloudfront/
+//	cflogddl -dialect bigquery > schema.json
+func main() {
+	var optRTMP bool
+	var optDialect, optTable, optLocation string
+	flag.BoolVar(&optRTMP, "rtmp", false, "Define table of RTMP distribution log")
+	flag.StringVar(&optDialect, "dialect", "athena-tsv", "Dialect: "+strings.Join(cflogparser.TableDialects, ", "))
+	flag.StringVar(&optTable, "table", "cloudfront_logs", "Name of table")
+	flag.StringVar(&optLocation, "location", "", "Location of data, e.g. s3://bucket/prefix/ (required by Athena)")
+	flag.Parse()
+
+	ddl, err := cflogparser.CreateTable(optDialect, cflogparser.TableOptions{
+		Name:     optTable,
+		Location: optLocation,
+		RTMP:     optRTMP,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Print(ddl)
+}
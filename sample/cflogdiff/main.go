@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Compare two sets of Web distribution logs and report significant changes.
+//
+//	cflogdiff -before 'yesterday/*.log' -after 'today/*.log'
+//
+// Changes are ranked by p-value, i.e. statistical significance, rather than
+// by raw delta, so that changes in tiny URIs do not bury important ones.
+func main() {
+	var optBefore, optAfter stringList
+	var optAlpha float64
+	var optCapacity, optN int
+	flag.Var(&optBefore, "before", "Log files (or glob pattern) of the period before (can be repeated)")
+	flag.Var(&optAfter, "after", "Log files (or glob pattern) of the period after (can be repeated)")
+	flag.Float64Var(&optAlpha, "alpha", 0.001, "Report changes whose p-value is at most this")
+	flag.IntVar(&optCapacity, "capacity", 100000, "Number of URIs and user agents to track")
+	flag.IntVar(&optN, "n", 50, "Number of changes to print")
+	flag.Parse()
+
+	if len(optBefore) == 0 || len(optAfter) == 0 {
+		fmt.Fprintln(os.Stderr, "Both -before and -after are required")
+		os.Exit(1)
+	}
+	before := cflogparser.NewPeriodStats(optCapacity)
+	aggregate(expand(optBefore), before)
+	after := cflogparser.NewPeriodStats(optCapacity)
+	aggregate(expand(optAfter), after)
+
+	fmt.Printf("Requests: %d -> %d\n", before.Requests, after.Requests)
+	fmt.Printf("Hit ratio: %.2f%% -> %.2f%%\n", 100*before.Cache.HitRatio(), 100*after.Cache.HitRatio())
+	fmt.Printf("Latency p50/p90/p99: %.3f/%.3f/%.3f -> %.3f/%.3f/%.3f\n",
+		before.Latency(0.5), before.Latency(0.9), before.Latency(0.99),
+		after.Latency(0.5), after.Latency(0.9), after.Latency(0.99))
+	fmt.Println()
+	fmt.Println("p-value\tkind\tbefore\tafter\tkey")
+	for i, c := range cflogparser.Diff(before, after, optAlpha) {
+		if i >= optN {
+			break
+		}
+		key := c.Key
+		if c.Note != "" {
+			key += " (" + c.Note + ")"
+		}
+		fmt.Printf("%.3g\t%s\t%.4g\t%.4g\t%s\n", c.PValue, c.Kind, c.Before, c.After, key)
+	}
+}
+
+func expand(patterns []string) []string {
+	var files []string
+	for _, p := range patterns {
+		ms, err := filepath.Glob(p)
+		if err != nil || len(ms) == 0 {
+			files = append(files, p)
+			continue
+		}
+		files = append(files, ms...)
+	}
+	return files
+}
+
+func aggregate(files []string, stats *cflogparser.PeriodStats) {
+	rd := argvreader.NewReader(files)
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			l, err := cflogparser.ParseLineWeb(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			stats.Add(l)
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Break down errors in Web distribution logs by cause.
+//
+//	cflogerrors -interval 5m -samples 5 access.log ...
+//
+// Each error cluster is printed with sample request IDs, which can be
+// attached to AWS support tickets.
+func main() {
+	var optInterval time.Duration
+	var optSamples, optN int
+	flag.DurationVar(&optInterval, "interval", 5*time.Minute, "Width of time windows for timeline")
+	flag.IntVar(&optSamples, "samples", 5, "Number of sample request IDs per cluster")
+	flag.IntVar(&optN, "n", 5, "Number of URIs and locations to print per cluster")
+	flag.Parse()
+
+	report := cflogparser.NewErrorReport(optInterval, optSamples)
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			l, err := cflogparser.ParseLineWeb(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			report.Add(l)
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	t := report.Total
+	fmt.Printf("Requests: %d, Errors: %d (%.2f%%), Origin: %d, Edge: %d\n",
+		t.Requests, t.Errors, 100*t.ErrorRate(), t.OriginErrors, t.EdgeErrors)
+
+	fmt.Println("\n== Clusters")
+	for _, c := range report.Clusters() {
+		fmt.Printf("\n%d %s (%s): %d errors from %s to %s\n", c.Status, c.ResultType, c.Source, c.Count,
+			c.FirstSeen.Format(time.RFC3339), c.LastSeen.Format(time.RFC3339))
+		fmt.Printf("  Request IDs: %s\n", strings.Join(c.SampleRequestIDs, " "))
+		for _, e := range c.TopLocations(optN) {
+			fmt.Printf("  Location: %d\t%s\n", e.Count, e.Key)
+		}
+		for _, e := range c.TopURIs(optN) {
+			fmt.Printf("  URI: %d\t%s\n", e.Count, e.Key)
+		}
+	}
+
+	fmt.Println("\n== By edge location")
+	fmt.Println("requests\terrors\terror%\torigin\tedge\tlocation")
+	for _, g := range report.ByLocation() {
+		fmt.Printf("%d\t%d\t%.2f\t%d\t%d\t%s\n", g.Requests, g.Errors, 100*g.ErrorRate(), g.OriginErrors, g.EdgeErrors, g.Name)
+	}
+
+	fmt.Println("\n== Timeline")
+	fmt.Println("time\trequests\terrors\terror%\torigin\tedge")
+	for _, b := range report.Timeline() {
+		fmt.Printf("%s\t%d\t%d\t%.2f\t%d\t%d\n", b.Time.Format(time.RFC3339), b.Requests, b.Errors, 100*b.ErrorRate(), b.OriginErrors, b.EdgeErrors)
+	}
+}
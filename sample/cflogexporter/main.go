@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser/prometheus"
+)
+
+// Serve metrics of log files, which are tailed as they arrive, for
+// Prometheus.
+//
+//	cflogexporter -listen :9106 '/var/log/cloudfront/*.gz'
+//	cflogexporter -labels status_class,location,uri -label-limit uri=500 'logs/*'
+//
+// Patterns should be quoted to be expanded by cflogexporter at every poll,
+// rather than once by the shell.
+func main() {
+	var optListen, optLabels, optBuckets string
+	var optInterval time.Duration
+	var optLimit, optMaxSeries int
+	var optLabelLimit stringList
+	var optFromEnd bool
+	flag.StringVar(&optListen, "listen", ":9106", "Address to serve /metrics")
+	flag.DurationVar(&optInterval, "interval", 10*time.Second, "Interval to poll log files")
+	flag.StringVar(&optLabels, "labels", strings.Join(prometheus.DefaultLabels, ","), "Comma-separated fields to label metrics with")
+	flag.IntVar(&optLimit, "limit", 100, "Maximum number of distinct values of each label (-1 for unlimited)")
+	flag.Var(&optLabelLimit, "label-limit", "Limit of a label as name=n (can be repeated)")
+	flag.IntVar(&optMaxSeries, "max-series", 10000, "Maximum number of combinations of label values (-1 for unlimited)")
+	flag.StringVar(&optBuckets, "buckets", "", "Comma-separated buckets of time taken in seconds")
+	flag.BoolVar(&optFromEnd, "from-end", false, "Skip content of files existing at start")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fail(fmt.Errorf("No pattern of log files is given"))
+	}
+
+	opts := prometheus.Options{Labels: []string{}, Limit: optLimit, Limits: map[string]int{}, MaxSeries: optMaxSeries}
+	if optLabels != "" {
+		opts.Labels = strings.Split(optLabels, ",")
+	}
+	for _, s := range optLabelLimit {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			fail(fmt.Errorf("Invalid label limit: %s", s))
+		}
+		n, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			fail(fmt.Errorf("Invalid label limit: %s", s))
+		}
+		opts.Limits[s[:i]] = n
+	}
+	if optBuckets != "" {
+		for _, s := range strings.Split(optBuckets, ",") {
+			b, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				fail(fmt.Errorf("Invalid bucket: %s", s))
+			}
+			opts.Buckets = append(opts.Buckets, b)
+		}
+	}
+	c, err := prometheus.NewCollector(opts)
+	if err != nil {
+		fail(err)
+	}
+
+	t := prometheus.NewTailer(c, flag.Args()...)
+	t.FromEnd = optFromEnd
+	go func() {
+		for {
+			if err := t.Poll(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			time.Sleep(optInterval)
+		}
+	}()
+
+	http.Handle("/metrics", c)
+	fail(http.ListenAndServe(optListen, nil))
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser/generator"
+)
+
+// Generate synthetic log for tests and load testing.
+//
+//	cfloggen -n 1000000 -seed 1 > access.log
+//	cfloggen -rtmp -n 1000 > rtmp.log
+func main() {
+	var optRTMP bool
+	var optN int
+	var optStart string
+	cfg := generator.DefaultConfig()
+	flag.BoolVar(&optRTMP, "rtmp", false, "Generate RTMP distribution log")
+	flag.IntVar(&optN, "n", 1000, "Number of records")
+	flag.Int64Var(&cfg.Seed, "seed", 1, "Random seed")
+	flag.StringVar(&optStart, "start", cfg.Start.Format(time.RFC3339), "Time of the first record")
+	flag.Float64Var(&cfg.Rate, "rate", cfg.Rate, "Mean number of records per second")
+	flag.IntVar(&cfg.Clients, "clients", cfg.Clients, "Number of distinct clients")
+	flag.Float64Var(&cfg.HitRatio, "hit-ratio", cfg.HitRatio, "Fraction of successful requests served from cache")
+	flag.Float64Var(&cfg.IPv6Ratio, "ipv6-ratio", cfg.IPv6Ratio, "Fraction of clients with IPv6 address")
+	flag.Float64Var(&cfg.DoubleEscapeRatio, "double-escape", cfg.DoubleEscapeRatio, "Fraction of lines escaped twice as CloudFront does")
+	flag.Parse()
+
+	start, err := time.Parse(time.RFC3339, optStart)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cfg.Start = start
+
+	g := generator.New(cfg)
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for _, h := range generator.Header(optRTMP) {
+		fmt.Fprintln(w, h)
+	}
+	for i := 0; i < optN; i++ {
+		if optRTMP {
+			fmt.Fprintln(w, g.Line(g.RTMP()))
+		} else {
+			fmt.Fprintln(w, g.Line(g.Web()))
+		}
+	}
+}
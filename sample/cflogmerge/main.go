@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// Merge log files, plain or gzipped, into a single log in chronological
+// order. Files that are out of order are sorted by external merge sort.
+//
+//	cflogmerge E2EXAMPLE.2019-10-01-00.*.gz > merged.log
+func main() {
+	var optRTMP bool
+	var optChunk int
+	var optTmpDir string
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.IntVar(&optChunk, "chunk", 1000000, "Number of lines sorted in memory at once for out-of-order files")
+	flag.StringVar(&optTmpDir, "tmpdir", "", "Directory for temporary files of external sort")
+	flag.Parse()
+
+	// Exit after deferred Close in run, which removes temporary files.
+	if err := run(flag.Args(), optRTMP, optChunk, optTmpDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run merges files of names to STDOUT. Readers are closed before it
+// returns, even on error.
+func run(names []string, rtmp bool, chunk int, dir string) error {
+	var readers []cflogparser.RecordReader
+	var header []string
+	for _, name := range names {
+		r, err := open(name, rtmp, chunk, dir)
+		if err != nil {
+			cflogparser.NewMergeReader(readers...).Close()
+			return err
+		}
+		readers = append(readers, r)
+		if header == nil {
+			header = peekHeader(name)
+		}
+	}
+	m := cflogparser.NewMergeReader(readers...)
+	defer m.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for _, h := range header {
+		fmt.Fprintln(w, h)
+	}
+	for {
+		_, err := m.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var pe *cflogparser.ParseError
+		if errors.As(err, &pe) {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, m.Line())
+	}
+}
+
+// open returns a RecordReader of name in chronological order.
+func open(name string, rtmp bool, chunk int, dir string) (cflogparser.RecordReader, error) {
+	f, err := cflogparser.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	sorted, err := cflogparser.IsSorted(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if sorted {
+		return cflogparser.OpenReader(name, rtmp)
+	}
+	fmt.Fprintf(os.Stderr, "%s: not in chronological order; sorting\n", name)
+	f, err = cflogparser.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return cflogparser.SortReader(f, rtmp, chunk, dir)
+}
+
+// peekHeader returns comment lines at the beginning of name.
+func peekHeader(name string) []string {
+	r, err := cflogparser.OpenReader(name, false)
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	r.Read()
+	return r.Header()
+}
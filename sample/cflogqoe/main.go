@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Report quality of experience of media delivery.
+//
+//	cflogqoe -rtmp rtmp.log ...   # per stream, from RTMP play intervals
+//	cflogqoe web.log ...          # per content, from CMCD query parameters
+func main() {
+	var optRTMP bool
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.Parse()
+
+	report := cflogparser.NewQoEReport()
+	z := cflogparser.NewRTMPSessionizer(nil, report.AddPlay)
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			if optRTMP {
+				l, err := cflogparser.ParseLineRTMP(line)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return nil
+				}
+				z.Add(l)
+				return nil
+			}
+			l, err := cflogparser.ParseLineWeb(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			report.AddWeb(l)
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if optRTMP {
+		z.Flush()
+		fmt.Println("plays\tabnormal\tavg-watch\tpauses/play\tseeks/play\tbitrate(kbps)\tstream")
+		for _, s := range report.Streams() {
+			fmt.Printf("%d\t%d\t%s\t%.2f\t%.2f\t%.0f\t%s\n", s.Plays, s.Abnormal, s.AverageWatchTime(),
+				s.PausesPerPlay(), s.SeeksPerPlay(), s.Bitrate()/1000, s.StreamName)
+		}
+		return
+	}
+	fmt.Println("requests\tsessions\tstarvation%\tbitrate-p50\tbitrate-p10\tbuffer-p50(ms)\tbuffer-p10(ms)\tthroughput-p50\tcontent")
+	for _, c := range report.Contents() {
+		fmt.Printf("%d\t%d\t%.2f\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\t%s\n", c.Requests, c.Sessions(), 100*c.StarvationRate(),
+			c.Bitrate(0.5), c.Bitrate(0.1), c.BufferLength(0.5), c.BufferLength(0.1), c.Throughput(0.5), c.ContentID)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Sample records and print them as JSON with "sample_rate" property, so that
+// aggregates of the sample can be re-weighted by 1/sample_rate.
+//
+//	cflogsample -field request_ip -rate 0.01 access.log ...   # 1% of clients
+//	cflogsample -n 1000 access.log ...                        # 1000 records
+//	cflogsample -n 100 -by status access.log ...              # 100 records per status
+func main() {
+	var optRTMP bool
+	var optField, optBy string
+	var optRate float64
+	var optN int
+	var optSeed int64
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.StringVar(&optField, "field", "request_id", "Field to hash for -rate (JSON name, e.g. request_ip, client_id)")
+	flag.Float64Var(&optRate, "rate", 0, "Keep this fraction of records by hash of -field")
+	flag.IntVar(&optN, "n", 0, "Keep this number of records at random, instead of -rate")
+	flag.StringVar(&optBy, "by", "", "With -n, keep -n records for each value of this field (e.g. status, location)")
+	flag.Int64Var(&optSeed, "seed", 1, "Random seed for -n")
+	flag.Parse()
+
+	var proto interface{} = &cflogparser.WebLog{}
+	if optRTMP {
+		proto = &cflogparser.RTMPLog{}
+	}
+	if optRate <= 0 && optN <= 0 {
+		fmt.Fprintln(os.Stderr, "Either -rate or -n is required")
+		os.Exit(1)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	var add func(interface{})
+	switch {
+	case optN > 0 && optBy != "":
+		f, err := cflogparser.LookupField(proto, optBy)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		s := cflogparser.NewStratifiedSampler(f, optN, optSeed)
+		add = s.Add
+		defer func() {
+			for _, st := range s.Strata() {
+				for _, rec := range st.Records() {
+					write(w, rec, st.Rate())
+				}
+			}
+		}()
+	case optN > 0:
+		r := cflogparser.NewReservoir(optN, optSeed)
+		add = r.Add
+		defer func() {
+			for _, rec := range r.Records() {
+				write(w, rec, r.Rate())
+			}
+		}()
+	default:
+		f, err := cflogparser.LookupField(proto, optField)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		s, err := cflogparser.NewHashSampler(f, optRate)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		add = func(rec interface{}) {
+			if s.Keep(rec) {
+				write(w, rec, s.Rate)
+			}
+		}
+	}
+
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			var l interface{}
+			var err error
+			if optRTMP {
+				l, err = cflogparser.ParseLineRTMP(line)
+			} else {
+				l, err = cflogparser.ParseLineWeb(line)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			add(l)
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// write prints rec as a JSON object with sample_rate added.
+func write(w *bufio.Writer, rec interface{}, rate float64) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	// rec is always marshaled into a non-empty object, so the property can
+	// be appended before the closing brace.
+	b = append(b[:len(b)-1], `,"sample_rate":`...)
+	b = strconv.AppendFloat(b, rate, 'g', -1, 64)
+	b = append(b, '}', '\n')
+	w.Write(b)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Reconstruct visitor sessions from time-ordered Web distribution logs and
+// print them as JSON, one session per line.
+//
+//	cflogsession -timeout 30m -cookie vid access.log ...
+func main() {
+	var optTimeout time.Duration
+	var optCookie string
+	var optMaxOpen int
+	flag.DurationVar(&optTimeout, "timeout", 30*time.Minute, "Inactivity timeout to close a session")
+	flag.StringVar(&optCookie, "cookie", "", "Identify visitors by this cookie instead of IP and user agent")
+	flag.IntVar(&optMaxOpen, "max-open", 1000000, "Maximum number of open sessions (0 means no limit)")
+	flag.Parse()
+
+	key := cflogparser.SessionKeyIPUA
+	if optCookie != "" {
+		key = cflogparser.SessionKeyCookie(optCookie)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	z := cflogparser.NewSessionizer(optTimeout, optMaxOpen, key, func(s *cflogparser.Session) {
+		if err := enc.Encode(s); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			l, err := cflogparser.ParseLineWeb(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			z.Add(l)
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+	z.Flush()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Compute SLIs, error budget and burn rates of Web distribution logs.
+//
+//	cflogslo -slo slo.json -windows 5m,1h,6h,72h access.log ...
+//
+// See SLO in cflogparser for the format of SLO definitions.
+func main() {
+	var optSLO, optWindows string
+	var optInterval time.Duration
+	var optSeries, optJSON bool
+	flag.StringVar(&optSLO, "slo", "", "JSON file of SLO definitions (required)")
+	flag.DurationVar(&optInterval, "interval", 5*time.Minute, "Width of time windows for series")
+	flag.StringVar(&optWindows, "windows", "5m,1h,6h,72h", "Comma-separated windows to compute burn rates over")
+	flag.BoolVar(&optSeries, "series", false, "Print SLIs and burn rates per time window")
+	flag.BoolVar(&optJSON, "json", false, "Output in JSON")
+	flag.Parse()
+
+	if optSLO == "" {
+		fmt.Fprintln(os.Stderr, "-slo is required")
+		os.Exit(1)
+	}
+	f, err := os.Open(optSLO)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slos, err := cflogparser.ParseSLOs(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var windows []time.Duration
+	for _, s := range strings.Split(optWindows, ",") {
+		w, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		windows = append(windows, w)
+	}
+
+	trackers := make([]*cflogparser.SLOTracker, len(slos))
+	for i, s := range slos {
+		trackers[i] = cflogparser.NewSLOTracker(s, optInterval)
+	}
+	rd := argvreader.NewReader(flag.Args())
+	for {
+		err := forlines.Do(rd, func(line string) error {
+			if strings.HasPrefix(line, "#") {
+				// Ignore leading comment lines for meta-information
+				return nil
+			}
+			l, err := cflogparser.ParseLineWeb(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return nil
+			}
+			for _, t := range trackers {
+				t.Add(l)
+			}
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if optJSON {
+		type result struct {
+			*cflogparser.SLOSummary
+			Series []cflogparser.SLOPoint `json:"series,omitempty"`
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, t := range trackers {
+			r := result{SLOSummary: t.Summary(windows)}
+			if optSeries {
+				r.Series = t.Series()
+			}
+			enc.Encode(r)
+		}
+		return
+	}
+
+	for _, t := range trackers {
+		s := t.Summary(windows)
+		fmt.Printf("== %s: %d requests\n", s.Name, s.Requests)
+		if t.SLO.Availability > 0 {
+			fmt.Printf("Availability: %.4f%% (target %.4f%%), budget remaining %.2f%%\n",
+				100*s.AvailabilitySLI, 100*t.SLO.Availability, 100*s.AvailabilityBudgetRemaining)
+		}
+		if t.SLO.LatencyTarget > 0 {
+			fmt.Printf("Latency <= %gs: %.4f%% (target %.4f%%), budget remaining %.2f%%\n",
+				t.SLO.LatencyThreshold, 100*s.LatencySLI, 100*t.SLO.LatencyTarget, 100*s.LatencyBudgetRemaining)
+		}
+		fmt.Println("window\tavailability-burn\tlatency-burn")
+		for _, b := range s.BurnRates {
+			fmt.Printf("%s\t%.2f\t%.2f\n", b.Window, b.Availability, b.Latency)
+		}
+		if optSeries {
+			fmt.Println("time\trequests\tavailability\tlatency\tavailability-burn\tlatency-burn")
+			for _, p := range t.Series() {
+				fmt.Printf("%s\t%d\t%.4f\t%.4f\t%.2f\t%.2f\n", p.Time.Format(time.RFC3339), p.Requests,
+					p.AvailabilitySLI(), p.LatencySLI(), p.AvailabilityBurnRate, p.LatencyBurnRate)
+			}
+		}
+		fmt.Println()
+	}
+}
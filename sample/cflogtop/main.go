@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	"github.com/Maki-Daisuke/go-argvreader"
+	"github.com/mattn/go-forlines"
+)
+
+// Find heavy hitters of any field in bounded memory.
+//
+//	cflogtop -field uri -n 100 access.log ...
+//
+// Each line of output is count, maximum overestimation of the count, and
+// value of the field. Intermediate state can be saved with -save and merged
+// later with -merge.
+func main() {
+	var optRTMP bool
+	var optField, optSave string
+	var optN, optCapacity int
+	var optMerge stringList
+	flag.BoolVar(&optRTMP, "rtmp", false, "Parse input as RTMP distribution log")
+	flag.StringVar(&optField, "field", "uri", "Field to count (JSON name, e.g. uri, request_ip, referrer)")
+	flag.IntVar(&optN, "n", 100, "Number of items to print")
+	flag.IntVar(&optCapacity, "capacity", 0, "Number of counters to keep (default 10 times of -n)")
+	flag.StringVar(&optSave, "save", "", "Save counter state to this file instead of printing result")
+	flag.Var(&optMerge, "merge", "Merge counter state saved by -save (can be repeated)")
+	flag.Parse()
+
+	if optCapacity <= 0 {
+		optCapacity = 10 * optN
+	}
+	var sample interface{} = &cflogparser.WebLog{}
+	if optRTMP {
+		sample = &cflogparser.RTMPLog{}
+	}
+	field, err := cflogparser.LookupField(sample, optField)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	tk := cflogparser.NewTopK(optCapacity)
+	for _, file := range optMerge {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		var t cflogparser.TopK
+		if err := t.UnmarshalBinary(b); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			os.Exit(1)
+		}
+		if err := tk.Merge(&t); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			os.Exit(1)
+		}
+	}
+
+	if flag.NArg() > 0 || len(optMerge) == 0 {
+		rd := argvreader.NewReader(flag.Args())
+		for {
+			err := forlines.Do(rd, func(line string) error {
+				if strings.HasPrefix(line, "#") {
+					// Ignore leading comment lines for meta-information
+					return nil
+				}
+				var l interface{}
+				var err error
+				if !optRTMP {
+					l, err = cflogparser.ParseLineWeb(line)
+				} else {
+					l, err = cflogparser.ParseLineRTMP(line)
+				}
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return nil
+				}
+				tk.Add(field.String(l), 1)
+				return nil
+			})
+			if err == nil {
+				break
+			}
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	if optSave != "" {
+		b, _ := tk.MarshalBinary()
+		if err := ioutil.WriteFile(optSave, b, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Printf("# total: %d, error bound: %d\n", tk.Total(), tk.ErrorBound())
+	for _, e := range tk.Top(optN) {
+		fmt.Printf("%d\t%d\t%s\n", e.Count, e.Error, e.Key)
+	}
+}
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
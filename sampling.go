@@ -0,0 +1,125 @@
+package cflogparser
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// HashSampler keeps records by hash of a field, so that the same value of
+// the field is always kept or always dropped, across files and processes.
+// For example, sampling by request_ip keeps all requests of 1% of clients,
+// and sampling by request_id keeps 1% of requests uniformly.
+type HashSampler struct {
+	Field Field
+	Rate  float64
+	limit uint64
+}
+
+// NewHashSampler returns a HashSampler keeping about rate of records, which
+// must be in (0, 1].
+func NewHashSampler(field Field, rate float64) (*HashSampler, error) {
+	if rate <= 0 || rate > 1 {
+		return nil, fmt.Errorf("Sampling rate must be in (0, 1]: %g", rate)
+	}
+	s := &HashSampler{Field: field, Rate: rate, limit: math.MaxUint64}
+	if x := rate * (1 << 64); x < 1<<64 {
+		s.limit = uint64(x)
+	}
+	return s, nil
+}
+
+// Keep reports whether rec is in the sample.
+func (s *HashSampler) Keep(rec interface{}) bool {
+	return hash64String(s.Field.String(rec)) <= s.limit
+}
+
+// Reservoir keeps a uniform random sample of fixed size from a stream of
+// unknown length.
+type Reservoir struct {
+	size    int
+	seen    uint64
+	records []interface{}
+	rnd     *rand.Rand
+}
+
+// NewReservoir returns a Reservoir keeping at most size records. The same
+// seed and input give the same sample.
+func NewReservoir(size int, seed int64) *Reservoir {
+	return &Reservoir{size: size, rnd: rand.New(rand.NewSource(seed))}
+}
+
+// Add offers rec to r.
+func (r *Reservoir) Add(rec interface{}) {
+	r.seen++
+	if len(r.records) < r.size {
+		r.records = append(r.records, rec)
+		return
+	}
+	if i := r.rnd.Int63n(int64(r.seen)); i < int64(r.size) {
+		r.records[i] = rec
+	}
+}
+
+// Records returns the sample in no particular order.
+func (r *Reservoir) Records() []interface{} {
+	return r.records
+}
+
+// Seen returns the number of records offered.
+func (r *Reservoir) Seen() uint64 {
+	return r.seen
+}
+
+// Rate returns the fraction of offered records in the sample. Each sampled
+// record stands for 1/Rate records.
+func (r *Reservoir) Rate() float64 {
+	if r.seen == 0 {
+		return 1
+	}
+	return float64(len(r.records)) / float64(r.seen)
+}
+
+// Stratum is a sample of records sharing the same value of a field.
+type Stratum struct {
+	Key string
+	*Reservoir
+}
+
+// StratifiedSampler keeps a fixed-size sample for each value of a field, such
+// as status or location, so that rare values are not lost in the sample. The
+// rate differs among strata, and must be taken into account in aggregation.
+type StratifiedSampler struct {
+	Field  Field
+	size   int
+	seed   int64
+	strata map[string]*Reservoir
+}
+
+// NewStratifiedSampler returns a StratifiedSampler keeping at most size
+// records for each value of field.
+func NewStratifiedSampler(field Field, size int, seed int64) *StratifiedSampler {
+	return &StratifiedSampler{Field: field, size: size, seed: seed, strata: map[string]*Reservoir{}}
+}
+
+// Add offers rec to the stratum of its field value.
+func (s *StratifiedSampler) Add(rec interface{}) {
+	key := s.Field.String(rec)
+	r, ok := s.strata[key]
+	if !ok {
+		r = NewReservoir(s.size, s.seed+int64(hash64String(key)))
+		s.strata[key] = r
+	}
+	r.Add(rec)
+}
+
+// Strata returns all strata sorted by key.
+func (s *StratifiedSampler) Strata() []Stratum {
+	res := make([]Stratum, 0, len(s.strata))
+	for k, r := range s.strata {
+		res = append(res, Stratum{k, r})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
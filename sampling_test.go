@@ -0,0 +1,94 @@
+package cflogparser
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"testing"
+)
+
+func TestHashSampler(t *testing.T) {
+	f, err := LookupField(&WebLog{}, "request_ip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewHashSampler(f, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := 0
+	const n = 20000
+	for i := 0; i < n; i++ {
+		ip := net.IPv4(10, byte(i>>16), byte(i>>8), byte(i))
+		l := &WebLog{RequestIP: ip}
+		k := s.Keep(l)
+		if k {
+			kept++
+		}
+		// The same value must always get the same decision.
+		if s.Keep(&WebLog{RequestIP: ip, URI: "/other"}) != k {
+			t.Fatalf("inconsistent decision for %s", ip)
+		}
+	}
+	if rate := float64(kept) / n; math.Abs(rate-0.1) > 0.01 {
+		t.Errorf("got rate %g, want about 0.1", rate)
+	}
+	all, _ := NewHashSampler(f, 1)
+	if !all.Keep(&WebLog{}) {
+		t.Error("rate 1 must keep everything")
+	}
+	if _, err := NewHashSampler(f, 0); err == nil {
+		t.Error("expected error for rate 0")
+	}
+}
+
+func TestReservoir(t *testing.T) {
+	r := NewReservoir(10, 1)
+	for i := 0; i < 5; i++ {
+		r.Add(i)
+	}
+	if len(r.Records()) != 5 || r.Rate() != 1 {
+		t.Errorf("got %d records at rate %g, want 5 at 1", len(r.Records()), r.Rate())
+	}
+
+	// Every item should be picked with about the same probability.
+	counts := make([]int, 100)
+	for seed := int64(0); seed < 2000; seed++ {
+		r := NewReservoir(10, seed)
+		for i := 0; i < 100; i++ {
+			r.Add(i)
+		}
+		for _, x := range r.Records() {
+			counts[x.(int)]++
+		}
+		if r.Rate() != 0.1 {
+			t.Fatalf("got rate %g, want 0.1", r.Rate())
+		}
+	}
+	for i, c := range counts {
+		if c < 130 || c > 270 {
+			t.Errorf("item %d picked %d times, want about 200", i, c)
+		}
+	}
+}
+
+func TestStratifiedSampler(t *testing.T) {
+	f, _ := LookupField(&WebLog{}, "status")
+	s := NewStratifiedSampler(f, 5, 1)
+	for i := 0; i < 1000; i++ {
+		s.Add(&WebLog{Status: 200, URI: fmt.Sprint(i)})
+	}
+	for i := 0; i < 3; i++ {
+		s.Add(&WebLog{Status: 503})
+	}
+	st := s.Strata()
+	if len(st) != 2 || st[0].Key != "200" || st[1].Key != "503" {
+		t.Fatalf("unexpected strata: %+v", st)
+	}
+	if len(st[0].Records()) != 5 || st[0].Rate() != 0.005 {
+		t.Errorf("got %d records at rate %g for 200", len(st[0].Records()), st[0].Rate())
+	}
+	if len(st[1].Records()) != 3 || st[1].Rate() != 1 {
+		t.Errorf("got %d records at rate %g for 503", len(st[1].Records()), st[1].Rate())
+	}
+}
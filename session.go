@@ -0,0 +1,161 @@
+package cflogparser
+
+import (
+	"container/list"
+	"path"
+	"strings"
+	"time"
+)
+
+// Session is a series of requests from a visitor without a pause longer
+// than timeout of Sessionizer. Pages counts requests that look like page
+// views, i.e. URIs of HTML or without extension, while Requests counts all.
+type Session struct {
+	Key       string    `json:"key"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Requests  int       `json:"requests"`
+	Pages     int       `json:"pages"`
+	Bytes     uint64    `json:"bytes"`
+	EntryURI  string    `json:"entry_uri"`
+	ExitURI   string    `json:"exit_uri"`
+	Referrer  string    `json:"referrer"`
+	RequestIP string    `json:"request_ip"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// Duration returns the time between the first and the last request.
+func (s *Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// SessionKeyIPUA identifies a visitor by client IP address and user agent.
+func SessionKeyIPUA(l *WebLog) string {
+	return l.RequestIP.String() + "\t" + l.UserAgent
+}
+
+// SessionKeyCookie returns a function that identifies a visitor by the
+// value of cookie named name, such as a visitor ID. Requests without the
+// cookie are identified by SessionKeyIPUA.
+func SessionKeyCookie(name string) func(*WebLog) string {
+	return func(l *WebLog) string {
+		if v, ok := CookieValue(l.Cookie, name); ok {
+			return name + "=" + v
+		}
+		return SessionKeyIPUA(l)
+	}
+}
+
+// CookieValue returns the value of cookie named name in cookie field of
+// WebLog, which is formatted like Cookie header, e.g. "a=b; c=d".
+func CookieValue(cookie, name string) (string, bool) {
+	for _, c := range strings.Split(cookie, ";") {
+		c = strings.TrimSpace(c)
+		if i := strings.IndexByte(c, '='); i >= 0 && c[:i] == name {
+			return c[i+1:], true
+		}
+	}
+	return "", false
+}
+
+func isPage(uri string) bool {
+	switch strings.ToLower(path.Ext(uri)) {
+	case "", ".html", ".htm", ".php", ".asp", ".aspx", ".jsp":
+		return true
+	}
+	return false
+}
+
+// Sessionizer groups time-ordered WebLogs into Sessions. A session is closed
+// and passed to the emit function when no request comes from its visitor
+// for timeout, or when the number of open sessions exceeds maxOpen, in
+// which case the least recently active session is closed first. So, memory
+// usage is bounded regardless of the number of logs.
+type Sessionizer struct {
+	timeout time.Duration
+	maxOpen int
+	key     func(*WebLog) string
+	emit    func(*Session)
+	open    map[string]*list.Element
+	lru     *list.List
+}
+
+// NewSessionizer returns a Sessionizer that identifies visitors by key,
+// e.g. SessionKeyIPUA. maxOpen <= 0 means no limit.
+func NewSessionizer(timeout time.Duration, maxOpen int, key func(*WebLog) string, emit func(*Session)) *Sessionizer {
+	return &Sessionizer{
+		timeout: timeout,
+		maxOpen: maxOpen,
+		key:     key,
+		emit:    emit,
+		open:    map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Add adds l to the session of its visitor, and closes sessions timed out
+// at the time of l. Logs must be added in chronological order.
+func (z *Sessionizer) Add(l *WebLog) {
+	z.expire(l.Time)
+
+	k := z.key(l)
+	if e := z.open[k]; e != nil {
+		s := e.Value.(*Session)
+		s.End = l.Time
+		s.ExitURI = l.URI
+		s.add(l)
+		z.lru.MoveToBack(e)
+		return
+	}
+	s := &Session{
+		Key:       k,
+		Start:     l.Time,
+		End:       l.Time,
+		EntryURI:  l.URI,
+		ExitURI:   l.URI,
+		Referrer:  l.Referrer,
+		RequestIP: l.RequestIP.String(),
+		UserAgent: l.UserAgent,
+	}
+	s.add(l)
+	z.open[k] = z.lru.PushBack(s)
+	if z.maxOpen > 0 && z.lru.Len() > z.maxOpen {
+		z.close(z.lru.Front())
+	}
+}
+
+func (s *Session) add(l *WebLog) {
+	s.Requests++
+	if isPage(l.URI) {
+		s.Pages++
+	}
+	s.Bytes += l.Bytes
+}
+
+func (z *Sessionizer) expire(now time.Time) {
+	for e := z.lru.Front(); e != nil; e = z.lru.Front() {
+		if now.Sub(e.Value.(*Session).End) <= z.timeout {
+			return
+		}
+		z.close(e)
+	}
+}
+
+func (z *Sessionizer) close(e *list.Element) {
+	s := z.lru.Remove(e).(*Session)
+	delete(z.open, s.Key)
+	z.emit(s)
+}
+
+// Open returns the number of open sessions.
+func (z *Sessionizer) Open() int {
+	return z.lru.Len()
+}
+
+// Flush closes all open sessions in order of their last activity. Call it
+// at the end of input.
+func (z *Sessionizer) Flush() {
+	for e := z.lru.Front(); e != nil; e = z.lru.Front() {
+		z.close(e)
+	}
+}
@@ -0,0 +1,84 @@
+package cflogparser
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCookieValue(t *testing.T) {
+	tests := []struct {
+		cookie string
+		name   string
+		value  string
+		ok     bool
+	}{
+		{"zip=98101", "zip", "98101", true},
+		{"a=b; vid=123; c=d", "vid", "123", true},
+		{"a=b;vid=", "vid", "", true},
+		{"avid=1", "vid", "", false},
+		{"", "vid", "", false},
+	}
+	for _, test := range tests {
+		v, ok := CookieValue(test.cookie, test.name)
+		if v != test.value || ok != test.ok {
+			t.Errorf("CookieValue(%q, %q): got %q %v, want %q %v", test.cookie, test.name, v, ok, test.value, test.ok)
+		}
+	}
+}
+
+func TestSessionizer(t *testing.T) {
+	base := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	var sessions []*Session
+	z := NewSessionizer(30*time.Minute, 0, SessionKeyIPUA, func(s *Session) { sessions = append(sessions, s) })
+	add := func(min int, ip, uri string) {
+		z.Add(&WebLog{
+			Time:      base.Add(time.Duration(min) * time.Minute),
+			RequestIP: net.ParseIP(ip),
+			UserAgent: "Mozilla/5.0",
+			URI:       uri,
+			Referrer:  "https://www.google.com/",
+			Bytes:     100,
+		})
+	}
+	add(0, "192.0.2.1", "/index.html")
+	add(1, "192.0.2.1", "/logo.png")
+	add(5, "192.0.2.2", "/")
+	add(20, "192.0.2.1", "/about")
+	add(51, "192.0.2.1", "/contact") // 31 minutes after the last request
+	if len(sessions) != 2 {
+		t.Fatalf("got %d closed sessions, want 2", len(sessions))
+	}
+	if z.Open() != 1 {
+		t.Errorf("got %d open sessions, want 1", z.Open())
+	}
+
+	s := sessions[1]
+	if s.RequestIP != "192.0.2.1" || s.Requests != 3 || s.Pages != 2 || s.Bytes != 300 ||
+		s.EntryURI != "/index.html" || s.ExitURI != "/about" || s.Duration() != 20*time.Minute ||
+		s.Referrer != "https://www.google.com/" {
+		t.Errorf("unexpected session: %+v", s)
+	}
+	if sessions[0].RequestIP != "192.0.2.2" || sessions[0].Pages != 1 {
+		t.Errorf("unexpected session: %+v", sessions[0])
+	}
+
+	z.Flush()
+	if len(sessions) != 3 || sessions[2].EntryURI != "/contact" || z.Open() != 0 {
+		t.Errorf("unexpected sessions after flush: %+v", sessions)
+	}
+}
+
+func TestSessionizerMaxOpen(t *testing.T) {
+	base := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	var closed []string
+	key := SessionKeyCookie("vid")
+	z := NewSessionizer(time.Hour, 2, key, func(s *Session) { closed = append(closed, s.Key) })
+	for i, vid := range []string{"a", "b", "a", "c"} {
+		z.Add(&WebLog{Time: base.Add(time.Duration(i) * time.Second), Cookie: "x=y; vid=" + vid, URI: "/"})
+	}
+	// "b" is the least recently active when "c" arrives.
+	if len(closed) != 1 || closed[0] != "vid=b" || z.Open() != 2 {
+		t.Errorf("got closed %v and %d open sessions", closed, z.Open())
+	}
+}
@@ -0,0 +1,233 @@
+package cflogparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SLO defines availability and latency objectives for a set of requests
+// selected by Host and URI. A request is regarded as available unless its
+// status is 5xx, and as fast if its TimeTaken is not more than
+// LatencyThreshold seconds. Zero targets are not evaluated.
+//
+// SLOs are usually loaded from JSON by ParseSLOs like this:
+//
+//	[{"name": "api", "host": "api.example.com", "uri_prefix": "/v1/",
+//	  "availability": 0.999, "latency_threshold": 0.5, "latency_target": 0.99}]
+type SLO struct {
+	Name             string  `json:"name"`
+	Host             string  `json:"host"`
+	URIPrefix        string  `json:"uri_prefix"`
+	URIPattern       string  `json:"uri_pattern"`
+	Availability     float64 `json:"availability"`
+	LatencyThreshold float64 `json:"latency_threshold"`
+	LatencyTarget    float64 `json:"latency_target"`
+	uriPattern       *regexp.Regexp
+}
+
+// ParseSLOs reads a JSON array of SLOs from r.
+func ParseSLOs(r io.Reader) ([]*SLO, error) {
+	var slos []*SLO
+	if err := json.NewDecoder(r).Decode(&slos); err != nil {
+		return nil, fmt.Errorf("Can't parse SLOs: %w", err)
+	}
+	for _, s := range slos {
+		if err := s.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return slos, nil
+}
+
+func (s *SLO) compile() error {
+	if s.Availability < 0 || s.Availability >= 1 || s.LatencyTarget < 0 || s.LatencyTarget >= 1 {
+		return fmt.Errorf("Targets of SLO %q must be in [0, 1)", s.Name)
+	}
+	if s.URIPattern != "" {
+		re, err := regexp.Compile(s.URIPattern)
+		if err != nil {
+			return fmt.Errorf("Invalid uri_pattern of SLO %q: %w", s.Name, err)
+		}
+		s.uriPattern = re
+	}
+	return nil
+}
+
+// Match reports whether l is subject to s. Host matches either Host
+// (domain name of the distribution) or HostHeader (alternate domain name).
+func (s *SLO) Match(l *WebLog) bool {
+	if s.Host != "" && s.Host != l.Host && s.Host != l.HostHeader {
+		return false
+	}
+	if !strings.HasPrefix(l.URI, s.URIPrefix) {
+		return false
+	}
+	if s.URIPattern != "" {
+		if s.uriPattern == nil && s.compile() != nil {
+			return false
+		}
+		if !s.uriPattern.MatchString(l.URI) {
+			return false
+		}
+	}
+	return true
+}
+
+// SLICount counts requests for service level indicators.
+type SLICount struct {
+	Requests  uint64 `json:"requests"`
+	Available uint64 `json:"available"`
+	Fast      uint64 `json:"fast"`
+}
+
+func (c *SLICount) merge(o *SLICount) {
+	c.Requests += o.Requests
+	c.Available += o.Available
+	c.Fast += o.Fast
+}
+
+// AvailabilitySLI returns the ratio of available requests.
+func (c *SLICount) AvailabilitySLI() float64 {
+	return ratio(c.Available, c.Requests)
+}
+
+// LatencySLI returns the ratio of fast requests.
+func (c *SLICount) LatencySLI() float64 {
+	return ratio(c.Fast, c.Requests)
+}
+
+// burnRate returns how fast error budget is consumed; 1 means the budget is
+// used up exactly at the end of SLO period.
+func burnRate(good, total uint64, target float64) float64 {
+	if target == 0 || total == 0 {
+		return 0
+	}
+	return (1 - ratio(good, total)) / (1 - target)
+}
+
+// budgetRemaining returns the ratio of error budget not consumed yet.
+// It becomes negative when the budget is exhausted.
+func budgetRemaining(good, total uint64, target float64) float64 {
+	if target == 0 {
+		return 0
+	}
+	return 1 - burnRate(good, total, target)
+}
+
+// SLOPoint is SLI and burn rates of a time window starting at Time.
+type SLOPoint struct {
+	Time time.Time `json:"time"`
+	SLICount
+	AvailabilityBurnRate float64 `json:"availability_burn_rate"`
+	LatencyBurnRate      float64 `json:"latency_burn_rate"`
+}
+
+// BurnRate is burn rates over the last Window.
+type BurnRate struct {
+	Window       time.Duration `json:"window"`
+	Availability float64       `json:"availability"`
+	Latency      float64       `json:"latency"`
+}
+
+// SLOSummary is the result of SLO evaluation over the whole period.
+type SLOSummary struct {
+	Name string `json:"name"`
+	SLICount
+	AvailabilitySLI             float64    `json:"availability_sli"`
+	AvailabilityBudgetRemaining float64    `json:"availability_budget_remaining"`
+	LatencySLI                  float64    `json:"latency_sli"`
+	LatencyBudgetRemaining      float64    `json:"latency_budget_remaining"`
+	BurnRates                   []BurnRate `json:"burn_rates"`
+}
+
+// SLOTracker evaluates an SLO over a stream of WebLogs. It counts requests
+// in time windows of fixed interval, so its memory usage is proportional
+// to the time range of logs, not to the number of logs.
+type SLOTracker struct {
+	SLO      *SLO
+	interval time.Duration
+	buckets  map[time.Time]*SLICount
+	last     time.Time
+}
+
+// NewSLOTracker returns an SLOTracker that counts requests in windows of
+// interval. Burn rates can be computed for multiples of interval.
+func NewSLOTracker(slo *SLO, interval time.Duration) *SLOTracker {
+	return &SLOTracker{SLO: slo, interval: interval, buckets: map[time.Time]*SLICount{}}
+}
+
+// Add counts l if it matches the SLO.
+func (t *SLOTracker) Add(l *WebLog) {
+	if !t.SLO.Match(l) {
+		return
+	}
+	k := l.Time.Truncate(t.interval)
+	b := t.buckets[k]
+	if b == nil {
+		b = &SLICount{}
+		t.buckets[k] = b
+	}
+	b.Requests++
+	if l.Status < 500 {
+		b.Available++
+	}
+	if float64(l.TimeTaken) <= t.SLO.LatencyThreshold {
+		b.Fast++
+	}
+	if l.Time.After(t.last) {
+		t.last = l.Time
+	}
+}
+
+// Series returns SLIs and burn rates of each time window in chronological
+// order.
+func (t *SLOTracker) Series() []SLOPoint {
+	ps := make([]SLOPoint, 0, len(t.buckets))
+	for k, b := range t.buckets {
+		ps = append(ps, SLOPoint{
+			Time:                 k,
+			SLICount:             *b,
+			AvailabilityBurnRate: burnRate(b.Available, b.Requests, t.SLO.Availability),
+			LatencyBurnRate:      burnRate(b.Fast, b.Requests, t.SLO.LatencyTarget),
+		})
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Time.Before(ps[j].Time) })
+	return ps
+}
+
+// Summary returns SLIs and remaining error budget over all logs added, and
+// burn rates over each of windows ending at the latest log.
+func (t *SLOTracker) Summary(windows []time.Duration) *SLOSummary {
+	var total SLICount
+	for _, b := range t.buckets {
+		total.merge(b)
+	}
+	s := &SLOSummary{
+		Name:                        t.SLO.Name,
+		SLICount:                    total,
+		AvailabilitySLI:             total.AvailabilitySLI(),
+		AvailabilityBudgetRemaining: budgetRemaining(total.Available, total.Requests, t.SLO.Availability),
+		LatencySLI:                  total.LatencySLI(),
+		LatencyBudgetRemaining:      budgetRemaining(total.Fast, total.Requests, t.SLO.LatencyTarget),
+	}
+	end := t.last.Truncate(t.interval).Add(t.interval)
+	for _, w := range windows {
+		var c SLICount
+		for k, b := range t.buckets {
+			if !k.Before(end.Add(-w)) {
+				c.merge(b)
+			}
+		}
+		s.BurnRates = append(s.BurnRates, BurnRate{
+			Window:       w,
+			Availability: burnRate(c.Available, c.Requests, t.SLO.Availability),
+			Latency:      burnRate(c.Fast, c.Requests, t.SLO.LatencyTarget),
+		})
+	}
+	return s
+}
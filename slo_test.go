@@ -0,0 +1,91 @@
+package cflogparser
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSLOs(t *testing.T) {
+	slos, err := ParseSLOs(strings.NewReader(`[
+		{"name": "api", "host": "api.example.com", "uri_prefix": "/v1/", "availability": 0.99, "latency_threshold": 0.5, "latency_target": 0.9},
+		{"name": "images", "uri_pattern": "\\.(png|jpg)$", "availability": 0.999}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slos) != 2 {
+		t.Fatalf("got %d SLOs, want 2", len(slos))
+	}
+	tests := []struct {
+		slo  *SLO
+		host string
+		uri  string
+		out  bool
+	}{
+		{slos[0], "api.example.com", "/v1/users", true},
+		{slos[0], "api.example.com", "/v2/users", false},
+		{slos[0], "www.example.com", "/v1/users", false},
+		{slos[1], "www.example.com", "/logo.png", true},
+		{slos[1], "www.example.com", "/index.html", false},
+	}
+	for _, test := range tests {
+		l := &WebLog{Host: "d111111abcdef8.cloudfront.net", HostHeader: test.host, URI: test.uri}
+		if m := test.slo.Match(l); m != test.out {
+			t.Errorf("%s: %s%s: got %v, want %v", test.slo.Name, test.host, test.uri, m, test.out)
+		}
+	}
+
+	for _, in := range []string{`[{"availability": 1.5}]`, `[{"uri_pattern": "("}]`, `{`} {
+		if _, err := ParseSLOs(strings.NewReader(in)); err == nil {
+			t.Errorf("%s should be rejected", in)
+		}
+	}
+}
+
+func TestSLOTracker(t *testing.T) {
+	slo := &SLO{Name: "all", Availability: 0.99, LatencyThreshold: 0.5, LatencyTarget: 0.9}
+	tr := NewSLOTracker(slo, time.Hour)
+	base := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	// 1st hour: 100 requests, 1 error and 5 slow.
+	for i := 0; i < 100; i++ {
+		l := &WebLog{Time: base.Add(time.Duration(i) * time.Second), Status: 200, TimeTaken: 0.1}
+		if i < 1 {
+			l.Status = 503
+		}
+		if i < 5 {
+			l.TimeTaken = 1.0
+		}
+		tr.Add(l)
+	}
+	// 2nd hour: 100 requests, 4 errors and 20 slow.
+	for i := 0; i < 100; i++ {
+		l := &WebLog{Time: base.Add(time.Hour + time.Duration(i)*time.Second), Status: 200, TimeTaken: 0.1}
+		if i < 4 {
+			l.Status = 500
+		}
+		if i < 20 {
+			l.TimeTaken = 0.8
+		}
+		tr.Add(l)
+	}
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	s := tr.Summary([]time.Duration{time.Hour, 2 * time.Hour})
+	if s.Requests != 200 || !near(s.AvailabilitySLI, 0.975) || !near(s.LatencySLI, 0.875) {
+		t.Errorf("unexpected SLIs: %+v", s)
+	}
+	if !near(s.AvailabilityBudgetRemaining, -1.5) || !near(s.LatencyBudgetRemaining, -0.25) {
+		t.Errorf("unexpected budget: %f %f", s.AvailabilityBudgetRemaining, s.LatencyBudgetRemaining)
+	}
+	if len(s.BurnRates) != 2 || !near(s.BurnRates[0].Availability, 4) || !near(s.BurnRates[0].Latency, 2) ||
+		!near(s.BurnRates[1].Availability, 2.5) || !near(s.BurnRates[1].Latency, 1.25) {
+		t.Errorf("unexpected burn rates: %+v", s.BurnRates)
+	}
+
+	ps := tr.Series()
+	if len(ps) != 2 || !ps[0].Time.Equal(base) || !near(ps[0].AvailabilityBurnRate, 1) || !near(ps[0].LatencyBurnRate, 0.5) {
+		t.Errorf("unexpected series: %+v", ps)
+	}
+}
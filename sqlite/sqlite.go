@@ -0,0 +1,284 @@
+// Package sqlite loads WebLog and RTMPLog into a SQLite database, to query
+// logs with standard SQL tools.
+//
+// The table mirrors fields of WebLog and RTMPLog with their JSON names.
+// Time is stored as text in "YYYY-MM-DD HH:MM:SS" in UTC, which the date and
+// time functions of SQLite understand, and IP addresses as text. Imported
+// files are recorded in the imported_files table per table, so that the
+// same files can be given again to append only new ones.
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Maki-Daisuke/cflogparser"
+)
+
+// TimeFormat is the layout of time stored in the database.
+const TimeFormat = "2006-01-02 15:04:05"
+
+// Options configures Loader.
+type Options struct {
+	// Table is the name of table. It is "web_log" or "rtmp_log" if empty.
+	Table string
+	// BatchSize is the number of rows inserted in a transaction. It is
+	// 10000 if zero.
+	BatchSize int
+}
+
+// Indexed lists columns to be indexed, if the table has them.
+var Indexed = []string{"time", "uri", "request_ip", "status"}
+
+// Schema returns statements to create the table of WebLog or RTMPLog named
+// table, its indexes and the imported_files table. Rows are linked to
+// imported_files by the file_id column, which is indexed as well.
+func Schema(table string, rtmp bool) []string {
+	fields := cflogparser.WebLogFields
+	if rtmp {
+		fields = cflogparser.RTMPLogFields
+	}
+	cols := make([]string, 0, len(fields)+1)
+	for _, f := range fields {
+		cols = append(cols, fmt.Sprintf("%s %s", quote(f.Name), columnType(f.Type)))
+	}
+	cols = append(cols, "file_id INTEGER")
+	stmts := []string{
+		"CREATE TABLE IF NOT EXISTS imported_files (" +
+			"id INTEGER PRIMARY KEY, table_name TEXT NOT NULL, name TEXT NOT NULL, " +
+			"rows INTEGER NOT NULL DEFAULT 0, done INTEGER NOT NULL DEFAULT 0, imported_at TEXT, " +
+			"UNIQUE (table_name, name))",
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", quote(table), strings.Join(cols, ",\n  ")),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (file_id)", quote(table+"_file_id"), quote(table)),
+	}
+	for _, name := range Indexed {
+		for _, f := range fields {
+			if f.Name == name {
+				stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+					quote(table+"_"+name), quote(table), quote(name)))
+			}
+		}
+	}
+	return stmts
+}
+
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func columnType(t reflect.Type) string {
+	switch t {
+	case reflect.TypeOf(time.Time{}), reflect.TypeOf(net.IP{}):
+		return "TEXT"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "TEXT"
+	case reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Float32:
+		return "REAL"
+	}
+	panic("unsupported field type: " + t.String())
+}
+
+// Loader inserts records into a table in transactions of BatchSize rows.
+//
+// Records of a file are inserted between Begin and End. If loading is
+// interrupted in the middle of a file, rows of the file are deleted by
+// Begin of the next time, so the file is imported again from scratch.
+type Loader struct {
+	db     *sql.DB
+	table  string
+	rtmp   bool
+	batch  int
+	fields []cflogparser.Field
+	insert *sql.Stmt
+	tx     *sql.Tx
+	stmt   *sql.Stmt
+	n      int
+	vals   []interface{}
+
+	fileID sql.NullInt64
+	rows   int
+}
+
+// NewLoader returns a Loader inserting records of WebLog, or RTMPLog if rtmp
+// is true, into db. Tables and indexes are created if they don't exist.
+func NewLoader(db *sql.DB, rtmp bool, opts Options) (*Loader, error) {
+	l := &Loader{db: db, table: opts.Table, rtmp: rtmp, batch: opts.BatchSize, fields: cflogparser.WebLogFields}
+	if rtmp {
+		l.fields = cflogparser.RTMPLogFields
+	}
+	if l.table == "" {
+		l.table = "web_log"
+		if rtmp {
+			l.table = "rtmp_log"
+		}
+	}
+	if l.batch <= 0 {
+		l.batch = 10000
+	}
+	for _, s := range Schema(l.table, rtmp) {
+		if _, err := db.Exec(s); err != nil {
+			return nil, err
+		}
+	}
+	cols := make([]string, 0, len(l.fields)+1)
+	for _, f := range l.fields {
+		cols = append(cols, quote(f.Name))
+	}
+	cols = append(cols, "file_id")
+	var err error
+	l.insert, err = db.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (?%s)",
+		quote(l.table), strings.Join(cols, ", "), strings.Repeat(", ?", len(cols)-1)))
+	if err != nil {
+		return nil, err
+	}
+	l.vals = make([]interface{}, len(cols))
+	return l, nil
+}
+
+// Begin starts to import the file named name. It returns false if the file
+// has already been imported, in which case records of the file should be
+// skipped. If name is empty, e.g. for STDIN, the file is not recorded.
+func (l *Loader) Begin(name string) (bool, error) {
+	if err := l.commit(); err != nil {
+		return false, err
+	}
+	l.fileID = sql.NullInt64{}
+	l.rows = 0
+	if name == "" {
+		return true, nil
+	}
+	var id int64
+	var done bool
+	err := l.db.QueryRow("SELECT id, done FROM imported_files WHERE table_name = ? AND name = ?", l.table, name).Scan(&id, &done)
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := l.db.Exec("INSERT INTO imported_files (table_name, name) VALUES (?, ?)", l.table, name)
+		if err != nil {
+			return false, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return false, err
+		}
+	case err != nil:
+		return false, err
+	case done:
+		return false, nil
+	default:
+		// The last import of the file was interrupted.
+		if _, err := l.db.Exec("DELETE FROM "+quote(l.table)+" WHERE file_id = ?", id); err != nil {
+			return false, err
+		}
+	}
+	l.fileID = sql.NullInt64{Int64: id, Valid: true}
+	return true, nil
+}
+
+// Insert inserts rec, which must be *WebLog or *RTMPLog as given to
+// NewLoader. The transaction is committed every BatchSize rows.
+func (l *Loader) Insert(rec interface{}) error {
+	switch rec.(type) {
+	case *cflogparser.WebLog:
+		if l.rtmp {
+			return fmt.Errorf("Unexpected record type: %T", rec)
+		}
+	case *cflogparser.RTMPLog:
+		if !l.rtmp {
+			return fmt.Errorf("Unexpected record type: %T", rec)
+		}
+	default:
+		return fmt.Errorf("Unexpected record type: %T", rec)
+	}
+	if err := l.begin(); err != nil {
+		return err
+	}
+	for i, f := range l.fields {
+		switch v := f.Value(rec).(type) {
+		case time.Time:
+			l.vals[i] = v.UTC().Format(TimeFormat)
+		case net.IP:
+			l.vals[i] = f.String(rec)
+		case uint16:
+			l.vals[i] = int64(v)
+		case uint32:
+			l.vals[i] = int64(v)
+		case uint64:
+			l.vals[i] = int64(v)
+		case float32:
+			l.vals[i] = float64(v)
+		default:
+			l.vals[i] = v
+		}
+	}
+	l.vals[len(l.fields)] = l.fileID
+	if _, err := l.stmt.Exec(l.vals...); err != nil {
+		return err
+	}
+	l.rows++
+	l.n++
+	if l.n >= l.batch {
+		return l.commit()
+	}
+	return nil
+}
+
+// End marks the file started by Begin as imported, and commits the
+// transaction.
+func (l *Loader) End() error {
+	if l.fileID.Valid {
+		if err := l.begin(); err != nil {
+			return err
+		}
+		_, err := l.tx.Exec("UPDATE imported_files SET rows = ?, done = 1, imported_at = ? WHERE id = ?",
+			l.rows, time.Now().UTC().Format(TimeFormat), l.fileID.Int64)
+		if err != nil {
+			return err
+		}
+		l.fileID = sql.NullInt64{}
+	}
+	return l.commit()
+}
+
+// Rows returns the number of rows inserted since the last Begin.
+func (l *Loader) Rows() int {
+	return l.rows
+}
+
+// Close commits the transaction and releases the prepared statement. It
+// doesn't close the database.
+func (l *Loader) Close() error {
+	err := l.commit()
+	if e := l.insert.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (l *Loader) begin() error {
+	if l.tx != nil {
+		return nil
+	}
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	l.tx = tx
+	l.stmt = tx.Stmt(l.insert)
+	return nil
+}
+
+func (l *Loader) commit() error {
+	if l.tx == nil {
+		return nil
+	}
+	err := l.tx.Commit()
+	l.tx, l.stmt, l.n = nil, nil, 0
+	return err
+}
@@ -0,0 +1,201 @@
+package sqlite
+
+import (
+	"database/sql"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/Maki-Daisuke/cflogparser"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func readLogs(t *testing.T) []interface{} {
+	t.Helper()
+	r, err := cflogparser.OpenReader("../testdata/sample-rtmp.log", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var recs []interface{}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return recs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+}
+
+func count(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestLoader(t *testing.T) {
+	db := openDB(t)
+	recs := readLogs(t)
+	l, err := NewLoader(db, true, Options{BatchSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func(name string) bool {
+		ok, err := l.Begin(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			return false
+		}
+		for _, rec := range recs {
+			if err := l.Insert(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := l.End(); err != nil {
+			t.Fatal(err)
+		}
+		return true
+	}
+	if !load("a.log") || !load("b.log") {
+		t.Fatal("new files are skipped")
+	}
+	if load("a.log") {
+		t.Error("imported file is not skipped")
+	}
+	if err := l.Insert(&cflogparser.WebLog{}); err == nil {
+		t.Error("expected error for WebLog")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(t, db, "SELECT count(*) FROM rtmp_log"); n != 2*len(recs) {
+		t.Errorf("got %d rows, want %d", n, 2*len(recs))
+	}
+	if n := count(t, db, "SELECT rows FROM imported_files WHERE name = 'b.log' AND done"); n != len(recs) {
+		t.Errorf("got %d rows of b.log, want %d", n, len(recs))
+	}
+	if n := count(t, db, "SELECT sum(bytes) FROM rtmp_log WHERE event_type = 'stop' AND time >= '2010-03-12 23:53:44'"); n != 2*(323914+429822014) {
+		t.Errorf("got sum of bytes %d", n)
+	}
+	if n := count(t, db, "SELECT stream_id FROM rtmp_log WHERE request_ip = '192.0.2.103'"); n != 2 {
+		t.Errorf("got stream_id %d, want 2", n)
+	}
+	for _, idx := range []string{"rtmp_log_time", "rtmp_log_uri", "rtmp_log_request_ip", "rtmp_log_status", "rtmp_log_file_id"} {
+		if n := count(t, db, "SELECT count(*) FROM sqlite_master WHERE type = 'index' AND name = ?", idx); n != 1 {
+			t.Errorf("missing index %s", idx)
+		}
+	}
+}
+
+func TestLoaderInterrupted(t *testing.T) {
+	db := openDB(t)
+	recs := readLogs(t)
+	l, err := NewLoader(db, true, Options{Table: "rtmp", BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Interrupted after some batches are committed
+	if _, err := l.Begin("a.log"); err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range recs[:3] {
+		if err := l.Insert(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Imported again from scratch
+	l, err = NewLoader(db, true, Options{Table: "rtmp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if ok, err := l.Begin("a.log"); err != nil || !ok {
+		t.Fatalf("interrupted file is not imported again: %v", err)
+	}
+	for _, rec := range recs {
+		if err := l.Insert(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.End(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, db, "SELECT count(*) FROM rtmp"); n != len(recs) {
+		t.Errorf("got %d rows, want %d", n, len(recs))
+	}
+
+	// Not recorded without name
+	if ok, err := l.Begin(""); err != nil || !ok {
+		t.Fatal(err)
+	}
+	l.Insert(recs[0])
+	if err := l.End(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, db, "SELECT count(*) FROM rtmp WHERE file_id IS NULL"); n != 1 {
+		t.Errorf("got %d rows without file, want 1", n)
+	}
+	if n := count(t, db, "SELECT count(*) FROM imported_files"); n != 1 {
+		t.Errorf("got %d files, want 1", n)
+	}
+}
+
+func TestLoaderTables(t *testing.T) {
+	db := openDB(t)
+	recs := readLogs(t)
+	load := func(table string) bool {
+		t.Helper()
+		l, err := NewLoader(db, true, Options{Table: table})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		ok, err := l.Begin("a.log")
+		if err != nil || !ok {
+			return false
+		}
+		for _, rec := range recs {
+			if err := l.Insert(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := l.End(); err != nil {
+			t.Fatal(err)
+		}
+		return true
+	}
+	if !load("a") || !load("b") {
+		t.Error("file imported into another table is skipped")
+	}
+	if load("a") {
+		t.Error("imported file is not skipped")
+	}
+	for _, table := range []string{"a", "b"} {
+		if n := count(t, db, "SELECT count(*) FROM "+table); n != len(recs) {
+			t.Errorf("got %d rows in %s, want %d", n, table, len(recs))
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package cflogparser
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const topKVersion = 1
+
+// maxTopKCapacity bounds capacity of TopK read by UnmarshalBinary.
+const maxTopKCapacity = 1 << 24
+
+// TopKEntry is an item reported by TopK. The true count of Key lies
+// between Count-Error and Count.
+type TopKEntry struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+	Error uint64 `json:"error"`
+}
+
+// TopK finds heavy hitters, e.g. top 100 URIs, in bounded memory with the
+// Space-Saving algorithm. It keeps at most capacity counters; when a new
+// key arrives and all counters are in use, the smallest counter is taken
+// over and its count becomes the error bound of the new key. So, any key
+// whose true count is more than Total()/capacity is guaranteed to be kept.
+// A TopK can be merged with another, so that files can be processed
+// separately and combined later.
+type TopK struct {
+	capacity int
+	total    uint64
+	index    map[string]*topKCounter
+	heap     topKHeap
+}
+
+type topKCounter struct {
+	TopKEntry
+	pos int
+}
+
+// NewTopK returns an empty TopK that keeps at most capacity counters.
+// Use capacity several times larger than the number of items you want to
+// get by Top to get accurate result.
+func NewTopK(capacity int) *TopK {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &TopK{capacity: capacity, index: map[string]*topKCounter{}}
+}
+
+// Add counts key n times.
+func (t *TopK) Add(key string, n uint64) {
+	t.add(key, n)
+}
+
+// add is Add, which returns the key evicted for key, if any.
+func (t *TopK) add(key string, n uint64) (evicted string, ok bool) {
+	t.total += n
+	if c := t.index[key]; c != nil {
+		c.Count += n
+		heap.Fix(&t.heap, c.pos)
+		return "", false
+	}
+	if len(t.heap) < t.capacity {
+		c := &topKCounter{TopKEntry: TopKEntry{Key: key, Count: n}}
+		t.index[key] = c
+		heap.Push(&t.heap, c)
+		return "", false
+	}
+	// Replace the minimum counter.
+	c := t.heap[0]
+	evicted = c.Key
+	delete(t.index, c.Key)
+	c.Key = key
+	c.Error = c.Count
+	c.Count += n
+	t.index[key] = c
+	heap.Fix(&t.heap, 0)
+	return evicted, true
+}
+
+// Total returns the sum of all counts added to t.
+func (t *TopK) Total() uint64 {
+	return t.total
+}
+
+// ErrorBound returns the maximum overestimation of any count reported by t,
+// which is also the minimum count a key not reported by t can have.
+func (t *TopK) ErrorBound() uint64 {
+	if len(t.heap) < t.capacity {
+		return 0
+	}
+	return t.heap[0].Count
+}
+
+// Top returns at most n entries in descending order of count.
+func (t *TopK) Top(n int) []TopKEntry {
+	es := make([]TopKEntry, 0, len(t.heap))
+	for _, c := range t.heap {
+		es = append(es, c.TopKEntry)
+	}
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].Count != es[j].Count {
+			return es[i].Count > es[j].Count
+		}
+		return es[i].Key < es[j].Key
+	})
+	if n < len(es) {
+		es = es[:n]
+	}
+	return es
+}
+
+// Merge merges o into t. Keys missing in either summary are assumed to
+// have the minimum count of that summary, so error bounds still hold.
+// Both must have the same capacity, otherwise ErrorBound of the result
+// could understate errors of counts.
+func (t *TopK) Merge(o *TopK) error {
+	if t.capacity != o.capacity {
+		return fmt.Errorf("Can't merge TopKs of different capacities: %d and %d", t.capacity, o.capacity)
+	}
+	minT, minO := t.ErrorBound(), o.ErrorBound()
+	merged := map[string]TopKEntry{}
+	for k, c := range t.index {
+		e := c.TopKEntry
+		if oc := o.index[k]; oc != nil {
+			e.Count += oc.Count
+			e.Error += oc.Error
+		} else {
+			e.Count += minO
+			e.Error += minO
+		}
+		merged[k] = e
+	}
+	for k, oc := range o.index {
+		if _, ok := merged[k]; !ok {
+			merged[k] = TopKEntry{Key: k, Count: oc.Count + minT, Error: oc.Error + minT}
+		}
+	}
+	es := make([]TopKEntry, 0, len(merged))
+	for _, e := range merged {
+		es = append(es, e)
+	}
+	total := t.total + o.total
+	t.reset(es)
+	t.total = total
+	return nil
+}
+
+// reset replaces counters of t with top entries of es.
+func (t *TopK) reset(es []TopKEntry) {
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].Count != es[j].Count {
+			return es[i].Count > es[j].Count
+		}
+		return es[i].Key < es[j].Key
+	})
+	if len(es) > t.capacity {
+		es = es[:t.capacity]
+	}
+	t.index = make(map[string]*topKCounter, len(es))
+	t.heap = make(topKHeap, 0, len(es))
+	for _, e := range es {
+		c := &topKCounter{TopKEntry: e}
+		t.index[e.Key] = c
+		heap.Push(&t.heap, c)
+	}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (t *TopK) MarshalBinary() ([]byte, error) {
+	b := []byte{topKVersion}
+	b = binary.AppendUvarint(b, uint64(t.capacity))
+	b = binary.AppendUvarint(b, t.total)
+	b = binary.AppendUvarint(b, uint64(len(t.heap)))
+	for _, c := range t.heap {
+		b = binary.AppendUvarint(b, uint64(len(c.Key)))
+		b = append(b, c.Key...)
+		b = binary.AppendUvarint(b, c.Count)
+		b = binary.AppendUvarint(b, c.Error)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (t *TopK) UnmarshalBinary(b []byte) error {
+	errInvalid := errors.New("Invalid TopK data")
+	if len(b) < 1 || b[0] != topKVersion {
+		return errInvalid
+	}
+	b = b[1:]
+	next := func() (uint64, bool) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, false
+		}
+		b = b[n:]
+		return v, true
+	}
+	capacity, ok1 := next()
+	total, ok2 := next()
+	n, ok3 := next()
+	// Each entry takes at least 3 bytes.
+	if !ok1 || !ok2 || !ok3 || capacity < 1 || capacity > maxTopKCapacity || n > capacity || n > uint64(len(b))/3 {
+		return errInvalid
+	}
+	es := make([]TopKEntry, 0, n)
+	for i := uint64(0); i < n; i++ {
+		l, ok := next()
+		if !ok || uint64(len(b)) < l {
+			return errInvalid
+		}
+		key := string(b[:l])
+		b = b[l:]
+		count, ok1 := next()
+		e, ok2 := next()
+		if !ok1 || !ok2 {
+			return errInvalid
+		}
+		es = append(es, TopKEntry{Key: key, Count: count, Error: e})
+	}
+	if len(b) != 0 {
+		return errInvalid
+	}
+	t.capacity = int(capacity)
+	t.reset(es)
+	t.total = total
+	return nil
+}
+
+// topKHeap is a min-heap of counters ordered by count.
+type topKHeap []*topKCounter
+
+func (h topKHeap) Len() int           { return len(h) }
+func (h topKHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h topKHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *topKHeap) Push(x interface{}) {
+	c := x.(*topKCounter)
+	c.pos = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *topKHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,119 @@
+package cflogparser
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// zipfKeys generates n keys in Zipf distribution, which is typical for URIs
+// and client IPs, and returns them with exact counts.
+func zipfKeys(seed int64, n int) ([]string, map[string]uint64) {
+	r := rand.New(rand.NewSource(seed))
+	z := rand.NewZipf(r, 1.2, 1, 100000)
+	keys := make([]string, n)
+	exact := map[string]uint64{}
+	for i := range keys {
+		keys[i] = fmt.Sprintf("/path/%d", z.Uint64())
+		exact[keys[i]]++
+	}
+	return keys, exact
+}
+
+func exactTop(exact map[string]uint64, n int) []string {
+	ks := make([]string, 0, len(exact))
+	for k := range exact {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool { return exact[ks[i]] > exact[ks[j]] })
+	return ks[:n]
+}
+
+func checkTopK(t *testing.T, tk *TopK, exact map[string]uint64) {
+	t.Helper()
+	for _, e := range tk.Top(100) {
+		if c := exact[e.Key]; c > e.Count || c < e.Count-e.Error {
+			t.Errorf("%s: true count %d is out of [%d, %d]", e.Key, c, e.Count-e.Error, e.Count)
+		}
+	}
+	got := map[string]bool{}
+	for _, e := range tk.Top(10) {
+		got[e.Key] = true
+	}
+	for _, k := range exactTop(exact, 10) {
+		if !got[k] {
+			t.Errorf("%s (count %d) is missing in top 10", k, exact[k])
+		}
+	}
+}
+
+func TestTopK(t *testing.T) {
+	keys, exact := zipfKeys(1, 100000)
+	tk := NewTopK(1000)
+	for _, k := range keys {
+		tk.Add(k, 1)
+	}
+	if tk.Total() != uint64(len(keys)) {
+		t.Errorf("got total %d, want %d", tk.Total(), len(keys))
+	}
+	if tk.ErrorBound() > tk.Total()/1000 {
+		t.Errorf("error bound %d exceeds total/capacity", tk.ErrorBound())
+	}
+	checkTopK(t, tk, exact)
+}
+
+func TestTopKMerge(t *testing.T) {
+	keys, exact := zipfKeys(2, 100000)
+	a, b := NewTopK(1000), NewTopK(1000)
+	for i, k := range keys {
+		if i < len(keys)/3 {
+			a.Add(k, 1)
+		} else {
+			b.Add(k, 1)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Total() != uint64(len(keys)) {
+		t.Errorf("got total %d, want %d", a.Total(), len(keys))
+	}
+	checkTopK(t, a, exact)
+	if err := a.Merge(NewTopK(2000)); err == nil {
+		t.Error("expected error for different capacities")
+	}
+}
+
+func TestTopKMarshal(t *testing.T) {
+	keys, _ := zipfKeys(3, 10000)
+	tk := NewTopK(50)
+	for _, k := range keys {
+		tk.Add(k, 1)
+	}
+	b, err := tk.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tk2 TopK
+	if err := tk2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tk.Top(50), tk2.Top(50)) || tk.Total() != tk2.Total() || tk.ErrorBound() != tk2.ErrorBound() {
+		t.Error("TopK differs after round trip")
+	}
+	if err := tk2.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Error("truncated data should fail")
+	}
+	for _, b := range [][]byte{
+		// Huge capacity and number of entries
+		{topKVersion, 0xff, 0xff, 0xff, 0xff, 0x0f, 0, 0xff, 0xff, 0xff, 0x0f},
+		// More entries than data
+		{topKVersion, 100, 0, 50, 1, 'a', 1, 0},
+	} {
+		if err := tk2.UnmarshalBinary(b); err == nil {
+			t.Errorf("malformed data % x should fail", b)
+		}
+	}
+}
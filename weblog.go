@@ -55,7 +55,7 @@ func ParseLineWeb(line string) (l *WebLog, err error) {
 		}
 	}()
 
-	l.Time, err = time.Parse("2006-01-02 15:04:05", vals[0]+" "+vals[1])
+	l.Time, err = ParseTimeField(vals[0], vals[1])
 	if err != nil {
 		panic(err)
 	}
@@ -63,8 +63,8 @@ func ParseLineWeb(line string) (l *WebLog, err error) {
 	l.Location = parseString(vals[2])
 	l.Bytes = uint64(parseInt(vals[3]))
 
-	l.RequestIP = net.ParseIP(vals[4])
-	if l.RequestIP == nil {
+	l.RequestIP, err = ParseIPField(vals[4])
+	if err != nil {
 		panic(err)
 	}
 
@@ -94,17 +94,15 @@ func ParseLineWeb(line string) (l *WebLog, err error) {
 }
 
 func parseString(f string) string {
-	if f == "-" {
-		return ""
+	s, err := ParseStringField(f)
+	if err != nil {
+		panic(err)
 	}
-	return MustUnescape(f)
+	return s
 }
 
 func parseInt(f string) int64 {
-	if f == "-" {
-		return 0
-	}
-	n, err := strconv.ParseInt(f, 10, 64)
+	n, err := ParseIntField(f)
 	if err != nil {
 		panic(err)
 	}
@@ -112,12 +110,51 @@ func parseInt(f string) int64 {
 }
 
 func parseFloat(f string) float64 {
-	if f == "-" {
-		return 0.0
-	}
-	n, err := strconv.ParseFloat(f, 64)
+	n, err := ParseFloatField(f)
 	if err != nil {
 		panic(err)
 	}
 	return n
 }
+
+// ParseTimeField parses date and time fields of a line. Fields of a line
+// are parsed by ParseLineWeb and ParseLineRTMP with ParseTimeField and
+// the following functions, which are exported for parsers building other
+// representations of records.
+func ParseTimeField(date, tm string) (time.Time, error) {
+	return time.Parse("2006-01-02 15:04:05", date+" "+tm)
+}
+
+// ParseStringField parses a string field. "-" means an empty string.
+func ParseStringField(f string) (string, error) {
+	if f == "-" {
+		return "", nil
+	}
+	return Unescape(f)
+}
+
+// ParseIntField parses an integer field. "-" means 0. Unsigned fields are
+// converted from the result.
+func ParseIntField(f string) (int64, error) {
+	if f == "-" {
+		return 0, nil
+	}
+	return strconv.ParseInt(f, 10, 64)
+}
+
+// ParseFloatField parses a float field. "-" means 0.
+func ParseFloatField(f string) (float64, error) {
+	if f == "-" {
+		return 0.0, nil
+	}
+	return strconv.ParseFloat(f, 64)
+}
+
+// ParseIPField parses an IP address field.
+func ParseIPField(f string) (net.IP, error) {
+	ip := net.ParseIP(f)
+	if ip == nil {
+		return nil, fmt.Errorf("Invalid IP address: %s", f)
+	}
+	return ip, nil
+}